@@ -2,6 +2,7 @@ package mp3joiner
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"sort"
 	"strconv"
@@ -18,12 +19,18 @@ type Chapter struct {
 	Start    int    `json:"start,omitempty"`
 	End      int    `json:"end,omitempty"`
 	Tags     Tags   `json:"tags,omitempty"`
+	// element id of the ID3v2 CHAP frame
+	ElementID string   `json:"element_id,omitempty"`
+	Links     []Link   `json:"links,omitempty"`
+	Image     *Picture `json:"image,omitempty"`
 
 	cachedMultiplicator int
 }
 
 type Tags struct {
-	Title string `json:"title,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Subtitle string `json:"subtitle,omitempty"`
+	Artist   string `json:"artist,omitempty"`
 }
 
 type metadata struct {
@@ -72,6 +79,52 @@ func (c *Chapter) SetEndTime(seconds float64) {
 	c.End = intermediate
 }
 
+// Returns copies of the chapters moved by the offset and expressed
+// in the default time base, so that chapters originating from files
+// with different time bases can be combined.
+func rebaseChapters(chapters []Chapter, offsetInSeconds float64) (result []Chapter) {
+	result = make([]Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		rebased := chapter
+		rebased.TimeBase = DEFAULT_TIME_BASE
+		rebased.cachedMultiplicator = DEFAULT_TIME_BASE_INT
+		rebased.Start = toDefaultTimeBase(chapter.GetStartTimeInSeconds() + offsetInSeconds)
+		rebased.End = toDefaultTimeBase(chapter.GetEndTimeInSeconds() + offsetInSeconds)
+		result = append(result, rebased)
+	}
+	return result
+}
+
+// Scales the start and end of all chapters by the factor,
+// e.g. 0.5 moves a chapter from 10s to 5s.
+func scaleChapters(chapters []Chapter, factor float64) (result []Chapter) {
+	result = make([]Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		scaled := chapter
+		scaled.TimeBase = DEFAULT_TIME_BASE
+		scaled.cachedMultiplicator = DEFAULT_TIME_BASE_INT
+		scaled.Start = toDefaultTimeBase(chapter.GetStartTimeInSeconds() * factor)
+		scaled.End = toDefaultTimeBase(chapter.GetEndTimeInSeconds() * factor)
+		result = append(result, scaled)
+	}
+	return result
+}
+
+func toDefaultTimeBase(seconds float64) int {
+	return int(math.Round(seconds * float64(DEFAULT_TIME_BASE_INT)))
+}
+
+// Returns true if any chapter has data besides its title,
+// which can only be stored in ID3v2 CHAP frames.
+func hasChapterDetails(chapters []Chapter) bool {
+	for _, chapter := range chapters {
+		if chapter.Tags.Subtitle != "" || chapter.Tags.Artist != "" || len(chapter.Links) > 0 || chapter.Image != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func getChapterInTimeFrame(chapters []Chapter, startInSeconds float64, endInSeconds float64) (result []Chapter) {
 	result = make([]Chapter, 0)
 
@@ -265,3 +265,62 @@ func TestChapter_getCachedMultiplicator(t *testing.T) {
 		})
 	}
 }
+
+func Test_rebaseChapters(t *testing.T) {
+	type args struct {
+		chapters        []Chapter
+		offsetInSeconds float64
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantResult []Chapter
+	}{
+		{
+			name: "shift and normalize time base",
+			args: args{
+				chapters: []Chapter{
+					{TimeBase: "1/1000", Start: 1500, End: 2500, Tags: Tags{Title: "demo"}},
+				},
+				offsetInSeconds: 10,
+			},
+			wantResult: []Chapter{
+				{
+					TimeBase:            DEFAULT_TIME_BASE,
+					Start:               11500000000,
+					End:                 12500000000,
+					Tags:                Tags{Title: "demo"},
+					cachedMultiplicator: DEFAULT_TIME_BASE_INT,
+				},
+			},
+		}, {
+			name: "negative offset",
+			args: args{
+				chapters: []Chapter{
+					{TimeBase: "1/1", Start: 3, End: 5, Tags: Tags{Title: "demo"}},
+				},
+				offsetInSeconds: -3,
+			},
+			wantResult: []Chapter{
+				{
+					TimeBase:            DEFAULT_TIME_BASE,
+					Start:               0,
+					End:                 2000000000,
+					Tags:                Tags{Title: "demo"},
+					cachedMultiplicator: DEFAULT_TIME_BASE_INT,
+				},
+			},
+		}, {
+			name:       "no chapters",
+			args:       args{chapters: nil, offsetInSeconds: 5},
+			wantResult: []Chapter{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotResult := rebaseChapters(tt.args.chapters, tt.args.offsetInSeconds); !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("rebaseChapters() = %v, want %v", gotResult, tt.wantResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package mp3joiner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format of a chapter file stored next to the audio, see WithChapterFile.
+type ChapterFileFormat int
+
+const (
+	// Podcasting 2.0 JSON chapters, written as NAME.chapters.json
+	ChapterFileFormatPodcastJSON ChapterFileFormat = iota
+	// Podlove Simple Chapters, written as NAME.chapters.xml
+	ChapterFileFormatPodlove
+)
+
+// Returns the path of the chapter file written next to the audio file.
+func getChapterFilePath(audioFilepath string, format ChapterFileFormat) string {
+	name := strings.TrimSuffix(audioFilepath, filepath.Ext(audioFilepath))
+	switch format {
+	case ChapterFileFormatPodlove:
+		return name + ".chapters.xml"
+	default:
+		return name + ".chapters.json"
+	}
+}
+
+// Returns the function encoding chapters in the given format.
+func getChapterFileEncoder(format ChapterFileFormat) (func(io.Writer, []Chapter) error, error) {
+	switch format {
+	case ChapterFileFormatPodcastJSON:
+		return func(writer io.Writer, chapters []Chapter) error {
+			return EncodePodcastChapters(writer, chapters, nil)
+		}, nil
+	case ChapterFileFormatPodlove:
+		return EncodePodloveChapters, nil
+	default:
+		return nil, fmt.Errorf("unknown chapter file format %d", format)
+	}
+}
+
+// Writes the chapters next to the audio file in the given format.
+func writeChapterFile(audioFilepath string, format ChapterFileFormat, chapters []Chapter) (err error) {
+	encode, err := getChapterFileEncoder(format)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(getChapterFilePath(audioFilepath, format))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return encode(file, chapters)
+}
@@ -0,0 +1,70 @@
+package mp3joiner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMP3Builder_BuildWithChapterFile(t *testing.T) {
+	executions := appendExecutions("00:00:10.00", `[{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Intro"}}]`, `{"bit_rate":"64000"}`, true)
+	executions = append(executions, appendExecutions("00:00:20.00", `[{"time_base":"1/1000","start":0,"end":20000,"tags":{"title":"Main"}}]`, `{"bit_rate":"64000"}`, false)...)
+	executions = append(executions, Execution{Name: "ffmpeg"})
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithChapterFile(ChapterFileFormatPodcastJSON), WithChapterFile(ChapterFileFormatPodlove))
+	if err := builder.Append("first.mp3", 2, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Append("second.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	outputFilepath := filepath.Join(t.TempDir(), "out.mp3")
+	if err := builder.Build(outputFilepath); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	tests := []struct {
+		fileName string
+		want     []string
+	}{
+		{
+			fileName: "out.chapters.json",
+			want:     []string{`"startTime": 8,`, `"endTime": 28,`},
+		}, {
+			fileName: "out.chapters.xml",
+			want:     []string{`start="00:00:08.000" title="Main"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join(filepath.Dir(outputFilepath), tt.fileName))
+			if err != nil {
+				t.Fatalf("MP3Builder.Build() expected chapter file: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("MP3Builder.Build() chapter file = %s, expected to contain %s", content, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithUnknownChapterFile(t *testing.T) {
+	executor := NewReplayExecutor()
+	builder := NewMP3Builder(WithExecutor(executor), WithChapterFile(ChapterFileFormat(7)))
+	builder.streams = []segment{newTestSegment("a.mp3", 10)}
+
+	outputFilepath := filepath.Join(t.TempDir(), "out.mp3")
+	if err := builder.Build(outputFilepath); err == nil {
+		t.Fatal("MP3Builder.Build() expected error for unknown chapter file format")
+	}
+	if len(executor.Calls()) != 0 {
+		t.Errorf("MP3Builder.Build() ran %d commands before failing", len(executor.Calls()))
+	}
+	if _, err := os.Stat(outputFilepath); !os.IsNotExist(err) {
+		t.Errorf("MP3Builder.Build() expected no output file, got %v", err)
+	}
+}
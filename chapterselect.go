@@ -0,0 +1,118 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+)
+
+// gap in seconds up to which selected chapters count as contiguous
+const contiguousChapterTolerance = 0.001
+
+// Selects chapters of a file by their index, their title or a pattern
+// matching their title. A chapter is selected if it matches any of the
+// set criteria.
+type ChapterSelector struct {
+	// positions of the chapters ordered by start time, counting from 0
+	Indices []int
+	// exact titles of the chapters
+	Titles []string
+	// pattern matching the titles of the chapters
+	Pattern *regexp.Regexp
+}
+
+// Returns a selector for the chapters at the positions, counting from 0.
+func SelectChapterIndices(indices ...int) ChapterSelector {
+	return ChapterSelector{Indices: indices}
+}
+
+// Returns a selector for the chapters with exactly these titles.
+func SelectChapterTitles(titles ...string) ChapterSelector {
+	return ChapterSelector{Titles: titles}
+}
+
+// Returns a selector for the chapters whose title matches the regular expression.
+func SelectChapterPattern(pattern string) (ChapterSelector, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return ChapterSelector{}, err
+	}
+	return ChapterSelector{Pattern: compiled}, nil
+}
+
+func (s ChapterSelector) matches(index int, chapter Chapter) bool {
+	return slices.Contains(s.Indices, index) ||
+		slices.Contains(s.Titles, chapter.Tags.Title) ||
+		(s.Pattern != nil && s.Pattern.MatchString(chapter.Tags.Title))
+}
+
+// Appends the selected chapters of the file to the builder. Contiguous
+// chapters are appended as a single segment, every other gap between
+// selected chapters starts a new segment. The selected chapters are
+// kept in the output. Either all segments are appended or none.
+func (b *MP3Builder) AppendChapters(audioFilepath string, selector ChapterSelector) error {
+	return b.AppendChaptersContext(context.Background(), audioFilepath, selector)
+}
+
+func (b *MP3Builder) AppendChaptersContext(ctx context.Context, audioFilepath string, selector ChapterSelector) error {
+	probe, err := b.probeFile(ctx, audioFilepath)
+	if err != nil {
+		return err
+	}
+	ranges, err := getSelectedRanges(probe.chapters, selector)
+	if err != nil {
+		return fmt.Errorf("could not select chapters of '%s': %w", audioFilepath, err)
+	}
+	segments := make([]segment, 0, len(ranges))
+	for _, r := range ranges {
+		s, err := b.newProbedSegment(ctx, audioFilepath, probe, r.Start, r.End)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, s)
+	}
+	b.streams = append(b.streams, segments...)
+	return nil
+}
+
+// Returns the time ranges of the selected chapters in the order of
+// the file, contiguous chapters are merged into one range.
+func getSelectedRanges(chapters []Chapter, selector ChapterSelector) ([]SplitRange, error) {
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("file has no chapters")
+	}
+	sorted := append([]Chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetStartTimeInSeconds() < sorted[j].GetStartTimeInSeconds()
+	})
+	for _, index := range selector.Indices {
+		if index < 0 || index >= len(sorted) {
+			return nil, fmt.Errorf("no chapter %d of %d chapters", index, len(sorted))
+		}
+	}
+
+	for _, title := range selector.Titles {
+		if !slices.ContainsFunc(sorted, func(chapter Chapter) bool { return chapter.Tags.Title == title }) {
+			return nil, fmt.Errorf("no chapter with title '%s'", title)
+		}
+	}
+
+	result := make([]SplitRange, 0)
+	for i, chapter := range sorted {
+		if !selector.matches(i, chapter) {
+			continue
+		}
+		start, end := chapter.GetStartTimeInSeconds(), chapter.GetEndTimeInSeconds()
+		if last := len(result) - 1; last >= 0 && start-result[last].End <= contiguousChapterTolerance {
+			result[last].End = max(result[last].End, end)
+			continue
+		}
+		result = append(result, SplitRange{Start: start, End: end, Title: chapter.Tags.Title})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no chapter matches the selector")
+	}
+	return result, nil
+}
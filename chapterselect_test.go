@@ -0,0 +1,137 @@
+package mp3joiner
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGetSelectedRanges(t *testing.T) {
+	chapters := []Chapter{
+		{TimeBase: "1/1000", Start: 60000, End: 120000, Tags: Tags{Title: "Interview: Alice"}},
+		{TimeBase: "1/1000", Start: 0, End: 60000, Tags: Tags{Title: "Intro"}},
+		{TimeBase: "1/1000", Start: 120000, End: 180000, Tags: Tags{Title: "Interview: Bob"}},
+		{TimeBase: "1/1000", Start: 180000, End: 200000, Tags: Tags{Title: "Ads"}},
+		{TimeBase: "1/1000", Start: 200000, End: 260000, Tags: Tags{Title: "Interview: Carol"}},
+	}
+	tests := []struct {
+		name     string
+		selector ChapterSelector
+		want     []SplitRange
+		wantErr  bool
+	}{
+		{
+			name:     "indices",
+			selector: SelectChapterIndices(0, 4),
+			want:     []SplitRange{{Start: 0, End: 60, Title: "Intro"}, {Start: 200, End: 260, Title: "Interview: Carol"}},
+		}, {
+			name:     "contiguous titles",
+			selector: SelectChapterTitles("Interview: Bob", "Intro", "Interview: Alice"),
+			want:     []SplitRange{{Start: 0, End: 180, Title: "Intro"}},
+		}, {
+			name:     "pattern",
+			selector: ChapterSelector{Pattern: regexp.MustCompile(`^Interview`)},
+			want:     []SplitRange{{Start: 60, End: 180, Title: "Interview: Alice"}, {Start: 200, End: 260, Title: "Interview: Carol"}},
+		}, {
+			name:     "combined",
+			selector: ChapterSelector{Indices: []int{3}, Titles: []string{"Interview: Carol"}},
+			want:     []SplitRange{{Start: 180, End: 260, Title: "Ads"}},
+		}, {
+			name:     "index out of range",
+			selector: SelectChapterIndices(5),
+			wantErr:  true,
+		}, {
+			name:     "unknown title",
+			selector: SelectChapterTitles("Outro"),
+			wantErr:  true,
+		}, {
+			name:     "no match",
+			selector: ChapterSelector{Pattern: regexp.MustCompile(`Outro`)},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getSelectedRanges(chapters, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getSelectedRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("getSelectedRanges() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("getSelectedRanges() range %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := getSelectedRanges(nil, SelectChapterIndices(0)); err == nil {
+		t.Error("getSelectedRanges() expected error for file without chapters")
+	}
+}
+
+func TestSelectChapterPattern(t *testing.T) {
+	if _, err := SelectChapterPattern(`(`); err == nil {
+		t.Error("SelectChapterPattern() expected error for invalid pattern")
+	}
+	selector, err := SelectChapterPattern(`(?i)^chapter \d+$`)
+	if err != nil {
+		t.Fatalf("SelectChapterPattern() error = %v", err)
+	}
+	if !selector.matches(7, Chapter{Tags: Tags{Title: "Chapter 12"}}) || selector.matches(0, Chapter{Tags: Tags{Title: "Epilogue"}}) {
+		t.Error("SelectChapterPattern() selected the wrong chapters")
+	}
+}
+
+func TestMP3Builder_AppendChapters(t *testing.T) {
+	chapters := `[{"time_base":"1/1000","start":0,"end":60000,"tags":{"title":"Intro"}},` +
+		`{"time_base":"1/1000","start":60000,"end":120000,"tags":{"title":"Highlight"}},` +
+		`{"time_base":"1/1000","start":120000,"end":180000,"tags":{"title":"Chatter"}},` +
+		`{"time_base":"1/1000","start":180000,"end":240000,"tags":{"title":"Highlight 2"}}]`
+	// the file is probed once for all selected chapters
+	executor := NewReplayExecutor(appendExecutions("00:04:00.00", chapters, `{"bit_rate":"64000"}`, true)...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	selector, err := SelectChapterPattern(`^Highlight`)
+	if err != nil {
+		t.Fatalf("SelectChapterPattern() error = %v", err)
+	}
+	if err := builder.AppendChapters("episode.mp3", selector); err != nil {
+		t.Fatalf("MP3Builder.AppendChapters() error = %v", err)
+	}
+
+	if len(builder.streams) != 2 {
+		t.Fatalf("MP3Builder.AppendChapters() got %d segments, want 2", len(builder.streams))
+	}
+	if s := builder.streams[1]; s.Start != 180 || s.Duration != 60 {
+		t.Errorf("MP3Builder.AppendChapters() segment = %v - %v, want 180 - 240", s.Start, s.Start+s.Duration)
+	}
+	result := builder.getChapters()
+	if len(result) != 2 || result[0].Tags.Title != "Highlight" || result[1].Tags.Title != "Highlight 2" || result[1].GetStartTimeInSeconds() != 60 {
+		t.Errorf("MP3Builder.getChapters() = %+v", result)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("MP3Builder.AppendChapters() expected %d more executions", executor.Remaining())
+	}
+}
+
+func TestMP3Builder_AppendChaptersFailure(t *testing.T) {
+	chapters := `[{"time_base":"1/1000","start":0,"end":60000,"tags":{"title":"Intro"}},` +
+		`{"time_base":"1/1000","start":60000,"end":120000,"tags":{"title":"Chatter"}},` +
+		`{"time_base":"1/1000","start":120000,"end":180000,"tags":{"title":"Outro"}}]`
+	executions := appendExecutions("00:03:00.00", chapters, `{"bit_rate":"64000"}`, true)
+	executions = append(executions,
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: "silence_start: 0\nsilence_end: 1 | silence_duration: 1"}},
+		Execution{Name: "ffmpeg", Error: "trim failed"},
+	)
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithSilenceTrim(SilenceTrim{}))
+	if err := builder.AppendChapters("episode.mp3", SelectChapterTitles("Intro", "Outro")); err == nil {
+		t.Fatal("MP3Builder.AppendChapters() expected error")
+	}
+	if len(builder.streams) != 0 {
+		t.Errorf("MP3Builder.AppendChapters() expected no segments after an error, got %d", len(builder.streams))
+	}
+}
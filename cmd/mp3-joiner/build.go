@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	mp3joiner "github.com/jo-hoe/mp3-joiner"
+)
+
+func (c *command) build(args []string) error {
+	flagSet, asJSON := c.newFlagSet("build")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return newUsageError("expected exactly one manifest file")
+	}
+	if err := checkFilesExist(flagSet.Arg(0)); err != nil {
+		return err
+	}
+
+	manifest, err := mp3joiner.LoadManifest(flagSet.Arg(0))
+	if err != nil {
+		return usageError{message: err.Error()}
+	}
+	if err := mp3joiner.BuildFromManifestContext(c.ctx, manifest); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(joinResult{Output: manifest.Output.Path})
+	}
+	_, err = fmt.Fprintln(c.stdout, manifest.Output.Path)
+	return err
+}
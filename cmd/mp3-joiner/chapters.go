@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mp3joiner "github.com/jo-hoe/mp3-joiner"
+)
+
+// File format of chapters export and chapters import.
+type chapterFile struct {
+	Chapters         []mp3joiner.Chapter         `json:"chapters"`
+	TablesOfContents []mp3joiner.TableOfContents `json:"tables_of_contents,omitempty"`
+}
+
+func (c *command) chapters(args []string) error {
+	if len(args) == 0 {
+		return newUsageError("expected one of 'list', 'detect', 'export' or 'import'")
+	}
+	switch args[0] {
+	case "list":
+		return c.listChapters(args[1:])
+	case "detect":
+		return c.detectChapters(args[1:])
+	case "export":
+		return c.exportChapters(args[1:])
+	case "import":
+		return c.importChapters(args[1:])
+	default:
+		return newUsageError("unknown chapters command '%s'", args[0])
+	}
+}
+
+func (c *command) listChapters(args []string) error {
+	flagSet, asJSON := c.newFlagSet("chapters list")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return newUsageError("expected exactly one input file")
+	}
+
+	chapters, err := c.readChapters(flagSet.Arg(0))
+	if err != nil {
+		return err
+	}
+	return c.printChapters(chapters, *asJSON)
+}
+
+// Prints chapters detected by the silence between them.
+// The chapters can be written into the file with chapters import.
+func (c *command) detectChapters(args []string) error {
+	flagSet, asJSON := c.newFlagSet("chapters detect")
+	noiseFloor := flagSet.Float64("noise", 0, "level in dB below which audio counts as silence, defaults to -50")
+	minSilence := flagSet.Float64("min-silence", 0, "minimum seconds of silence between chapters, defaults to 2")
+	minChapter := flagSet.Float64("min-chapter", 0, "minimum seconds of a chapter")
+	title := flagSet.String("title", mp3joiner.DEFAULT_CHAPTER_TITLE_TEMPLATE, "template of the chapter titles")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return newUsageError("expected exactly one input file")
+	}
+	if err := checkFilesExist(flagSet.Arg(0)); err != nil {
+		return err
+	}
+
+	chapters, err := mp3joiner.DetectSilenceChaptersContext(c.ctx, flagSet.Arg(0), mp3joiner.SilenceDetection{
+		NoiseFloor:    *noiseFloor,
+		MinSilence:    *minSilence,
+		MinChapter:    *minChapter,
+		TitleTemplate: *title,
+	})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.printJSON(chapterFile{Chapters: chapters})
+	}
+	return c.printChapters(chapters, false)
+}
+
+func (c *command) printChapters(chapters []mp3joiner.Chapter, asJSON bool) error {
+	if asJSON {
+		return c.printJSON(chapters)
+	}
+	for _, chapter := range chapters {
+		if _, err := fmt.Fprintf(c.stdout, "%s - %s  %s\n",
+			formatTimestamp(chapter.GetStartTimeInSeconds()),
+			formatTimestamp(chapter.GetEndTimeInSeconds()),
+			chapter.Tags.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *command) exportChapters(args []string) error {
+	flagSet, _ := c.newFlagSet("chapters export")
+	output := flagSet.String("o", "", "path of the chapter file, defaults to stdout")
+	format := flagSet.String("format", "", "one of 'json', 'cue', 'podcast' or 'podlove', detected from the file extension if not set")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return newUsageError("expected exactly one input file")
+	}
+	chapterFormat, err := getChapterFormat(*format, *output)
+	if err != nil {
+		return err
+	}
+
+	chapters, tablesOfContents, err := mp3joiner.ReadID3Chapters(flagSet.Arg(0))
+	if err != nil || len(chapters) == 0 {
+		tablesOfContents = nil
+		if chapters, err = c.readChapters(flagSet.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	var buffer bytes.Buffer
+	switch chapterFormat {
+	case "cue":
+		editor, err := mp3joiner.NewTagEditor(flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+		sheet := &mp3joiner.CueSheet{File: flagSet.Arg(0), Metadata: editor.Metadata(), Chapters: chapters}
+		err = sheet.Encode(&buffer)
+	case "podcast":
+		err = mp3joiner.EncodePodcastChapters(&buffer, chapters, tablesOfContents)
+	case "podlove":
+		err = mp3joiner.EncodePodloveChapters(&buffer, chapters)
+	default:
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(chapterFile{Chapters: chapters, TablesOfContents: tablesOfContents})
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = c.stdout.Write(buffer.Bytes())
+		return err
+	}
+	return os.WriteFile(*output, buffer.Bytes(), 0644)
+}
+
+// Writes the chapters of the chapter file into the ID3v2 tag.
+// The FILE of CUE sheets is ignored, as the sheet may have been
+// created for another file with the same content.
+func (c *command) importChapters(args []string) error {
+	flagSet, _ := c.newFlagSet("chapters import")
+	format := flagSet.String("format", "", "one of 'json', 'cue', 'podcast' or 'podlove', detected from the file extension if not set")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 2 {
+		return newUsageError("expected an MP3 file and a chapter file")
+	}
+	chapterFormat, err := getChapterFormat(*format, flagSet.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := checkFilesExist(flagSet.Args()...); err != nil {
+		return err
+	}
+	// ID3v2 chapters would corrupt other containers
+	if _, err := mp3joiner.GetMP3Info(flagSet.Arg(0)); err != nil {
+		return newUsageError("chapters can only be imported into MP3 files: %v", err)
+	}
+
+	data, err := os.ReadFile(flagSet.Arg(1))
+	if err != nil {
+		return err
+	}
+	var input chapterFile
+	if chapterFormat == "json" {
+		err = json.Unmarshal(data, &input)
+	} else {
+		var length float64
+		if length, err = c.getLength(flagSet.Arg(0)); err != nil {
+			return err
+		}
+		input, err = decodeChapterFile(chapterFormat, data, length)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read chapter file '%s': %w", flagSet.Arg(1), err)
+	}
+	return mp3joiner.WriteID3Chapters(flagSet.Arg(0), input.Chapters, input.TablesOfContents)
+}
+
+func decodeChapterFile(format string, data []byte, lengthInSeconds float64) (result chapterFile, err error) {
+	switch format {
+	case "cue":
+		var sheet *mp3joiner.CueSheet
+		if sheet, err = mp3joiner.ParseCueSheet(bytes.NewReader(data), lengthInSeconds); err == nil {
+			result.Chapters = sheet.Chapters
+		}
+	case "podcast":
+		result.Chapters, result.TablesOfContents, err = mp3joiner.DecodePodcastChapters(bytes.NewReader(data), lengthInSeconds)
+	case "podlove":
+		result.Chapters, err = mp3joiner.DecodePodloveChapters(bytes.NewReader(data), lengthInSeconds)
+	}
+	return result, err
+}
+
+// Returns the given format or detects it from the extension of the file.
+func getChapterFormat(format string, path string) (string, error) {
+	switch format {
+	case "json", "cue", "podcast", "podlove":
+		return format, nil
+	case "":
+	default:
+		return "", newUsageError("unknown format '%s'", format)
+	}
+
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".cue"):
+		return "cue", nil
+	case strings.EqualFold(filepath.Ext(path), ".xml"):
+		return "podlove", nil
+	case strings.HasSuffix(strings.ToLower(path), ".chapters.json"):
+		return "podcast", nil
+	default:
+		return "json", nil
+	}
+}
+
+// Reads the chapters from the ID3v2 tag and falls back to
+// ffprobe if the tag has no chapters.
+func (c *command) readChapters(path string) ([]mp3joiner.Chapter, error) {
+	if chapters, _, err := mp3joiner.ReadID3Chapters(path); err == nil && len(chapters) > 0 {
+		return chapters, nil
+	}
+	if err := checkFilesExist(path); err != nil {
+		return nil, err
+	}
+	return mp3joiner.GetChapterMetadataContext(c.ctx, path)
+}
+
+// Formats seconds as hh:mm:ss.mmm.
+func formatTimestamp(seconds float64) string {
+	milliseconds := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, milliseconds%1000)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	mp3joiner "github.com/jo-hoe/mp3-joiner"
+)
+
+type fileInfo struct {
+	Path string `json:"path"`
+	// length in seconds
+	Length float64 `json:"length"`
+	// bitrate in bit/s
+	Bitrate    int                 `json:"bitrate"`
+	SampleRate int                 `json:"sample_rate,omitempty"`
+	Channels   int                 `json:"channels,omitempty"`
+	VBR        bool                `json:"vbr"`
+	Chapters   []mp3joiner.Chapter `json:"chapters"`
+}
+
+func (c *command) info(args []string) error {
+	flagSet, asJSON := c.newFlagSet("info")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return newUsageError("expected exactly one input file")
+	}
+
+	result, err := c.getFileInfo(flagSet.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(result)
+	}
+	if _, err := fmt.Fprintf(c.stdout, "path:        %s\nlength:      %s\nbitrate:     %d bit/s\n",
+		result.Path, formatTimestamp(result.Length), result.Bitrate); err != nil {
+		return err
+	}
+	if result.SampleRate > 0 {
+		if _, err := fmt.Fprintf(c.stdout, "sample rate: %d Hz\nchannels:    %d\nvbr:         %t\n",
+			result.SampleRate, result.Channels, result.VBR); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(c.stdout, "chapters:    %d\n", len(result.Chapters)); err != nil {
+		return err
+	}
+	for _, chapter := range result.Chapters {
+		if _, err := fmt.Fprintf(c.stdout, "  %s - %s  %s\n",
+			formatTimestamp(chapter.GetStartTimeInSeconds()),
+			formatTimestamp(chapter.GetEndTimeInSeconds()),
+			chapter.Tags.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the properties from the MP3 frames and only uses
+// ffmpeg if the file can not be read by the frame scanner.
+func (c *command) getFileInfo(path string) (result fileInfo, err error) {
+	result.Path = path
+	if err = checkFilesExist(path); err != nil {
+		return result, err
+	}
+	if mp3Info, err := mp3joiner.GetMP3Info(path); err == nil {
+		result.Length = mp3Info.Duration
+		result.Bitrate = mp3Info.Bitrate
+		result.SampleRate = mp3Info.SampleRate
+		result.Channels = mp3Info.Channels
+		result.VBR = mp3Info.VBR
+	} else {
+		if result.Length, err = mp3joiner.GetLengthInSecondsContext(c.ctx, path); err != nil {
+			return result, err
+		}
+		if result.Bitrate, err = mp3joiner.GetBitrateContext(c.ctx, path); err != nil {
+			return result, err
+		}
+	}
+
+	result.Chapters, err = c.readChapters(path)
+	return result, err
+}
+
+func (c *command) getLength(path string) (float64, error) {
+	if err := checkFilesExist(path); err != nil {
+		return 0, err
+	}
+	if mp3Info, err := mp3joiner.GetMP3Info(path); err == nil {
+		return mp3Info.Duration, nil
+	}
+	return mp3joiner.GetLengthInSecondsContext(c.ctx, path)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	mp3joiner "github.com/jo-hoe/mp3-joiner"
+)
+
+// Part of an input file, start and end are in seconds
+// and an end of -1 marks the end of the file.
+type inputRange struct {
+	path  string
+	start float64
+	end   float64
+}
+
+type joinResult struct {
+	Output   string              `json:"output"`
+	Chapters []mp3joiner.Chapter `json:"chapters,omitempty"`
+	// loudness before and after the normalization
+	Loudness []mp3joiner.LoudnessStats `json:"loudness,omitempty"`
+}
+
+func (c *command) join(args []string) error {
+	flagSet, asJSON := c.newFlagSet("join")
+	output := flagSet.String("o", "joined.mp3", "path of the output file")
+	mode := flagSet.String("mode", "reencode", "build mode, either 'reencode' or 'copy'")
+	showProgress := flagSet.Bool("progress", false, "print the progress to stderr")
+	coverArt := flagSet.String("cover", "", "image embedded as cover art")
+	crossfade := flagSet.Float64("crossfade", 0, "seconds each file is crossfaded into the next one")
+	fadeIn := flagSet.Float64("fade-in", 0, "seconds to fade in the start of the output")
+	fadeOut := flagSet.Float64("fade-out", 0, "seconds to fade out the end of the output")
+	trimSilence := flagSet.Bool("trim-silence", false, "trim silence at the start and the end of each file")
+	loudness := flagSet.Float64("loudnorm", 0, "integrated loudness in LUFS the output is normalized to, e.g. -16")
+	loudnessPerFile := flagSet.Bool("loudnorm-files", false, "normalize each file instead of the whole output")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() == 0 {
+		return newUsageError("no input files")
+	}
+
+	opts := make([]mp3joiner.Option, 0)
+	switch *mode {
+	case "reencode":
+		opts = append(opts, mp3joiner.WithBuildMode(mp3joiner.BuildModeReencode))
+	case "copy":
+		opts = append(opts, mp3joiner.WithBuildMode(mp3joiner.BuildModeStreamCopy))
+	default:
+		return newUsageError("unknown mode '%s'", *mode)
+	}
+	if *coverArt != "" {
+		if err := checkFilesExist(*coverArt); err != nil {
+			return err
+		}
+		opts = append(opts, mp3joiner.WithCoverArt(*coverArt))
+	}
+	opts = append(opts,
+		mp3joiner.WithCrossfade(mp3joiner.Fade{Duration: *crossfade}),
+		mp3joiner.WithFadeIn(mp3joiner.Fade{Duration: *fadeIn}),
+		mp3joiner.WithFadeOut(mp3joiner.Fade{Duration: *fadeOut}),
+	)
+	if *trimSilence {
+		opts = append(opts, mp3joiner.WithSilenceTrim(mp3joiner.SilenceTrim{Padding: 0.25}))
+	}
+	if *loudness != 0 || *loudnessPerFile {
+		target := mp3joiner.LoudnessTarget{IntegratedLoudness: *loudness}
+		if *loudnessPerFile {
+			target.Scope = mp3joiner.LoudnessScopeSegment
+		}
+		opts = append(opts, mp3joiner.WithLoudnessNormalization(target))
+	}
+	if *showProgress {
+		opts = append(opts, mp3joiner.WithProgress(func(p mp3joiner.Progress) {
+			fmt.Fprintf(c.stderr, "\r%5.1f%%", p.Percent)
+			if p.Done {
+				fmt.Fprintln(c.stderr)
+			}
+		}))
+	}
+
+	inputs := make([]inputRange, 0, flagSet.NArg())
+	for _, arg := range flagSet.Args() {
+		input, err := parseInputRange(arg)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input)
+		if err := checkFilesExist(input.path); err != nil {
+			return err
+		}
+	}
+
+	builder := mp3joiner.NewMP3Builder(opts...)
+	for _, input := range inputs {
+		if err := builder.AppendContext(c.ctx, input.path, input.start, input.end); err != nil {
+			return err
+		}
+	}
+	if err := builder.BuildContext(c.ctx, *output); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		chapters, err := c.readChapters(*output)
+		if err != nil {
+			return err
+		}
+		return c.printJSON(joinResult{Output: *output, Chapters: chapters, Loudness: builder.GetLoudnessStats()})
+	}
+	_, err := fmt.Fprintln(c.stdout, *output)
+	return err
+}
+
+// Parses arguments in the form FILE, FILE:START:END or FILE:START.
+// Times are given in seconds, an empty or negative end reads until
+// the end of the file. Paths which contain colons themselves, e.g.
+// on Windows, are kept as long as the suffix is not a time range.
+func parseInputRange(arg string) (inputRange, error) {
+	result := inputRange{path: arg, end: -1}
+
+	parts := strings.Split(arg, ":")
+	for _, count := range []int{2, 1} {
+		if len(parts) <= count {
+			continue
+		}
+		times := parts[len(parts)-count:]
+		start, startErr := parseSeconds(times[0], 0)
+		end := -1.0
+		var endErr error
+		if count == 2 {
+			end, endErr = parseSeconds(times[1], -1)
+		}
+		if startErr != nil || endErr != nil {
+			continue
+		}
+
+		if end < 0 {
+			end = -1
+		}
+		if end != -1 && start >= end {
+			return result, newUsageError("start %v set after end %v in '%s'", start, end, arg)
+		}
+		result.path = strings.Join(parts[:len(parts)-count], ":")
+		result.start = start
+		result.end = end
+		return result, nil
+	}
+	return result, nil
+}
+
+func parseSeconds(value string, defaultValue float64) (float64, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,172 @@
+// Command mp3-joiner joins, splits and inspects MP3 files.
+//
+// Usage:
+//
+//	mp3-joiner join [-o output.mp3|m4b|opus] [-mode reencode|copy] [-cover image.jpg]
+//	                [-crossfade SECONDS] [-fade-in SECONDS] [-fade-out SECONDS]
+//	                [-trim-silence] [-loudnorm LUFS] [-loudnorm-files] [-progress] FILE[:START:END]...
+//	mp3-joiner build MANIFEST
+//	mp3-joiner split [-dir folder] [-template name] [-range START:END]... [-keep-chapters] FILE
+//	mp3-joiner chapters list FILE
+//	mp3-joiner chapters detect [-noise DB] [-min-silence SECONDS] [-min-chapter SECONDS] [-title template] FILE
+//	mp3-joiner chapters export [-o chapters.json] [-format json|cue|podcast|podlove] FILE
+//	mp3-joiner chapters import [-format json|cue|podcast|podlove] FILE chapters.json
+//	mp3-joiner tags get FILE [KEY]...
+//	mp3-joiner tags set FILE KEY=VALUE...
+//	mp3-joiner info FILE
+//
+// All subcommands accept -json to print the result as JSON.
+//
+// Exit codes:
+//
+//	0   success
+//	1   the operation failed
+//	2   invalid arguments
+//	3   an input file does not exist
+//	130 interrupted
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+)
+
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitInterrupted = 130
+)
+
+// Returned for invalid arguments, results in exit code 2.
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+func newUsageError(format string, a ...any) error {
+	return usageError{message: fmt.Sprintf(format, a...)}
+}
+
+// Context passed to all subcommands.
+type command struct {
+	ctx    context.Context
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var subcommands = map[string]func(c *command, args []string) error{
+	"join":     (*command).join,
+	"build":    (*command).build,
+	"split":    (*command).split,
+	"chapters": (*command).chapters,
+	"tags":     (*command).tags,
+	"info":     (*command).info,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Runs the subcommand given in args and returns the exit code.
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	c := &command{ctx: ctx, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	subcommand, ok := subcommands[args[0]]
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+			printUsage(stdout)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "unknown command '%s'\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	err := subcommand(c, args[1:])
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "mp3-joiner %s: %v\n", args[0], err)
+	return getExitCode(ctx, err)
+}
+
+func getExitCode(ctx context.Context, err error) int {
+	var usageErr usageError
+	switch {
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case ctx.Err() != nil:
+		return exitInterrupted
+	case errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	default:
+		return exitFailure
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `usage: mp3-joiner <command> [arguments]
+
+commands:
+  join      join files or parts of files, e.g. intro.mp3 book.mp3:60:-1
+  build     join the files described by a JSON or YAML manifest
+  split     split a file by its chapters or by ranges
+  chapters  list, export or import chapters
+  tags      get or set tags
+  info      print length, bitrate and chapters
+
+run 'mp3-joiner <command> -h' for the arguments of a command
+`)
+}
+
+// Creates a flag set with the -json flag all subcommands share.
+// Parse errors are returned as usage errors.
+func (c *command) newFlagSet(name string) (*flag.FlagSet, *bool) {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	flagSet.SetOutput(c.stderr)
+	return flagSet, flagSet.Bool("json", false, "print the result as JSON")
+}
+
+func parseFlags(flagSet *flag.FlagSet, args []string) error {
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{message: err.Error()}
+	}
+	return nil
+}
+
+func (c *command) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// Fails early for missing inputs, as ffmpeg would only report
+// them as a generic error.
+func checkFilesExist(paths ...string) error {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
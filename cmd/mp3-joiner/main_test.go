@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_run(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{
+			name: "no command",
+			args: []string{},
+			want: exitUsage,
+		}, {
+			name: "unknown command",
+			args: []string{"unknown"},
+			want: exitUsage,
+		}, {
+			name: "help",
+			args: []string{"help"},
+			want: exitOK,
+		}, {
+			name: "unknown flag",
+			args: []string{"info", "-unknown", "file.mp3"},
+			want: exitUsage,
+		}, {
+			name: "missing argument",
+			args: []string{"chapters", "list"},
+			want: exitUsage,
+		}, {
+			name: "missing detect file",
+			args: []string{"chapters", "detect", "-min-silence", "3", filepath.Join(t.TempDir(), "missing.mp3")},
+			want: exitNotFound,
+		}, {
+			name: "missing file",
+			args: []string{"info", filepath.Join(t.TempDir(), "missing.mp3")},
+			want: exitNotFound,
+		}, {
+			name: "invalid manifest",
+			args: []string{"build", createTestManifest(t, "version: 1\nunknown: true\n")},
+			want: exitUsage,
+		}, {
+			name: "invalid range",
+			args: []string{"join", "file.mp3:20:10"},
+			want: exitUsage,
+		}, {
+			name: "import chapters into non-MP3 file",
+			args: []string{"chapters", "import", createTestFile(t, "audio.flac", "fLaC and some audio"), createTestFile(t, "chapters.json", `{"chapters":[]}`)},
+			want: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(context.Background(), tt.args, &bytes.Buffer{}, &bytes.Buffer{}); got != tt.want {
+				t.Errorf("run() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runTags(t *testing.T) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("tags are read with ffprobe")
+	}
+	mp3Filepath := createTestMP3File(t)
+
+	runSuccessfully(t, "tags", "set", mp3Filepath, "title=My Title", "artist=Me")
+	runSuccessfully(t, "tags", "set", mp3Filepath, "artist=")
+
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(runSuccessfully(t, "tags", "get", "-json", mp3Filepath, "title", "artist")), &tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags["title"] != "My Title" {
+		t.Errorf("tags get = %v, want only the title", tags)
+	}
+}
+
+func Test_runChapters(t *testing.T) {
+	mp3Filepath := createTestMP3File(t)
+	chapterFilepath := createTestFile(t, "chapters.json", `{"chapters":[
+		{"time_base":"1/1000","start":0,"end":100,"tags":{"title":"Intro"}},
+		{"time_base":"1/1000","start":100,"end":261,"tags":{"title":"Outro"}}]}`)
+
+	runSuccessfully(t, "chapters", "import", mp3Filepath, chapterFilepath)
+
+	var info fileInfo
+	if err := json.Unmarshal([]byte(runSuccessfully(t, "info", "-json", mp3Filepath)), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Length == 0 || info.SampleRate != 44100 || len(info.Chapters) != 2 {
+		t.Errorf("info = %+v", info)
+	}
+
+	cueFilepath := filepath.Join(t.TempDir(), "chapters.cue")
+	runSuccessfully(t, "chapters", "export", "-o", cueFilepath, mp3Filepath)
+	runSuccessfully(t, "chapters", "import", mp3Filepath, cueFilepath)
+	podcastFilepath := filepath.Join(t.TempDir(), "episode.chapters.json")
+	runSuccessfully(t, "chapters", "export", "-o", podcastFilepath, mp3Filepath)
+	runSuccessfully(t, "chapters", "import", mp3Filepath, podcastFilepath)
+	podloveChapters := runSuccessfully(t, "chapters", "export", "-format", "podlove", mp3Filepath)
+	if want := `<chapter start="00:00:00.107" title="Outro"></chapter>`; !strings.Contains(podloveChapters, want) {
+		t.Errorf("chapters export = %s, expected to contain %s", podloveChapters, want)
+	}
+
+	listed := runSuccessfully(t, "chapters", "list", mp3Filepath)
+	if want := "00:00:00.107 - 00:00:00.267  Outro"; !strings.Contains(listed, want) {
+		t.Errorf("chapters list = %s, expected to contain %s", listed, want)
+	}
+}
+
+func Test_parseInputRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    inputRange
+		wantErr bool
+	}{
+		{
+			name: "file only",
+			arg:  "book.mp3",
+			want: inputRange{path: "book.mp3", start: 0, end: -1},
+		}, {
+			name: "start and end",
+			arg:  "book.mp3:1.5:20",
+			want: inputRange{path: "book.mp3", start: 1.5, end: 20},
+		}, {
+			name: "start only",
+			arg:  "book.mp3:60",
+			want: inputRange{path: "book.mp3", start: 60, end: -1},
+		}, {
+			name: "open end",
+			arg:  "book.mp3:60:",
+			want: inputRange{path: "book.mp3", start: 60, end: -1},
+		}, {
+			name: "windows path",
+			arg:  `C:\audio\book.mp3`,
+			want: inputRange{path: `C:\audio\book.mp3`, start: 0, end: -1},
+		}, {
+			name: "windows path with range",
+			arg:  `C:\audio\book.mp3:5:10`,
+			want: inputRange{path: `C:\audio\book.mp3`, start: 5, end: 10},
+		}, {
+			name:    "inverted range",
+			arg:     "book.mp3:10:5",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInputRange(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInputRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseInputRange() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func runSuccessfully(t *testing.T, args ...string) string {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if code := run(context.Background(), args, stdout, stderr); code != exitOK {
+		t.Fatalf("run(%v) = %d, stderr: %s", args, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+// Creates a file of 10 silent MPEG-1 Layer III frames
+// with 128 kbit/s at 44.1 kHz.
+func createTestMP3File(t *testing.T) string {
+	const frameLength = 417
+	data := make([]byte, 0, 10*frameLength)
+	for i := 0; i < 10; i++ {
+		frame := make([]byte, frameLength)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		data = append(data, frame...)
+	}
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func createTestManifest(t *testing.T, content string) string {
+	return createTestFile(t, "manifest.yaml", content)
+}
+
+func createTestFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
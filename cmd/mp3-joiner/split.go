@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	mp3joiner "github.com/jo-hoe/mp3-joiner"
+)
+
+type splitResult struct {
+	Files []string `json:"files"`
+}
+
+func (c *command) split(args []string) error {
+	flagSet, asJSON := c.newFlagSet("split")
+	splitOptions := mp3joiner.SplitOptions{}
+	flagSet.StringVar(&splitOptions.OutputDir, "dir", "", "folder for the created files, defaults to the folder of the input file")
+	flagSet.StringVar(&splitOptions.NameTemplate, "template", mp3joiner.DEFAULT_SPLIT_NAME_TEMPLATE, "template for the file names")
+	flagSet.BoolVar(&splitOptions.KeepChapters, "keep-chapters", false, "keep the chapters within each created file")
+	flagSet.Func("range", "range START:END in seconds to cut instead of the chapters, can be repeated", func(value string) error {
+		splitRange, err := parseSplitRange(value)
+		if err != nil {
+			return err
+		}
+		splitOptions.Ranges = append(splitOptions.Ranges, splitRange)
+		return nil
+	})
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return newUsageError("expected exactly one input file")
+	}
+	if err := checkFilesExist(flagSet.Arg(0)); err != nil {
+		return err
+	}
+
+	files, err := mp3joiner.SplitContext(c.ctx, flagSet.Arg(0), splitOptions)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(splitResult{Files: files})
+	}
+	_, err = fmt.Fprintln(c.stdout, strings.Join(files, "\n"))
+	return err
+}
+
+func parseSplitRange(value string) (result mp3joiner.SplitRange, err error) {
+	start, end, found := strings.Cut(value, ":")
+	if !found {
+		return result, fmt.Errorf("expected START:END but got '%s'", value)
+	}
+	if result.Start, err = parseSeconds(start, 0); err != nil {
+		return result, err
+	}
+	if result.End, err = parseSeconds(end, -1); err != nil {
+		return result, err
+	}
+	if result.End < 0 {
+		result.End = -1
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	mp3joiner "github.com/jo-hoe/mp3-joiner"
+)
+
+func (c *command) tags(args []string) error {
+	if len(args) == 0 {
+		return newUsageError("expected one of 'get' or 'set'")
+	}
+	switch args[0] {
+	case "get":
+		return c.getTags(args[1:])
+	case "set":
+		return c.setTags(args[1:])
+	default:
+		return newUsageError("unknown tags command '%s'", args[0])
+	}
+}
+
+func (c *command) getTags(args []string) error {
+	flagSet, asJSON := c.newFlagSet("tags get")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() == 0 {
+		return newUsageError("expected an input file")
+	}
+
+	if err := checkFilesExist(flagSet.Arg(0)); err != nil {
+		return err
+	}
+	metadata, err := mp3joiner.GetFFmpegMetadataTagContext(c.ctx, flagSet.Arg(0))
+	if err != nil {
+		return err
+	}
+	if keys := flagSet.Args()[1:]; len(keys) > 0 {
+		selected := make(map[string]string, len(keys))
+		for _, key := range keys {
+			if value, ok := metadata[key]; ok {
+				selected[key] = value
+			}
+		}
+		metadata = selected
+	}
+
+	if *asJSON {
+		return c.printJSON(metadata)
+	}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(c.stdout, "%s=%s\n", key, metadata[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets the given KEY=VALUE pairs, an empty value removes the tag.
+func (c *command) setTags(args []string) error {
+	flagSet, asJSON := c.newFlagSet("tags set")
+	if err := parseFlags(flagSet, args); err != nil {
+		return err
+	}
+	if flagSet.NArg() < 2 {
+		return newUsageError("expected an input file and at least one KEY=VALUE pair")
+	}
+
+	pairs := flagSet.Args()[1:]
+	for _, pair := range pairs {
+		if key, _, found := strings.Cut(pair, "="); !found || key == "" {
+			return newUsageError("expected KEY=VALUE but got '%s'", pair)
+		}
+	}
+
+	path := flagSet.Arg(0)
+	if err := checkFilesExist(path); err != nil {
+		return err
+	}
+	metadata, err := mp3joiner.GetFFmpegMetadataTagContext(c.ctx, path)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		metadata = make(map[string]string, len(pairs))
+	}
+	for _, pair := range pairs {
+		key, value, _ := strings.Cut(pair, "=")
+		if value == "" {
+			delete(metadata, key)
+		} else {
+			metadata[key] = value
+		}
+	}
+	// the chapters are written again with the tags
+	chapters, err := c.readChapters(path)
+	if err != nil {
+		return err
+	}
+	if err := mp3joiner.SetFFmpegMetadataTagContext(c.ctx, path, metadata, chapters); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return c.printJSON(metadata)
+	}
+	return nil
+}
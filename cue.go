@@ -0,0 +1,322 @@
+package mp3joiner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CUE sheets express times in CD frames
+	cueFramesPerSecond = 75
+	cueTimeBase        = "1/75"
+	cueMaxTracks       = 99
+)
+
+// Maps CUE sheet commands to the metadata keys of GetFFmpegMetadataTag.
+// All other metadata is stored in REM comments.
+var cueCommandToMetadataKey = map[string]string{
+	"TITLE":      "title",
+	"PERFORMER":  "artist",
+	"SONGWRITER": "composer",
+	"CATALOG":    "catalog",
+}
+
+// Content of a CUE sheet referencing a single audio file.
+type CueSheet struct {
+	// audio file the chapters refer to
+	File string
+	// global tags using the keys of GetFFmpegMetadataTag
+	Metadata map[string]string
+	// one chapter per track, each chapter ends where the next starts
+	Chapters []Chapter
+}
+
+// Reads a CUE sheet. The path of the referenced audio file is
+// resolved against the folder of the sheet and its length is used
+// as end of the last chapter.
+func ReadCueSheet(cueFilepath string, opts ...Option) (*CueSheet, error) {
+	return ReadCueSheetContext(context.Background(), cueFilepath, opts...)
+}
+
+// Same as ReadCueSheet but stops ffmpeg once the context is done.
+func ReadCueSheetContext(ctx context.Context, cueFilepath string, opts ...Option) (result *CueSheet, err error) {
+	return readCueSheet(ctx, newOptions(opts), cueFilepath)
+}
+
+func readCueSheet(ctx context.Context, o *options, cueFilepath string) (result *CueSheet, err error) {
+	file, err := os.Open(cueFilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(file)
+
+	result, err = parseCueSheet(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read '%s': %w", cueFilepath, err)
+	}
+	if result.File == "" {
+		return nil, fmt.Errorf("'%s' does not reference an audio file", cueFilepath)
+	}
+	if !filepath.IsAbs(result.File) {
+		result.File = filepath.Join(filepath.Dir(cueFilepath), result.File)
+	}
+
+	var length float64
+	if info, infoErr := GetMP3Info(result.File); infoErr == nil {
+		length = info.Duration
+	} else if length, err = getLengthInSeconds(ctx, o, result.File); err != nil {
+		return nil, err
+	}
+	result.setLastChapterEnd(length)
+	return result, nil
+}
+
+// Parses a CUE sheet. As the length of the audio is not part of the
+// sheet, the given length in seconds is used as end of the last chapter.
+func ParseCueSheet(reader io.Reader, lengthInSeconds float64) (*CueSheet, error) {
+	result, err := parseCueSheet(reader)
+	if err != nil {
+		return nil, err
+	}
+	result.setLastChapterEnd(lengthInSeconds)
+	return result, nil
+}
+
+// Returns the path of the CUE sheet written next to the audio file.
+func getCueSheetPath(audioFilepath string) string {
+	return strings.TrimSuffix(audioFilepath, filepath.Ext(audioFilepath)) + ".cue"
+}
+
+// Writes the CUE sheet to the file.
+func WriteCueSheet(cueFilepath string, sheet *CueSheet) (err error) {
+	file, err := os.Create(cueFilepath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return sheet.Encode(file)
+}
+
+// Writes the sheet in the CUE format. Metadata without a matching
+// CUE command is written as REM comment.
+func (c *CueSheet) Encode(writer io.Writer) error {
+	if len(c.Chapters) > cueMaxTracks {
+		return fmt.Errorf("CUE sheets support at most %d tracks but got %d chapters", cueMaxTracks, len(c.Chapters))
+	}
+
+	var sb strings.Builder
+	keys := make([]string, 0, len(c.Metadata))
+	for key := range c.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if getCueCommand(key) == "" {
+			sb.WriteString(fmt.Sprintf("REM %s %s\n", strings.ToUpper(strings.ReplaceAll(key, " ", "_")), quoteCueValue(c.Metadata[key])))
+		}
+	}
+	for _, command := range []string{"CATALOG", "PERFORMER", "SONGWRITER", "TITLE"} {
+		if value := c.Metadata[cueCommandToMetadataKey[command]]; value != "" {
+			sb.WriteString(fmt.Sprintf("%s %s\n", command, quoteCueValue(value)))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("FILE %s %s\n", quoteCueValue(filepath.Base(c.File)), getCueFileType(c.File)))
+	for i, chapter := range c.Chapters {
+		sb.WriteString(fmt.Sprintf("  TRACK %02d AUDIO\n", i+1))
+		if chapter.Tags.Title != "" {
+			sb.WriteString(fmt.Sprintf("    TITLE %s\n", quoteCueValue(chapter.Tags.Title)))
+		}
+		if chapter.Tags.Artist != "" {
+			sb.WriteString(fmt.Sprintf("    PERFORMER %s\n", quoteCueValue(chapter.Tags.Artist)))
+		}
+		sb.WriteString(fmt.Sprintf("    INDEX 01 %s\n", formatCueTime(chapter.GetStartTimeInSeconds())))
+	}
+
+	_, err := io.WriteString(writer, sb.String())
+	return err
+}
+
+func parseCueSheet(reader io.Reader) (*CueSheet, error) {
+	result := &CueSheet{
+		Metadata: make(map[string]string),
+		Chapters: make([]Chapter, 0),
+	}
+	var track *Chapter
+	hasIndex := false
+	trackLine := 0
+
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		fields := splitCueLine(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		command := strings.ToUpper(fields[0])
+		arguments := fields[1:]
+		switch {
+		case command == "FILE":
+			if result.File != "" {
+				return nil, fmt.Errorf("line %d: only a single FILE is supported", lineNumber)
+			}
+			if len(arguments) == 0 {
+				return nil, fmt.Errorf("line %d: FILE without a name", lineNumber)
+			}
+			result.File = arguments[0]
+		case command == "TRACK":
+			if track != nil && !hasIndex {
+				return nil, fmt.Errorf("line %d: track has no INDEX 01", trackLine)
+			}
+			if track != nil {
+				result.Chapters = append(result.Chapters, *track)
+			}
+			track = &Chapter{TimeBase: cueTimeBase}
+			hasIndex = false
+			trackLine = lineNumber
+		case command == "INDEX" && track != nil:
+			if len(arguments) < 2 {
+				return nil, fmt.Errorf("line %d: INDEX requires a number and a time", lineNumber)
+			}
+			if number, err := strconv.Atoi(arguments[0]); err != nil || number != 1 {
+				// pregaps (INDEX 00) and sub-indexes are not chapters
+				continue
+			}
+			frames, err := parseCueTime(arguments[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			track.Start = frames
+			hasIndex = true
+		case (command == "TITLE" || command == "PERFORMER") && track != nil:
+			if len(arguments) > 0 && command == "TITLE" {
+				track.Tags.Title = arguments[0]
+			} else if len(arguments) > 0 {
+				track.Tags.Artist = arguments[0]
+			}
+		case command == "REM" && track == nil:
+			if len(arguments) > 1 {
+				result.Metadata[strings.ToLower(arguments[0])] = strings.Join(arguments[1:], " ")
+			}
+		case cueCommandToMetadataKey[command] != "" && track == nil:
+			if len(arguments) > 0 {
+				result.Metadata[cueCommandToMetadataKey[command]] = arguments[0]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if track != nil && !hasIndex {
+		return nil, fmt.Errorf("line %d: track has no INDEX 01", trackLine)
+	}
+	if track != nil {
+		result.Chapters = append(result.Chapters, *track)
+	}
+
+	sort.SliceStable(result.Chapters, func(i, j int) bool {
+		return result.Chapters[i].Start < result.Chapters[j].Start
+	})
+	for i := 0; i+1 < len(result.Chapters); i++ {
+		result.Chapters[i].End = result.Chapters[i+1].Start
+	}
+	return result, nil
+}
+
+func (c *CueSheet) setLastChapterEnd(lengthInSeconds float64) {
+	if len(c.Chapters) == 0 {
+		return
+	}
+	last := &c.Chapters[len(c.Chapters)-1]
+	last.End = max(last.Start, int(lengthInSeconds*cueFramesPerSecond+0.5))
+}
+
+// Splits a line into its command and arguments,
+// keeping quoted arguments together.
+func splitCueLine(line string) (result []string) {
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return append(result, line[1:])
+			}
+			result = append(result, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			return append(result, line)
+		}
+		result = append(result, line[:end])
+		line = line[end:]
+	}
+	return result
+}
+
+// Parses mm:ss:ff into CD frames.
+func parseCueTime(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time '%s', expected mm:ss:ff", value)
+	}
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("invalid time '%s', expected mm:ss:ff", value)
+		}
+		numbers[i] = number
+	}
+	if numbers[1] >= 60 || numbers[2] >= cueFramesPerSecond {
+		return 0, fmt.Errorf("invalid time '%s', expected mm:ss:ff", value)
+	}
+	return (numbers[0]*60+numbers[1])*cueFramesPerSecond + numbers[2], nil
+}
+
+// Formats seconds as mm:ss:ff, minutes may exceed 99.
+func formatCueTime(seconds float64) string {
+	frames := int(seconds*cueFramesPerSecond + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d",
+		frames/(60*cueFramesPerSecond), frames/cueFramesPerSecond%60, frames%cueFramesPerSecond)
+}
+
+func getCueCommand(metadataKey string) string {
+	for command, key := range cueCommandToMetadataKey {
+		if key == metadataKey {
+			return command
+		}
+	}
+	return ""
+}
+
+// CUE sheets have no escaping, so quotes within values are replaced.
+func quoteCueValue(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "'") + `"`
+}
+
+func getCueFileType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return "MP3"
+	case ".aif", ".aiff":
+		return "AIFF"
+	default:
+		return "WAVE"
+	}
+}
@@ -0,0 +1,170 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCueSheet = `REM GENRE Electronic
+REM DATE 2023
+PERFORMER "DJ Test"
+TITLE "Summer Mix"
+FILE "mix.mp3" MP3
+  TRACK 01 AUDIO
+    TITLE "Opening"
+    PERFORMER "Artist A"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Second Track"
+    INDEX 00 04:58:00
+    INDEX 01 05:00:37
+  TRACK 03 AUDIO
+    TITLE "Closing"
+    INDEX 01 62:10:74
+`
+
+func TestParseCueSheet(t *testing.T) {
+	sheet, err := ParseCueSheet(strings.NewReader("\uFEFF"+testCueSheet), 4000)
+	if err != nil {
+		t.Fatalf("ParseCueSheet() error = %v", err)
+	}
+
+	wantMetadata := map[string]string{"genre": "Electronic", "date": "2023", "artist": "DJ Test", "title": "Summer Mix"}
+	if !reflect.DeepEqual(sheet.Metadata, wantMetadata) {
+		t.Errorf("ParseCueSheet() metadata = %v, want %v", sheet.Metadata, wantMetadata)
+	}
+	if sheet.File != "mix.mp3" {
+		t.Errorf("ParseCueSheet() file = %v, want mix.mp3", sheet.File)
+	}
+
+	expected := []struct {
+		title  string
+		artist string
+		start  float64
+		end    float64
+	}{
+		{title: "Opening", artist: "Artist A", start: 0, end: 300 + 37.0/75},
+		{title: "Second Track", start: 300 + 37.0/75, end: 3730 + 74.0/75},
+		{title: "Closing", start: 3730 + 74.0/75, end: 4000},
+	}
+	if len(sheet.Chapters) != len(expected) {
+		t.Fatalf("ParseCueSheet() got %d chapters, want %d", len(sheet.Chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := sheet.Chapters[i]
+		if chapter.Tags.Title != want.title || chapter.Tags.Artist != want.artist ||
+			chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("ParseCueSheet() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+}
+
+func TestParseCueSheetErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing index",
+			content: "FILE \"a.mp3\" MP3\n  TRACK 01 AUDIO\n    TITLE \"A\"\n  TRACK 02 AUDIO\n    INDEX 01 00:01:00\n",
+			want:    "line 2",
+		}, {
+			name:    "invalid time",
+			content: "FILE \"a.mp3\" MP3\n  TRACK 01 AUDIO\n    INDEX 01 00:61:00\n",
+			want:    "line 3",
+		}, {
+			name:    "multiple files",
+			content: "FILE \"a.mp3\" MP3\nFILE \"b.mp3\" MP3\n",
+			want:    "line 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCueSheet(strings.NewReader(tt.content), 0)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseCueSheet() error = %v, expected to contain %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCueSheet_Encode(t *testing.T) {
+	sheet, err := ParseCueSheet(strings.NewReader(testCueSheet), 4000)
+	if err != nil {
+		t.Fatalf("ParseCueSheet() error = %v", err)
+	}
+	sheet.Metadata["comment"] = `say "hi"`
+
+	var buffer bytes.Buffer
+	if err = sheet.Encode(&buffer); err != nil {
+		t.Fatalf("CueSheet.Encode() error = %v", err)
+	}
+	for _, want := range []string{
+		"REM COMMENT \"say 'hi'\"\n",
+		"PERFORMER \"DJ Test\"\n",
+		"FILE \"mix.mp3\" MP3\n",
+		"  TRACK 02 AUDIO\n    TITLE \"Second Track\"\n    INDEX 01 05:00:37\n",
+		"    INDEX 01 62:10:74\n",
+	} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("CueSheet.Encode() = %s, expected to contain %s", buffer.String(), want)
+		}
+	}
+
+	sheet.Metadata["comment"] = "say 'hi'"
+	decoded, err := ParseCueSheet(&buffer, 4000)
+	if err != nil {
+		t.Fatalf("ParseCueSheet() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, sheet) {
+		t.Errorf("ParseCueSheet() = %+v, want %+v", decoded, sheet)
+	}
+}
+
+func TestMP3Builder_AppendCueSheet(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mix.mp3"), createTestFrames(testMPEG1FrameHeader, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content := "TITLE \"Mix\"\nFILE \"mix.mp3\" MP3\n  TRACK 01 AUDIO\n    TITLE \"A\"\n    INDEX 01 00:00:00\n  TRACK 02 AUDIO\n    TITLE \"B\"\n    INDEX 01 00:00:10\n"
+	cueFilepath := filepath.Join(dir, "mix.cue")
+	if err := os.WriteFile(cueFilepath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":[]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"format":{"tags":{"title":"demo","genre":"Mix"}}}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"bit_rate":"128000"}]}`}},
+		Execution{Name: "ffmpeg"},
+	)
+	builder := NewMP3Builder(WithExecutor(executor), WithCueSheet())
+	if err := builder.AppendCueSheet(cueFilepath); err != nil {
+		t.Fatalf("MP3Builder.AppendCueSheet() error = %v", err)
+	}
+
+	chapters := builder.getChapters()
+	if len(chapters) != 2 || chapters[1].Tags.Title != "B" || chapters[1].Start != toDefaultTimeBase(10.0/75) {
+		t.Errorf("MP3Builder.AppendCueSheet() chapters = %+v", chapters)
+	}
+	if builder.metaData["title"] != "Mix" || builder.metaData["genre"] != "Mix" {
+		t.Errorf("MP3Builder.AppendCueSheet() metadata = %v", builder.metaData)
+	}
+
+	outputFilepath := filepath.Join(dir, "out.mp3")
+	if err := builder.Build(outputFilepath); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+	sheet, err := os.ReadFile(filepath.Join(dir, "out.cue"))
+	if err != nil {
+		t.Fatalf("MP3Builder.Build() expected CUE sheet: %v", err)
+	}
+	if !strings.Contains(string(sheet), "FILE \"out.mp3\" MP3\n") || !strings.Contains(string(sheet), "TITLE \"B\"") {
+		t.Errorf("MP3Builder.Build() CUE sheet = %s", sheet)
+	}
+}
@@ -0,0 +1,203 @@
+package mp3joiner
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Defines how the encoder spends bits over time.
+type BitrateMode int
+
+const (
+	// Constant bitrate, every frame uses EncodeOptions.Bitrate.
+	BitrateModeCBR BitrateMode = iota
+	// Average bitrate, frames vary around EncodeOptions.Bitrate.
+	BitrateModeABR
+	// Variable bitrate, frames vary to keep EncodeOptions.Quality.
+	// Only supported for MP3 output, Opus output is always encoded
+	// with variable bitrate unless BitrateModeCBR is set.
+	BitrateModeVBR
+)
+
+// Defines the channels of the output.
+type ChannelMode int
+
+const (
+	// Keeps the channels of the input, at most two.
+	ChannelModeAuto ChannelMode = iota
+	ChannelModeMono
+	// Two independent channels.
+	ChannelModeStereo
+	// Two channels stored as sum and difference where it saves bits.
+	// Same as ChannelModeStereo for other than MP3 output.
+	ChannelModeJointStereo
+)
+
+const (
+	// highest quality of LAME in variable bitrate mode
+	minVBRQuality = 0
+	// lowest quality of LAME in variable bitrate mode
+	maxVBRQuality = 9
+	// bitrates in bit/s supported by libopus
+	minOpusBitrate = 6000
+	maxOpusBitrate = 510000
+)
+
+// sample rates in Hz supported by libopus
+var opusSampleRates = []int{8000, 12000, 16000, 24000, 48000}
+
+// Settings of the encoder used by MP3Builder.Build.
+// The zero value encodes with a constant bitrate, which is the
+// highest bitrate of all appended files, and keeps the sample
+// rate and channels of the first file.
+type EncodeOptions struct {
+	BitrateMode BitrateMode
+	// bitrate in bit/s used for BitrateModeCBR and BitrateModeABR.
+	// For MP3 output a constant bitrate has to be one of the bitrates
+	// of MPEG layer III at the encoded sample rate, e.g. 64000.
+	// By default the highest bitrate of all appended files is used.
+	Bitrate int
+	// quality used for BitrateModeVBR from 0 (best) to 9 (smallest),
+	// same as the -V option of LAME
+	Quality int
+	// sample rate in Hz, e.g. 22050. For MP3 output it has to be one
+	// of the sample rates of MPEG 1, 2 or 2.5. By default the sample
+	// rate of the input is used.
+	SampleRate  int
+	ChannelMode ChannelMode
+	// Omits the Xing/LAME tag of MP3 output, which stores the exact
+	// length and the encoder delay for gapless playback.
+	DisableLAMETag bool
+}
+
+// Checks the options against the capabilities of the output format.
+// The joined sample rate is the sample rate of the joined segments,
+// which is encoded if no sample rate is set, or 0 if it is unknown.
+func (e EncodeOptions) validate(format OutputFormat, joinedSampleRate int) error {
+	switch e.BitrateMode {
+	case BitrateModeCBR, BitrateModeABR:
+		if e.Bitrate < 0 {
+			return fmt.Errorf("invalid negative bitrate %d", e.Bitrate)
+		}
+	case BitrateModeVBR:
+		if format != OutputFormatMP3 && format != OutputFormatOpus {
+			return fmt.Errorf("variable bitrate is not supported for %s output", format)
+		}
+		if format == OutputFormatMP3 && (e.Quality < minVBRQuality || e.Quality > maxVBRQuality) {
+			return fmt.Errorf("VBR quality %d is not between %d and %d", e.Quality, minVBRQuality, maxVBRQuality)
+		}
+	default:
+		return fmt.Errorf("unknown bitrate mode %d", e.BitrateMode)
+	}
+	if e.SampleRate < 0 {
+		return fmt.Errorf("invalid negative sample rate %d", e.SampleRate)
+	}
+	if e.ChannelMode < ChannelModeAuto || e.ChannelMode > ChannelModeJointStereo {
+		return fmt.Errorf("unknown channel mode %d", e.ChannelMode)
+	}
+
+	switch format {
+	case OutputFormatMP3:
+		return e.validateMPEG(joinedSampleRate)
+	case OutputFormatOpus:
+		if e.SampleRate > 0 && !slices.Contains(opusSampleRates, e.SampleRate) {
+			return fmt.Errorf("sample rate %d Hz is not supported by Opus, use one of %v", e.SampleRate, opusSampleRates)
+		}
+		if e.Bitrate > 0 && (e.Bitrate < minOpusBitrate || e.Bitrate > maxOpusBitrate) {
+			return fmt.Errorf("bitrate %d is not between %d and %d bit/s supported by Opus", e.Bitrate, minOpusBitrate, maxOpusBitrate)
+		}
+	}
+	return nil
+}
+
+// Checks bitrate and sample rate against the tables of MPEG layer III.
+// Without a sample rate the bitrate is checked at the joined sample
+// rate. Joined sample rates which are not supported by MP3 are
+// resampled by the encoder, so the bitrate has to be one of MPEG 1.
+func (e EncodeOptions) validateMPEG(joinedSampleRate int) error {
+	sampleRate := e.SampleRate
+	if sampleRate > 0 {
+		if _, ok := getMPEGVersion(sampleRate); !ok {
+			return fmt.Errorf("sample rate %d Hz is not supported by MP3", sampleRate)
+		}
+	} else if _, ok := getMPEGVersion(joinedSampleRate); ok {
+		sampleRate = joinedSampleRate
+	}
+	if e.Bitrate == 0 || e.BitrateMode == BitrateModeVBR {
+		return nil
+	}
+
+	version := mpegVersion1
+	if sampleRate > 0 {
+		version, _ = getMPEGVersion(sampleRate)
+	}
+	// average bitrates only have to be in the range of the table
+	bitrates := getBitrateTable(version, mpegLayer3)
+	kbps := e.Bitrate / 1000
+	if e.BitrateMode == BitrateModeABR && kbps >= bitrates[1] && kbps <= bitrates[len(bitrates)-1] {
+		return nil
+	}
+	if e.Bitrate%1000 == 0 && slices.Contains(bitrates[1:], kbps) {
+		return nil
+	}
+	if sampleRate > 0 {
+		return fmt.Errorf("bitrate %d is not supported by MP3 at %d Hz", e.Bitrate, sampleRate)
+	}
+	return fmt.Errorf("bitrate %d is not supported by MPEG 1, set a sample rate of MPEG 2 or 2.5 to use it", e.Bitrate)
+}
+
+// Returns the MPEG version using the sample rate.
+func getMPEGVersion(sampleRate int) (int, bool) {
+	for version, sampleRates := range mpegSampleRates {
+		if slices.Contains(sampleRates, sampleRate) {
+			return version, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the ffmpeg arguments to set bitrate, sample rate and channels.
+// The bitrate has to be set, e.g. by MP3Builder.getEncodeOptions.
+func (e EncodeOptions) getArgs(format OutputFormat) []string {
+	result := make([]string, 0, 10)
+	switch {
+	case e.BitrateMode == BitrateModeVBR && format == OutputFormatMP3:
+		result = append(result, "-q:a", strconv.Itoa(e.Quality))
+	default:
+		result = append(result, "-b:a", fmt.Sprintf("%dk", e.Bitrate/1000))
+	}
+	if format == OutputFormatMP3 && e.BitrateMode == BitrateModeABR {
+		result = append(result, "-abr", "1")
+	}
+	if format == OutputFormatOpus {
+		switch e.BitrateMode {
+		case BitrateModeCBR:
+			result = append(result, "-vbr", "off")
+		case BitrateModeABR:
+			result = append(result, "-vbr", "constrained")
+		}
+	}
+
+	if e.SampleRate > 0 {
+		result = append(result, "-ar", strconv.Itoa(e.SampleRate))
+	}
+	switch e.ChannelMode {
+	case ChannelModeMono:
+		result = append(result, "-ac", "1")
+	case ChannelModeStereo, ChannelModeJointStereo:
+		result = append(result, "-ac", "2")
+	}
+	if format == OutputFormatMP3 {
+		switch e.ChannelMode {
+		case ChannelModeStereo:
+			result = append(result, "-joint_stereo", "0")
+		case ChannelModeJointStereo:
+			result = append(result, "-joint_stereo", "1")
+		}
+		if e.DisableLAMETag {
+			result = append(result, "-write_xing", "0")
+		}
+	}
+	return result
+}
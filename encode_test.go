@@ -0,0 +1,139 @@
+package mp3joiner
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEncodeOptions_validate(t *testing.T) {
+	tests := []struct {
+		name             string
+		settings         EncodeOptions
+		format           OutputFormat
+		joinedSampleRate int
+		wantErr          bool
+	}{
+		{name: "default", settings: EncodeOptions{}, format: OutputFormatMP3},
+		{name: "speech", settings: EncodeOptions{BitrateMode: BitrateModeVBR, Quality: 7, SampleRate: 22050, ChannelMode: ChannelModeMono}, format: OutputFormatMP3},
+		{name: "mpeg1 bitrate", settings: EncodeOptions{Bitrate: 320000, SampleRate: 44100}, format: OutputFormatMP3},
+		{name: "mpeg2 bitrate", settings: EncodeOptions{Bitrate: 8000}, format: OutputFormatMP3, joinedSampleRate: 16000},
+		{name: "mpeg2 bitrate at joined mpeg1 sample rate", settings: EncodeOptions{Bitrate: 8000}, format: OutputFormatMP3, joinedSampleRate: 44100, wantErr: true},
+		{name: "mpeg2 bitrate at unknown sample rate", settings: EncodeOptions{Bitrate: 144000}, format: OutputFormatMP3, wantErr: true},
+		{name: "mpeg1 bitrate at resampled sample rate", settings: EncodeOptions{Bitrate: 320000}, format: OutputFormatMP3, joinedSampleRate: 96000},
+		{name: "sample rate overrides joined sample rate", settings: EncodeOptions{Bitrate: 8000, SampleRate: 8000}, format: OutputFormatMP3, joinedSampleRate: 44100},
+		{name: "mpeg2 bitrate at mpeg1 sample rate", settings: EncodeOptions{Bitrate: 8000, SampleRate: 48000}, format: OutputFormatMP3, wantErr: true},
+		{name: "mpeg1 bitrate at mpeg2 sample rate", settings: EncodeOptions{Bitrate: 320000, SampleRate: 24000}, format: OutputFormatMP3, wantErr: true},
+		{name: "bitrate not in table", settings: EncodeOptions{Bitrate: 100000}, format: OutputFormatMP3, wantErr: true},
+		{name: "average bitrate not in table", settings: EncodeOptions{BitrateMode: BitrateModeABR, Bitrate: 100000, SampleRate: 44100}, format: OutputFormatMP3},
+		{name: "average bitrate too high", settings: EncodeOptions{BitrateMode: BitrateModeABR, Bitrate: 200000, SampleRate: 16000}, format: OutputFormatMP3, wantErr: true},
+		{name: "invalid sample rate", settings: EncodeOptions{SampleRate: 96000}, format: OutputFormatMP3, wantErr: true},
+		{name: "invalid quality", settings: EncodeOptions{BitrateMode: BitrateModeVBR, Quality: 10}, format: OutputFormatMP3, wantErr: true},
+		{name: "unknown bitrate mode", settings: EncodeOptions{BitrateMode: 5}, format: OutputFormatMP3, wantErr: true},
+		{name: "unknown channel mode", settings: EncodeOptions{ChannelMode: 7}, format: OutputFormatMP3, wantErr: true},
+		{name: "aac", settings: EncodeOptions{Bitrate: 100000, SampleRate: 96000}, format: OutputFormatM4B},
+		{name: "aac variable bitrate", settings: EncodeOptions{BitrateMode: BitrateModeVBR}, format: OutputFormatM4B, wantErr: true},
+		{name: "opus", settings: EncodeOptions{BitrateMode: BitrateModeVBR, Bitrate: 24000, SampleRate: 48000}, format: OutputFormatOpus},
+		{name: "opus sample rate", settings: EncodeOptions{SampleRate: 44100}, format: OutputFormatOpus, wantErr: true},
+		{name: "opus bitrate", settings: EncodeOptions{Bitrate: 4000}, format: OutputFormatOpus, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.validate(tt.format, tt.joinedSampleRate); (err != nil) != tt.wantErr {
+				t.Errorf("EncodeOptions.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeOptions_getArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings EncodeOptions
+		format   OutputFormat
+		want     []string
+	}{
+		{
+			name:     "constant bitrate",
+			settings: EncodeOptions{Bitrate: 128000},
+			format:   OutputFormatMP3,
+			want:     []string{"-b:a", "128k"},
+		}, {
+			name:     "variable bitrate mono",
+			settings: EncodeOptions{BitrateMode: BitrateModeVBR, Quality: 6, Bitrate: 128000, ChannelMode: ChannelModeMono},
+			format:   OutputFormatMP3,
+			want:     []string{"-q:a", "6", "-ac", "1"},
+		}, {
+			name:     "average bitrate stereo",
+			settings: EncodeOptions{BitrateMode: BitrateModeABR, Bitrate: 96000, SampleRate: 32000, ChannelMode: ChannelModeStereo, DisableLAMETag: true},
+			format:   OutputFormatMP3,
+			want:     []string{"-b:a", "96k", "-abr", "1", "-ar", "32000", "-ac", "2", "-joint_stereo", "0", "-write_xing", "0"},
+		}, {
+			name:     "joint stereo aac",
+			settings: EncodeOptions{Bitrate: 64000, ChannelMode: ChannelModeJointStereo, DisableLAMETag: true},
+			format:   OutputFormatM4B,
+			want:     []string{"-b:a", "64k", "-ac", "2"},
+		}, {
+			name:     "constant bitrate opus",
+			settings: EncodeOptions{Bitrate: 32000},
+			format:   OutputFormatOpus,
+			want:     []string{"-b:a", "32k", "-vbr", "off"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.getArgs(tt.format); !slices.Equal(got, tt.want) {
+				t.Errorf("EncodeOptions.getArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithEncodeOptions(t *testing.T) {
+	settings := EncodeOptions{BitrateMode: BitrateModeVBR, Quality: 7, SampleRate: 22050, ChannelMode: ChannelModeMono}
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "speech",
+			opts: []Option{WithEncodeOptions(settings)},
+			want: []string{"-c:a", "libmp3lame", "-q:a", "7", "-ar", "22050", "-ac", "1"},
+		}, {
+			name: "bitrate after encode options",
+			opts: []Option{WithEncodeOptions(EncodeOptions{SampleRate: 22050}), WithBitrate(64000)},
+			want: []string{"-c:a", "libmp3lame", "-b:a", "64k", "-ar", "22050"},
+		}, {
+			name:    "invalid encode options",
+			opts:    []Option{WithEncodeOptions(EncodeOptions{Bitrate: 192000, SampleRate: 11025})},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executions := appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"320000"}`, true)
+			executor := NewReplayExecutor(append(executions, Execution{Name: "ffmpeg"})...)
+			builder := NewMP3Builder(append(tt.opts, WithExecutor(executor))...)
+			if err := builder.Append("first.mp3", 0, -1); err != nil {
+				t.Fatalf("MP3Builder.Append() error = %v", err)
+			}
+
+			err := builder.Build(filepath.Join(t.TempDir(), "out.mp3"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MP3Builder.Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if executor.Remaining() != 1 {
+					t.Errorf("MP3Builder.Build() expected ffmpeg not to run")
+				}
+				return
+			}
+			calls := executor.Calls()
+			if args := calls[len(calls)-1].Args; !containsSequence(args, tt.want) {
+				t.Errorf("MP3Builder.Build() args = %v, expected to contain %v", args, tt.want)
+			}
+		})
+	}
+}
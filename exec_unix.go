@@ -0,0 +1,18 @@
+//go:build !windows
+
+package mp3joiner
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Starts the program in its own process group and kills
+// the whole group on cancellation, so that child processes
+// do not outlive the program.
+func configureProcessTreeKill(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
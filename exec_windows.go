@@ -0,0 +1,16 @@
+//go:build windows
+
+package mp3joiner
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// Kills the program including all of its child processes
+// on cancellation.
+func configureProcessTreeKill(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
@@ -0,0 +1,109 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// Time granted to a cancelled process to release its output
+// before the executor stops waiting for it.
+const cancelWaitDelay = 5 * time.Second
+
+// Executor runs external programs such as ffmpeg and ffprobe.
+// Implementations can be used to route the calls of this library
+// through a sandbox or to replace them with canned results in tests.
+type Executor interface {
+	// Runs the program with the given arguments. A non-zero exit code
+	// is reported via the result. The returned error is reserved for
+	// failures to run the program at all and for a done context.
+	// Implementations must stop the program once the context is done.
+	Run(ctx context.Context, name string, args ...string) (ExecResult, error)
+}
+
+// Executor which can additionally forward the standard output
+// of a program while it is still running. Executors which do not
+// implement this interface receive the complete output at once
+// after the program has exited.
+type StreamingExecutor interface {
+	Executor
+	// Same as Run but additionally writes the standard output to
+	// the writer as soon as it is available.
+	RunStreaming(ctx context.Context, stdout io.Writer, name string, args ...string) (ExecResult, error)
+}
+
+// Output of a single program run.
+type ExecResult struct {
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+}
+
+// Executor that runs programs on the local system via os/exec.
+// This is the default executor. On cancellation the complete
+// process tree of the program is killed.
+type CommandExecutor struct{}
+
+func (e CommandExecutor) Run(ctx context.Context, name string, args ...string) (result ExecResult, err error) {
+	return e.RunStreaming(ctx, io.Discard, name, args...)
+}
+
+func (CommandExecutor) RunStreaming(ctx context.Context, stdoutWriter io.Writer, name string, args ...string) (result ExecResult, err error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdout, stdoutWriter)
+	cmd.Stderr = &stderr
+	configureProcessTreeKill(cmd)
+	cmd.WaitDelay = cancelWaitDelay
+	err = cmd.Run()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return result, ctxErr
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		err = nil
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result, err
+}
+
+// Runs the command and turns a non-zero exit code into an error.
+func runCmd(ctx context.Context, executor Executor, name string, args ...string) (ExecResult, error) {
+	result, err := executor.Run(ctx, name, args...)
+	return result, checkResult(name, result, err)
+}
+
+// Same as runCmd but forwards the standard output to the writer.
+// If the executor can not stream, the output is written after
+// the program has exited.
+func runCmdStreaming(ctx context.Context, executor Executor, stdout io.Writer, name string, args ...string) (ExecResult, error) {
+	streamingExecutor, ok := executor.(StreamingExecutor)
+	if ok {
+		result, err := streamingExecutor.RunStreaming(ctx, stdout, name, args...)
+		return result, checkResult(name, result, err)
+	}
+
+	result, err := executor.Run(ctx, name, args...)
+	if err == nil {
+		_, err = io.WriteString(stdout, result.Stdout)
+	}
+	return result, checkResult(name, result, err)
+}
+
+func checkResult(name string, result ExecResult, err error) error {
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s exited with code %d", name, result.ExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package mp3joiner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandExecutor_Run(t *testing.T) {
+	type args struct {
+		name string
+		args []string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantExitCode int
+		wantErr      bool
+	}{
+		{
+			name:         "positive test",
+			args:         args{name: "go", args: []string{"env", "GOOS"}},
+			wantExitCode: 0,
+			wantErr:      false,
+		}, {
+			name:         "non-zero exit code",
+			args:         args{name: "go", args: []string{"notacommand"}},
+			wantExitCode: 2,
+			wantErr:      false,
+		}, {
+			name:    "program not found",
+			args:    args{name: "not-existing-program"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CommandExecutor{}.Run(context.Background(), tt.args.name, tt.args.args...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CommandExecutor.Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.ExitCode != tt.wantExitCode {
+				t.Errorf("CommandExecutor.Run() exit code = %v, want %v", got.ExitCode, tt.wantExitCode)
+			}
+			if tt.wantExitCode != 0 && len(got.Stderr) == 0 {
+				t.Errorf("CommandExecutor.Run() expected output on stderr")
+			}
+		})
+	}
+}
+
+func Test_runCmd(t *testing.T) {
+	executor := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: "{}"}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stderr: "broken", ExitCode: 1}},
+	)
+
+	result, err := runCmd(context.Background(), executor, "ffprobe")
+	if err != nil || result.Stdout != "{}" {
+		t.Errorf("runCmd() = %v, %v", result, err)
+	}
+	result, err = runCmd(context.Background(), executor, "ffprobe")
+	if err == nil || !strings.Contains(err.Error(), "code 1") || result.Stderr != "broken" {
+		t.Errorf("runCmd() expected exit code error, found %v, %v", result, err)
+	}
+}
+
+func TestCommandExecutor_RunCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	t.Setenv("MP3JOINER_HELPER_PROCESS", "1")
+	start := time.Now()
+	_, err := CommandExecutor{}.Run(ctx, os.Args[0], "-test.run=TestHelperProcess")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CommandExecutor.Run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("CommandExecutor.Run() process was not stopped, took %v", elapsed)
+	}
+}
+
+// Not a real test, used as long running process by other tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("MP3JOINER_HELPER_PROCESS") != "1" {
+		return
+	}
+	time.Sleep(time.Minute)
+}
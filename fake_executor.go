@@ -0,0 +1,160 @@
+package mp3joiner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A single recorded program run.
+type Execution struct {
+	Name   string     `json:"name"`
+	Args   []string   `json:"args,omitempty"`
+	Result ExecResult `json:"result"`
+	// error returned by the executor, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Executor that forwards all runs to another executor and
+// records them. The recording can be saved and later be
+// replayed with a ReplayExecutor.
+type RecordingExecutor struct {
+	executor   Executor
+	mutex      sync.Mutex
+	executions []Execution
+}
+
+func NewRecordingExecutor(executor Executor) *RecordingExecutor {
+	return &RecordingExecutor{
+		executor:   executor,
+		executions: make([]Execution, 0),
+	}
+}
+
+func (r *RecordingExecutor) Run(ctx context.Context, name string, args ...string) (ExecResult, error) {
+	result, err := r.executor.Run(ctx, name, args...)
+	r.record(name, args, result, err)
+	return result, err
+}
+
+func (r *RecordingExecutor) RunStreaming(ctx context.Context, stdout io.Writer, name string, args ...string) (result ExecResult, err error) {
+	if streamingExecutor, ok := r.executor.(StreamingExecutor); ok {
+		result, err = streamingExecutor.RunStreaming(ctx, stdout, name, args...)
+	} else {
+		result, err = r.executor.Run(ctx, name, args...)
+		if err == nil {
+			_, err = io.WriteString(stdout, result.Stdout)
+		}
+	}
+	r.record(name, args, result, err)
+	return result, err
+}
+
+func (r *RecordingExecutor) record(name string, args []string, result ExecResult, err error) {
+	execution := Execution{
+		Name:   name,
+		Args:   append([]string(nil), args...),
+		Result: result,
+	}
+	if err != nil {
+		execution.Error = err.Error()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.executions = append(r.executions, execution)
+}
+
+// Returns a copy of all recorded runs.
+func (r *RecordingExecutor) Executions() []Execution {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Execution(nil), r.executions...)
+}
+
+// Writes all recorded runs as JSON.
+func (r *RecordingExecutor) Save(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Executions())
+}
+
+// Executor that replays recorded runs in order without
+// starting any program. Only the program name is checked,
+// as arguments typically contain generated temp file paths.
+// The actual arguments can be inspected via Calls.
+type ReplayExecutor struct {
+	mutex      sync.Mutex
+	executions []Execution
+	calls      []Execution
+}
+
+func NewReplayExecutor(executions ...Execution) *ReplayExecutor {
+	return &ReplayExecutor{
+		executions: executions,
+		calls:      make([]Execution, 0),
+	}
+}
+
+// Creates a replay executor from JSON written by RecordingExecutor.Save.
+func LoadReplayExecutor(reader io.Reader) (*ReplayExecutor, error) {
+	var executions []Execution
+	if err := json.NewDecoder(reader).Decode(&executions); err != nil {
+		return nil, err
+	}
+	return NewReplayExecutor(executions...), nil
+}
+
+func (r *ReplayExecutor) Run(ctx context.Context, name string, args ...string) (ExecResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ExecResult{}, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	position := len(r.calls)
+	r.calls = append(r.calls, Execution{Name: name, Args: append([]string(nil), args...)})
+	if position >= len(r.executions) {
+		return ExecResult{}, fmt.Errorf("no recorded execution left for %s", name)
+	}
+
+	execution := r.executions[position]
+	if execution.Name != name {
+		return ExecResult{}, fmt.Errorf("expected execution of %s, got %s", execution.Name, name)
+	}
+	r.calls[position].Result = execution.Result
+	r.calls[position].Error = execution.Error
+
+	if execution.Error != "" {
+		return execution.Result, errors.New(execution.Error)
+	}
+	return execution.Result, nil
+}
+
+// Replays the next recorded run and writes its standard output to the writer.
+func (r *ReplayExecutor) RunStreaming(ctx context.Context, stdout io.Writer, name string, args ...string) (ExecResult, error) {
+	result, err := r.Run(ctx, name, args...)
+	if err != nil {
+		return result, err
+	}
+	_, err = io.WriteString(stdout, result.Stdout)
+	return result, err
+}
+
+// Returns the runs requested so far including their actual arguments.
+func (r *ReplayExecutor) Calls() []Execution {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Execution(nil), r.calls...)
+}
+
+// Returns the number of recorded runs that have not been replayed yet.
+func (r *ReplayExecutor) Remaining() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return max(len(r.executions)-len(r.calls), 0)
+}
@@ -0,0 +1,86 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRecordingExecutor_Replay(t *testing.T) {
+	inner := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"bit_rate":"64000"}]}`}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: "failure", ExitCode: 1}},
+	)
+	recorder := NewRecordingExecutor(inner)
+
+	if _, err := recorder.Run(context.Background(), "ffprobe", "-i", "a.mp3"); err != nil {
+		t.Errorf("RecordingExecutor.Run() error = %v", err)
+	}
+	if _, err := recorder.Run(context.Background(), "ffmpeg", "-i", "b.mp3"); err != nil {
+		t.Errorf("RecordingExecutor.Run() error = %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := recorder.Save(&buffer); err != nil {
+		t.Fatalf("RecordingExecutor.Save() error = %v", err)
+	}
+	replay, err := LoadReplayExecutor(&buffer)
+	if err != nil {
+		t.Fatalf("LoadReplayExecutor() error = %v", err)
+	}
+
+	for _, execution := range recorder.Executions() {
+		result, err := replay.Run(context.Background(), execution.Name, execution.Args...)
+		if err != nil {
+			t.Errorf("ReplayExecutor.Run() error = %v", err)
+		}
+		if !reflect.DeepEqual(result, execution.Result) {
+			t.Errorf("ReplayExecutor.Run() = %v, want %v", result, execution.Result)
+		}
+	}
+	if replay.Remaining() != 0 {
+		t.Errorf("ReplayExecutor.Remaining() = %v, want 0", replay.Remaining())
+	}
+	if !reflect.DeepEqual(replay.Calls(), recorder.Executions()) {
+		t.Errorf("ReplayExecutor.Calls() = %v, want %v", replay.Calls(), recorder.Executions())
+	}
+}
+
+func TestReplayExecutor_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		executions []Execution
+		program    string
+		wantErr    bool
+	}{
+		{
+			name:       "positive test",
+			executions: []Execution{{Name: "ffmpeg"}},
+			program:    "ffmpeg",
+			wantErr:    false,
+		}, {
+			name:       "unexpected program",
+			executions: []Execution{{Name: "ffmpeg"}},
+			program:    "ffprobe",
+			wantErr:    true,
+		}, {
+			name:       "no recording left",
+			executions: []Execution{},
+			program:    "ffmpeg",
+			wantErr:    true,
+		}, {
+			name:       "recorded error",
+			executions: []Execution{{Name: "ffmpeg", Error: "not found"}},
+			program:    "ffmpeg",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewReplayExecutor(tt.executions...).Run(context.Background(), tt.program); (err != nil) != tt.wantErr {
+				t.Errorf("ReplayExecutor.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
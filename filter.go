@@ -0,0 +1,218 @@
+package mp3joiner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// limits of a single atempo filter in older ffmpeg versions
+	minTempoFactor = 0.5
+	maxTempoFactor = 2.0
+	// maximum noise reduction in dB of the afftdn filter
+	maxNoiseReduction = 97
+)
+
+// Audio filter applied to a single segment before it is joined,
+// see MP3Builder.SetFilters.
+type AudioFilter interface {
+	// Returns the ffmpeg filter, e.g. volume=3dB.
+	getFilter() string
+	validate() error
+}
+
+// Changes the volume of the segment by the gain in dB.
+type VolumeFilter struct {
+	Gain float64
+}
+
+// Changes the speed of the segment without changing its pitch.
+// A factor of 1.1 plays the segment 10% faster, which also moves
+// the chapters of the segment.
+type TempoFilter struct {
+	Factor float64
+}
+
+// Removes frequencies below the cutoff frequency in Hz, e.g. rumble.
+type HighPassFilter struct {
+	Frequency float64
+}
+
+// Removes frequencies above the cutoff frequency in Hz, e.g. hiss.
+type LowPassFilter struct {
+	Frequency float64
+}
+
+// Reorders the channels of the segment. Each output channel is
+// taken from the input channel at the same position, e.g. []int{1, 0}
+// swaps left and right and []int{0} keeps only the left channel.
+type ChannelMapFilter struct {
+	Channels []int
+}
+
+// Reduces stationary background noise by the reduction in dB,
+// 12 dB if 0.
+type DenoiseFilter struct {
+	Reduction float64
+}
+
+func (f VolumeFilter) getFilter() string {
+	return "volume=" + formatFilterValue(f.Gain) + "dB"
+}
+
+func (f VolumeFilter) validate() error {
+	return nil
+}
+
+// atempo is chained for factors beyond the limits of a single filter.
+func (f TempoFilter) getFilter() string {
+	filters := make([]string, 0, 1)
+	factor := f.Factor
+	for factor > maxTempoFactor {
+		filters = append(filters, "atempo="+formatFilterValue(maxTempoFactor))
+		factor /= maxTempoFactor
+	}
+	for factor < minTempoFactor {
+		filters = append(filters, "atempo="+formatFilterValue(minTempoFactor))
+		factor /= minTempoFactor
+	}
+	filters = append(filters, "atempo="+formatFilterValue(factor))
+	return strings.Join(filters, ",")
+}
+
+func (f TempoFilter) validate() error {
+	if f.Factor <= 0 {
+		return fmt.Errorf("tempo factor has to be positive but is %v", f.Factor)
+	}
+	return nil
+}
+
+func (f HighPassFilter) getFilter() string {
+	return "highpass=f=" + formatFilterValue(f.Frequency)
+}
+
+func (f HighPassFilter) validate() error {
+	return validateCutoffFrequency(f.Frequency)
+}
+
+func (f LowPassFilter) getFilter() string {
+	return "lowpass=f=" + formatFilterValue(f.Frequency)
+}
+
+func (f LowPassFilter) validate() error {
+	return validateCutoffFrequency(f.Frequency)
+}
+
+func (f ChannelMapFilter) getFilter() string {
+	channels := make([]string, 0, len(f.Channels))
+	for _, channel := range f.Channels {
+		channels = append(channels, strconv.Itoa(channel))
+	}
+	layout := "stereo"
+	if len(f.Channels) == 1 {
+		layout = "mono"
+	}
+	return fmt.Sprintf("channelmap=map=%s:channel_layout=%s", strings.Join(channels, "|"), layout)
+}
+
+func (f ChannelMapFilter) validate() error {
+	if len(f.Channels) < 1 || len(f.Channels) > 2 {
+		return fmt.Errorf("channel map needs one or two channels but has %d", len(f.Channels))
+	}
+	for _, channel := range f.Channels {
+		if channel < 0 {
+			return fmt.Errorf("invalid negative channel %d", channel)
+		}
+	}
+	return nil
+}
+
+// Checks that the mapped channels exist in the input.
+func (f ChannelMapFilter) validateInput(channels int) error {
+	for _, channel := range f.Channels {
+		if channels > 0 && channel >= channels {
+			return fmt.Errorf("channel %d does not exist in input with %d channels", channel, channels)
+		}
+	}
+	return nil
+}
+
+func (f DenoiseFilter) getFilter() string {
+	if f.Reduction == 0 {
+		return "afftdn"
+	}
+	return "afftdn=nr=" + formatFilterValue(f.Reduction)
+}
+
+func (f DenoiseFilter) validate() error {
+	if f.Reduction < 0 || f.Reduction > maxNoiseReduction {
+		return fmt.Errorf("noise reduction %v dB is not between 0 and %d", f.Reduction, maxNoiseReduction)
+	}
+	return nil
+}
+
+func validateCutoffFrequency(frequency float64) error {
+	if frequency <= 0 {
+		return fmt.Errorf("cutoff frequency has to be positive but is %v", frequency)
+	}
+	return nil
+}
+
+// Sets the filters of the segment at the index, counting appended
+// files from 0. The filters are applied in the given order and
+// replace previously set filters.
+func (b *MP3Builder) SetFilters(index int, filters ...AudioFilter) error {
+	if err := b.checkSegmentIndex(index); err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		if filter == nil {
+			return fmt.Errorf("filter of segment %d is nil", index)
+		}
+		if err := filter.validate(); err != nil {
+			return err
+		}
+		if channelMap, ok := filter.(ChannelMapFilter); ok {
+			if err := channelMap.validateInput(b.streams[index].Stream.Channels); err != nil {
+				return err
+			}
+		}
+	}
+	b.streams[index].Filters = append([]AudioFilter(nil), filters...)
+	return nil
+}
+
+func (b *MP3Builder) hasFilters() bool {
+	for _, s := range b.streams {
+		if len(s.Filters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the factor by which the tempo filters speed up the segment.
+func (s segment) getTempo() float64 {
+	result := 1.0
+	for _, filter := range s.Filters {
+		if tempo, ok := filter.(TempoFilter); ok {
+			result *= tempo.Factor
+		}
+	}
+	return result
+}
+
+// Returns the length of the segment in the output in seconds.
+func (s segment) getOutputDuration() float64 {
+	return s.Duration / s.getTempo()
+}
+
+// Returns the chapters of the segment relative to its start in the output.
+func (s segment) getOutputChapters() []Chapter {
+	return scaleChapters(s.Chapters, 1/s.getTempo())
+}
+
+func formatFilterValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,125 @@
+package mp3joiner
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestAudioFilter_getFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter AudioFilter
+		want   string
+	}{
+		{name: "volume", filter: VolumeFilter{Gain: 6}, want: "volume=6dB"},
+		{name: "negative volume", filter: VolumeFilter{Gain: -2.5}, want: "volume=-2.5dB"},
+		{name: "tempo", filter: TempoFilter{Factor: 1.1}, want: "atempo=1.1"},
+		{name: "fast tempo", filter: TempoFilter{Factor: 3}, want: "atempo=2,atempo=1.5"},
+		{name: "slow tempo", filter: TempoFilter{Factor: 0.25}, want: "atempo=0.5,atempo=0.5"},
+		{name: "high pass", filter: HighPassFilter{Frequency: 80}, want: "highpass=f=80"},
+		{name: "low pass", filter: LowPassFilter{Frequency: 12000}, want: "lowpass=f=12000"},
+		{name: "swap channels", filter: ChannelMapFilter{Channels: []int{1, 0}}, want: "channelmap=map=1|0:channel_layout=stereo"},
+		{name: "left channel", filter: ChannelMapFilter{Channels: []int{0}}, want: "channelmap=map=0:channel_layout=mono"},
+		{name: "denoise", filter: DenoiseFilter{}, want: "afftdn"},
+		{name: "strong denoise", filter: DenoiseFilter{Reduction: 30}, want: "afftdn=nr=30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.getFilter(); got != tt.want {
+				t.Errorf("AudioFilter.getFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_SetFilters(t *testing.T) {
+	builder := NewMP3Builder()
+	builder.streams = []segment{{Duration: 10, Stream: stream{Channels: 1}}}
+
+	tests := []struct {
+		name    string
+		index   int
+		filters []AudioFilter
+		wantErr bool
+	}{
+		{name: "valid", index: 0, filters: []AudioFilter{VolumeFilter{Gain: 3}, TempoFilter{Factor: 1.1}}},
+		{name: "no filters", index: 0},
+		{name: "unknown segment", index: 1, filters: []AudioFilter{VolumeFilter{Gain: 3}}, wantErr: true},
+		{name: "nil filter", index: 0, filters: []AudioFilter{nil}, wantErr: true},
+		{name: "zero tempo", index: 0, filters: []AudioFilter{TempoFilter{}}, wantErr: true},
+		{name: "zero cutoff", index: 0, filters: []AudioFilter{LowPassFilter{}}, wantErr: true},
+		{name: "missing channel", index: 0, filters: []AudioFilter{ChannelMapFilter{Channels: []int{1, 0}}}, wantErr: true},
+		{name: "three channels", index: 0, filters: []AudioFilter{ChannelMapFilter{Channels: []int{0, 0, 0}}}, wantErr: true},
+		{name: "denoise", index: 0, filters: []AudioFilter{DenoiseFilter{Reduction: 100}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := builder.SetFilters(tt.index, tt.filters...); (err != nil) != tt.wantErr {
+				t.Errorf("MP3Builder.SetFilters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithFilters(t *testing.T) {
+	executions := appendExecutions("00:00:11.00", `[{"time_base":"1/1000","start":0,"end":5500,"tags":{"title":"Question"}},{"time_base":"1/1000","start":5500,"end":11000,"tags":{"title":"Answer"}}]`, `{"bit_rate":"64000"}`, true)
+	executions = append(executions, appendExecutions("00:00:10.00", `[{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Outro"}}]`, `{"bit_rate":"64000"}`, false)...)
+	executor := NewReplayExecutor(append(executions, Execution{Name: "ffmpeg"})...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	for _, file := range []string{"interview.mp3", "outro.mp3"} {
+		if err := builder.Append(file, 0, -1); err != nil {
+			t.Fatalf("MP3Builder.Append() error = %v", err)
+		}
+	}
+	if err := builder.SetFilters(0, HighPassFilter{Frequency: 80}, VolumeFilter{Gain: 6}, TempoFilter{Factor: 1.1}); err != nil {
+		t.Fatalf("MP3Builder.SetFilters() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	if args := calls[len(calls)-1].Args; !containsSequence(args, []string{"-filter_complex",
+		"[0:a]highpass=f=80,volume=6dB,atempo=1.1[f0];[f0][1:a]concat=n=2:v=0:a=1[aout]"}) {
+		t.Errorf("MP3Builder.Build() args = %v", args)
+	}
+
+	expected := []struct {
+		title string
+		start float64
+		end   float64
+	}{
+		{title: "Question", start: 0, end: 5},
+		{title: "Answer", start: 5, end: 10},
+		{title: "Outro", start: 10, end: 20},
+	}
+	chapters := builder.getChapters()
+	if len(chapters) != len(expected) {
+		t.Fatalf("MP3Builder.getChapters() got %d chapters, want %d", len(chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || math.Abs(chapter.GetStartTimeInSeconds()-want.start) > 1e-6 || math.Abs(chapter.GetEndTimeInSeconds()-want.end) > 1e-6 {
+			t.Errorf("MP3Builder.getChapters() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+	if got := builder.getTotalDuration(); math.Abs(got-20) > 1e-6 {
+		t.Errorf("MP3Builder.getTotalDuration() = %v, want 20", got)
+	}
+}
+
+func TestMP3Builder_BuildWithFiltersInStreamCopyMode(t *testing.T) {
+	executor := NewReplayExecutor(appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"64000"}`, true)...)
+	builder := NewMP3Builder(WithExecutor(executor), WithBuildMode(BuildModeStreamCopy))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.SetFilters(0, VolumeFilter{Gain: 3}); err != nil {
+		t.Fatalf("MP3Builder.SetFilters() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err == nil {
+		t.Error("MP3Builder.Build() expected error")
+	}
+}
@@ -0,0 +1,100 @@
+package mp3joiner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const filterGraphOutput = "[aout]"
+
+// Returns the filter graph which joins all segments into [aout].
+// Segments are concatenated, e.g. [0:a][1:a]concat=n=2:v=0:a=1[aout],
+// unless a crossfade is set between them.
+func (b *MP3Builder) getFilterGraph() string {
+	return b.joinSegments(b.getOutputFilters())
+}
+
+// Returns the filter graph which filters each segment, joins them
+// and applies the output filters to the result.
+func (b *MP3Builder) joinSegments(outputFilters []string) string {
+	filters := make([]string, 0, 2*len(b.streams))
+	addFilter := func(inputs string, filter string) string {
+		label := fmt.Sprintf("[f%d]", len(filters))
+		filters = append(filters, inputs+filter+label)
+		return label
+	}
+
+	// segments joined without crossfade, each group is crossfaded into the previous one
+	groups := make([][]string, 0, 1)
+	for i, s := range b.streams {
+		label := "[" + strconv.Itoa(i) + ":" + s.Stream.getAudioSpecifier() + "]"
+		if segmentFilters := b.getSegmentFilters(i); len(segmentFilters) > 0 {
+			label = addFilter(label, strings.Join(segmentFilters, ","))
+		}
+		if i == 0 || b.getCrossfade(i).Duration > 0 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], label)
+	}
+
+	var current string
+	segmentIndex := 0
+	for i, group := range groups {
+		label := group[0]
+		if len(group) > 1 || (len(groups) == 1 && len(outputFilters) == 0) {
+			label = addFilter(strings.Join(group, ""), fmt.Sprintf("concat=n=%d:v=0:a=1", len(group)))
+		}
+		if i > 0 {
+			crossfade := b.getCrossfade(segmentIndex)
+			current = addFilter(current+label, fmt.Sprintf("acrossfade=d=%s:c1=%s:c2=%s",
+				formatSeconds(crossfade.Duration), crossfade.getCurve(), crossfade.getCurve()))
+		} else {
+			current = label
+		}
+		segmentIndex += len(group)
+	}
+	if len(outputFilters) > 0 {
+		current = addFilter(current, strings.Join(outputFilters, ","))
+	}
+
+	// the last filter writes the output
+	last := len(filters) - 1
+	filters[last] = strings.TrimSuffix(filters[last], current) + filterGraphOutput
+	return strings.Join(filters, ";")
+}
+
+// Returns the filters applied to the segment at the index before it is joined.
+func (b *MP3Builder) getSegmentFilters(index int) []string {
+	result := make([]string, 0, len(b.streams[index].Filters)+1)
+	for _, filter := range b.streams[index].Filters {
+		result = append(result, filter.getFilter())
+	}
+	if measured := b.getSegmentLoudness(index); measured != nil {
+		result = append(result, b.options.loudness.getNormalizeFilter(*measured, b.streams[index].Stream.SampleRate))
+	}
+	return result
+}
+
+// Returns the filters applied to the joined segments.
+func (b *MP3Builder) getOutputFilters() []string {
+	result := b.getFadeFilters()
+	if measured := b.getOutputLoudness(); measured != nil {
+		result = append(result, b.options.loudness.getNormalizeFilter(*measured, b.getJoinedSampleRate()))
+	}
+	return result
+}
+
+// Returns the fade in and fade out of the output.
+func (b *MP3Builder) getFadeFilters() []string {
+	result := make([]string, 0, 2)
+	if fadeIn := b.options.fadeIn; fadeIn.Duration > 0 {
+		result = append(result, fmt.Sprintf("afade=t=in:st=0:d=%s:curve=%s",
+			formatSeconds(fadeIn.Duration), fadeIn.getCurve()))
+	}
+	if fadeOut := b.options.fadeOut; fadeOut.Duration > 0 {
+		result = append(result, fmt.Sprintf("afade=t=out:st=%s:d=%s:curve=%s",
+			formatSeconds(b.getTotalDuration()-fadeOut.Duration), formatSeconds(fadeOut.Duration), fadeOut.getCurve()))
+	}
+	return result
+}
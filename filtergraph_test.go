@@ -0,0 +1,65 @@
+package mp3joiner
+
+import (
+	"testing"
+)
+
+func TestMP3Builder_getFilterGraph(t *testing.T) {
+	crossfade := Fade{Duration: 2, Curve: FadeCurveQuarterSine}
+	tests := []struct {
+		name       string
+		opts       []Option
+		segments   int
+		crossfades map[int]Fade
+		want       string
+	}{
+		{
+			name:     "single segment",
+			segments: 1,
+			want:     "[0:a]concat=n=1:v=0:a=1[aout]",
+		}, {
+			name:     "concat",
+			segments: 3,
+			want:     "[0:a][1:a][2:a]concat=n=3:v=0:a=1[aout]",
+		}, {
+			name:       "single crossfade",
+			segments:   4,
+			crossfades: map[int]Fade{2: crossfade},
+			want: "[0:a][1:a]concat=n=2:v=0:a=1[f0];[2:a][3:a]concat=n=2:v=0:a=1[f1];" +
+				"[f0][f1]acrossfade=d=2.000:c1=qsin:c2=qsin[aout]",
+		}, {
+			name:       "crossfade with hard cut",
+			opts:       []Option{WithCrossfade(Fade{Duration: 1})},
+			segments:   3,
+			crossfades: map[int]Fade{1: {}},
+			want:       "[0:a][1:a]concat=n=2:v=0:a=1[f0];[f0][2:a]acrossfade=d=1.000:c1=tri:c2=tri[aout]",
+		}, {
+			name:     "crossfades with fades",
+			opts:     []Option{WithCrossfade(Fade{Duration: 1}), WithFadeIn(Fade{Duration: 3}), WithFadeOut(Fade{Duration: 5, Curve: FadeCurveLogarithmic})},
+			segments: 3,
+			want: "[0:a][1:a]acrossfade=d=1.000:c1=tri:c2=tri[f0];[f0][2:a]acrossfade=d=1.000:c1=tri:c2=tri[f1];" +
+				"[f1]afade=t=in:st=0:d=3.000:curve=tri,afade=t=out:st=23.000:d=5.000:curve=log[aout]",
+		}, {
+			name:     "fade of single segment",
+			opts:     []Option{WithFadeOut(Fade{Duration: 2})},
+			segments: 1,
+			want:     "[0:a]afade=t=out:st=8.000:d=2.000:curve=tri[aout]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewMP3Builder(tt.opts...)
+			for i := 0; i < tt.segments; i++ {
+				builder.streams = append(builder.streams, segment{Duration: 10})
+			}
+			for index, fade := range tt.crossfades {
+				if err := builder.SetCrossfade(index, fade); err != nil {
+					t.Fatalf("MP3Builder.SetCrossfade() error = %v", err)
+				}
+			}
+			if got := builder.getFilterGraph(); got != tt.want {
+				t.Errorf("MP3Builder.getFilterGraph() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package mp3joiner
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Audio source of ffmpeg used by MP3Builder.AppendGenerated.
+type GeneratorSource string
+
+const (
+	GeneratorSourceSilence GeneratorSource = "anullsrc"
+	GeneratorSourceSine    GeneratorSource = "sine"
+	GeneratorSourceNoise   GeneratorSource = "anoisesrc"
+)
+
+const (
+	defaultGeneratorSampleRate = 44100
+	defaultGeneratorChannels   = 2
+	defaultSineFrequency       = 440
+)
+
+var noiseColors = []string{"white", "pink", "brown", "blue", "violet", "velvet"}
+
+// Audio which is generated by ffmpeg instead of read from a file.
+type GeneratedAudio struct {
+	Source GeneratorSource
+	// length in seconds
+	Duration float64
+	// frequency in Hz of GeneratorSourceSine, 440 Hz if 0
+	Frequency float64
+	// amplitude between 0 and 1 of GeneratorSourceSine and
+	// GeneratorSourceNoise, the default of ffmpeg if 0
+	Amplitude float64
+	// color of GeneratorSourceNoise, e.g. "white", "pink" or "brown",
+	// white if empty
+	NoiseColor string
+	// creates a chapter covering the generated audio if set
+	ChapterTitle string
+}
+
+func (g GeneratedAudio) validate() error {
+	if g.Duration <= 0 {
+		return fmt.Errorf("duration of generated audio has to be positive but is %v", g.Duration)
+	}
+	if g.Amplitude < 0 || g.Amplitude > 1 {
+		return fmt.Errorf("amplitude %v is not between 0 and 1", g.Amplitude)
+	}
+	switch g.Source {
+	case GeneratorSourceSilence:
+	case GeneratorSourceSine:
+		if g.Frequency < 0 {
+			return fmt.Errorf("invalid negative frequency %v", g.Frequency)
+		}
+	case GeneratorSourceNoise:
+		if g.NoiseColor != "" && !slices.Contains(noiseColors, g.NoiseColor) {
+			return fmt.Errorf("unknown noise color '%s', use one of %v", g.NoiseColor, noiseColors)
+		}
+	default:
+		return fmt.Errorf("unknown generator source '%s'", g.Source)
+	}
+	return nil
+}
+
+// Returns the lavfi source reading the generated audio in the format of the stream.
+func (g GeneratedAudio) getSource(format stream) string {
+	sampleRate := format.SampleRate
+	switch g.Source {
+	case GeneratorSourceSine:
+		frequency := g.Frequency
+		if frequency == 0 {
+			frequency = defaultSineFrequency
+		}
+		result := fmt.Sprintf("sine=f=%s:r=%s", strconv.FormatFloat(frequency, 'f', -1, 64), sampleRate)
+		if g.Amplitude > 0 {
+			// sine has no amplitude and uses 1/8 of full scale
+			result += fmt.Sprintf(",volume=%s", strconv.FormatFloat(g.Amplitude*8, 'f', -1, 64))
+		}
+		return result
+	case GeneratorSourceNoise:
+		color := g.NoiseColor
+		if color == "" {
+			color = noiseColors[0]
+		}
+		result := fmt.Sprintf("anoisesrc=c=%s:r=%s", color, sampleRate)
+		if g.Amplitude > 0 {
+			result += ":a=" + strconv.FormatFloat(g.Amplitude, 'f', -1, 64)
+		}
+		return result
+	default:
+		channelLayout := "stereo"
+		if format.Channels == 1 {
+			channelLayout = "mono"
+		}
+		return fmt.Sprintf("anullsrc=r=%s:cl=%s", sampleRate, channelLayout)
+	}
+}
+
+// Adds silence of the given length in seconds to the builder.
+func (b *MP3Builder) AppendSilence(durationInSeconds float64) error {
+	return b.AppendGenerated(GeneratedAudio{Source: GeneratorSourceSilence, Duration: durationInSeconds})
+}
+
+// Adds audio generated by ffmpeg, like silence, a sine tone or noise,
+// to the builder. The audio uses the sample rate and channels of the
+// previously appended file.
+func (b *MP3Builder) AppendGenerated(generated GeneratedAudio) error {
+	if err := generated.validate(); err != nil {
+		return err
+	}
+
+	format := b.getGeneratorFormat()
+	chapters := make([]Chapter, 0, 1)
+	if generated.ChapterTitle != "" {
+		chapters = append(chapters, Chapter{
+			TimeBase: DEFAULT_TIME_BASE,
+			Start:    0,
+			End:      toDefaultTimeBase(generated.Duration),
+			Tags:     Tags{Title: generated.ChapterTitle},
+		})
+	}
+	b.streams = append(b.streams, segment{
+		Source:   generated.getSource(format),
+		Duration: generated.Duration,
+		Chapters: chapters,
+		Stream:   format,
+	})
+	return nil
+}
+
+// Returns sample rate and channels of the last appended file
+// or CD quality if no file has been appended.
+func (b *MP3Builder) getGeneratorFormat() stream {
+	for i := len(b.streams) - 1; i >= 0; i-- {
+		s := b.streams[i].Stream
+		if b.streams[i].Source == "" && s.SampleRate != "" && s.Channels > 0 {
+			return stream{CodecType: "audio", SampleRate: s.SampleRate, Channels: min(s.Channels, 2)}
+		}
+	}
+	return stream{
+		CodecType:  "audio",
+		SampleRate: strconv.Itoa(defaultGeneratorSampleRate),
+		Channels:   defaultGeneratorChannels,
+	}
+}
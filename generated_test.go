@@ -0,0 +1,134 @@
+package mp3joiner
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedAudio_getSource(t *testing.T) {
+	format := stream{SampleRate: "22050", Channels: 1}
+	tests := []struct {
+		name      string
+		generated GeneratedAudio
+		want      string
+	}{
+		{name: "silence", generated: GeneratedAudio{Source: GeneratorSourceSilence}, want: "anullsrc=r=22050:cl=mono"},
+		{name: "sine", generated: GeneratedAudio{Source: GeneratorSourceSine}, want: "sine=f=440:r=22050"},
+		{name: "quiet sine", generated: GeneratedAudio{Source: GeneratorSourceSine, Frequency: 1000.5, Amplitude: 0.25}, want: "sine=f=1000.5:r=22050,volume=2"},
+		{name: "noise", generated: GeneratedAudio{Source: GeneratorSourceNoise}, want: "anoisesrc=c=white:r=22050"},
+		{name: "pink noise", generated: GeneratedAudio{Source: GeneratorSourceNoise, NoiseColor: "pink", Amplitude: 0.1}, want: "anoisesrc=c=pink:r=22050:a=0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.generated.getSource(format); got != tt.want {
+				t.Errorf("GeneratedAudio.getSource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_AppendGeneratedErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		generated GeneratedAudio
+	}{
+		{name: "no duration", generated: GeneratedAudio{Source: GeneratorSourceSilence}},
+		{name: "unknown source", generated: GeneratedAudio{Source: "aevalsrc", Duration: 1}},
+		{name: "amplitude", generated: GeneratedAudio{Source: GeneratorSourceSine, Duration: 1, Amplitude: 2}},
+		{name: "frequency", generated: GeneratedAudio{Source: GeneratorSourceSine, Duration: 1, Frequency: -1}},
+		{name: "noise color", generated: GeneratedAudio{Source: GeneratorSourceNoise, Duration: 1, NoiseColor: "green"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewMP3Builder()
+			if err := builder.AppendGenerated(tt.generated); err == nil {
+				t.Error("MP3Builder.AppendGenerated() expected error")
+			}
+			if len(builder.streams) != 0 {
+				t.Errorf("MP3Builder.AppendGenerated() added segment despite error")
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithGeneratedAudio(t *testing.T) {
+	streamJSON := `{"codec_type":"audio","codec_name":"mp3","bit_rate":"64000","sample_rate":"22050","channels":1}`
+	executions := appendExecutions("00:00:10.00", `[{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Intro"}}]`, streamJSON, true)
+	executions = append(executions, appendExecutions("00:00:20.00", `[{"time_base":"1/1000","start":0,"end":20000,"tags":{"title":"Main"}}]`, streamJSON, false)...)
+	executor := NewReplayExecutor(append(executions, Execution{Name: "ffmpeg"})...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	if err := builder.AppendSilence(1.5); err != nil {
+		t.Fatalf("MP3Builder.AppendSilence() error = %v", err)
+	}
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.AppendGenerated(GeneratedAudio{Source: GeneratorSourceSine, Duration: 2, ChapterTitle: "Sting"}); err != nil {
+		t.Fatalf("MP3Builder.AppendGenerated() error = %v", err)
+	}
+	if err := builder.Append("second.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	args := calls[len(calls)-1].Args
+	for _, want := range [][]string{
+		{"-f", "lavfi", "-t", "1.500", "-i", "anullsrc=r=44100:cl=stereo"},
+		{"-f", "lavfi", "-t", "2.000", "-i", "sine=f=440:r=22050"},
+		{"-filter_complex", "[0:a][1:a][2:a][3:a]concat=n=4:v=0:a=1[aout]"},
+	} {
+		if !containsSequence(args, want) {
+			t.Errorf("MP3Builder.Build() args = %v, expected to contain %v", args, want)
+		}
+	}
+
+	expected := []struct {
+		title string
+		start float64
+		end   float64
+	}{
+		{title: "Intro", start: 1.5, end: 11.5},
+		{title: "Sting", start: 11.5, end: 13.5},
+		{title: "Main", start: 13.5, end: 33.5},
+	}
+	chapters := builder.getChapters()
+	if len(chapters) != len(expected) {
+		t.Fatalf("MP3Builder.getChapters() got %d chapters, want %d", len(chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("MP3Builder.getChapters() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+}
+
+func TestMP3Builder_BuildStreamCopyWithGeneratedAudio(t *testing.T) {
+	streamJSON := `{"codec_type":"audio","codec_name":"mp3","bit_rate":"64000","sample_rate":"44100","channels":2}`
+	executions := appendExecutions("00:00:10.00", `[]`, streamJSON, true)
+	executions = append(executions, Execution{Name: "ffmpeg"}, Execution{Name: "ffmpeg"})
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithBuildMode(BuildModeStreamCopy))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.AppendSilence(2); err != nil {
+		t.Fatalf("MP3Builder.AppendSilence() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	if len(calls) < 2 {
+		t.Fatalf("MP3Builder.Build() expected the silence to be encoded separately")
+	}
+	if args := calls[len(calls)-2].Args; !containsSequence(args, []string{"-f", "lavfi", "-t", "2.000", "-i", "anullsrc=r=44100:cl=stereo"}) {
+		t.Errorf("MP3Builder.Build() args = %v, expected silence to be encoded", args)
+	}
+}
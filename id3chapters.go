@@ -0,0 +1,361 @@
+package mp3joiner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	chapterFrameID         = "CHAP"
+	tableOfContentsFrameID = "CTOC"
+	titleFrameID           = "TIT2"
+	subtitleFrameID        = "TIT3"
+	artistFrameID          = "TPE1"
+	userLinkFrameID        = "WXXX"
+	pictureFrameID         = "APIC"
+
+	tableOfContentsOrderedFlag  = 0x1
+	tableOfContentsTopLevelFlag = 0x2
+	chapterOffsetNotSet         = 0xFFFFFFFF
+	// CHAP frames store times in milliseconds
+	id3ChapterTimeBase = "1/1000"
+
+	defaultTableOfContentsID = "toc"
+)
+
+// Link attached to a chapter, stored as WXXX frame.
+type Link struct {
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// Image attached to a chapter, stored as APIC frame.
+type Picture struct {
+	MIMEType string `json:"mime_type,omitempty"`
+	// picture type as defined by ID3v2, e.g. 3 for the front cover
+	Type        byte   `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+// Table of contents, stored as CTOC frame. It references
+// chapters and nested tables of contents by their element id.
+type TableOfContents struct {
+	ElementID string `json:"element_id,omitempty"`
+	Title     string `json:"title,omitempty"`
+	// the root table of contents
+	TopLevel bool `json:"top_level,omitempty"`
+	// true if the children have to be played in order
+	Ordered bool `json:"ordered,omitempty"`
+	// element ids of chapters and nested tables of contents
+	Children []string `json:"children,omitempty"`
+}
+
+// Reads the chapters (CHAP frames) and tables of contents (CTOC frames)
+// from the ID3v2 tag of the file without using ffprobe.
+// In contrast to GetChapterMetadata this also reads the subtitle,
+// links and images of each chapter.
+func ReadID3Chapters(mp3Filepath string) (chapters []Chapter, tablesOfContents []TableOfContents, err error) {
+	tag, err := readID3TagFromFile(mp3Filepath)
+	if err != nil {
+		return make([]Chapter, 0), make([]TableOfContents, 0), err
+	}
+	return tag.getChapters()
+}
+
+// Replaces all chapters and tables of contents in the ID3v2 tag of
+// the file. Other frames of the tag are kept. If no table of contents
+// is provided, a top level table of contents listing all chapters in
+// order is created, with nested tables of contents of at most 255
+// chapters each for longer lists. Chapters without or with duplicate element ids
+// get generated ids.
+func WriteID3Chapters(mp3Filepath string, chapters []Chapter, tablesOfContents []TableOfContents) (err error) {
+	editor, err := NewTagEditor(mp3Filepath)
+	if err != nil {
+		return err
+	}
+	if err = editor.SetChapters(chapters, tablesOfContents); err != nil {
+		return err
+	}
+	return editor.Save()
+}
+
+func (t *id3Tag) getChapters() (chapters []Chapter, tablesOfContents []TableOfContents, err error) {
+	chapters = make([]Chapter, 0)
+	tablesOfContents = make([]TableOfContents, 0)
+
+	for _, payload := range t.getPayloads(chapterFrameID) {
+		chapter, err := t.decodeChapter(payload)
+		if err != nil {
+			return chapters, tablesOfContents, err
+		}
+		chapters = append(chapters, chapter)
+	}
+	for _, payload := range t.getPayloads(tableOfContentsFrameID) {
+		tableOfContents, err := t.decodeTableOfContents(payload)
+		if err != nil {
+			return chapters, tablesOfContents, err
+		}
+		tablesOfContents = append(tablesOfContents, tableOfContents)
+	}
+
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+	return chapters, tablesOfContents, nil
+}
+
+func (t *id3Tag) setChapters(chapters []Chapter, tablesOfContents []TableOfContents) error {
+	frames := make([]id3Frame, 0, len(chapters)+len(tablesOfContents)+1)
+	elementIDs := getUniqueElementIDs(chapters)
+	for i, chapter := range chapters {
+		payload, err := t.encodeChapter(chapter, elementIDs[i])
+		if err != nil {
+			return err
+		}
+		frames = append(frames, id3Frame{id: chapterFrameID, data: payload})
+	}
+
+	if len(tablesOfContents) == 0 && len(chapters) > 0 {
+		tablesOfContents = getGeneratedTablesOfContents(elementIDs)
+	}
+	for _, tableOfContents := range tablesOfContents {
+		payload, err := t.encodeTableOfContents(tableOfContents)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, id3Frame{id: tableOfContentsFrameID, data: payload})
+	}
+
+	t.removeFrames(chapterFrameID, tableOfContentsFrameID)
+	t.frames = append(t.frames, frames...)
+	return nil
+}
+
+// Returns a top level table of contents listing the element ids in
+// order. A table of contents holds at most 255 entries, so longer
+// lists are split into nested tables of contents.
+func getGeneratedTablesOfContents(elementIDs []string) []TableOfContents {
+	nested := make([]TableOfContents, 0)
+	children := elementIDs
+	for level := 1; len(children) > math.MaxUint8; level++ {
+		groups := make([]string, 0, len(children)/math.MaxUint8+1)
+		for start := 0; start < len(children); start += math.MaxUint8 {
+			elementID := fmt.Sprintf("%s%d_%d", defaultTableOfContentsID, level, len(groups)+1)
+			nested = append(nested, TableOfContents{
+				ElementID: elementID,
+				Ordered:   true,
+				Children:  children[start:min(start+math.MaxUint8, len(children))],
+			})
+			groups = append(groups, elementID)
+		}
+		children = groups
+	}
+	topLevel := TableOfContents{
+		ElementID: defaultTableOfContentsID,
+		TopLevel:  true,
+		Ordered:   true,
+		Children:  children,
+	}
+	return append([]TableOfContents{topLevel}, nested...)
+}
+
+// Returns the element ids of the chapters, generating ids
+// for chapters without or with an already used id.
+func getUniqueElementIDs(chapters []Chapter) []string {
+	result := make([]string, len(chapters))
+	used := make(map[string]bool, len(chapters))
+	for i, chapter := range chapters {
+		id := chapter.ElementID
+		for counter := i; id == "" || used[id]; counter++ {
+			id = fmt.Sprintf("chp%d", counter)
+		}
+		used[id] = true
+		result[i] = id
+	}
+	return result
+}
+
+func (t *id3Tag) decodeChapter(payload []byte) (result Chapter, err error) {
+	elementID, rest := readTerminatedText(payload, textEncodingISO88591)
+	if len(rest) < 16 {
+		return result, fmt.Errorf("invalid CHAP frame '%s'", elementID)
+	}
+
+	result.ElementID = elementID
+	result.TimeBase = id3ChapterTimeBase
+	result.Start = int(binary.BigEndian.Uint32(rest[0:4]))
+	result.End = int(binary.BigEndian.Uint32(rest[4:8]))
+
+	subFrames, err := t.parseFrames(rest[16:], false)
+	if err != nil {
+		return result, err
+	}
+	for _, frame := range subFrames {
+		payload, ok := t.getPayload(frame)
+		if !ok {
+			continue
+		}
+		switch frame.id {
+		case titleFrameID:
+			result.Tags.Title = decodeTextFrame(payload)
+		case subtitleFrameID:
+			result.Tags.Subtitle = decodeTextFrame(payload)
+		case artistFrameID:
+			result.Tags.Artist = decodeTextFrame(payload)
+		case userLinkFrameID:
+			result.Links = append(result.Links, decodeLinkFrame(payload))
+		case pictureFrameID:
+			picture, err := decodePictureFrame(payload)
+			if err != nil {
+				return result, err
+			}
+			result.Image = &picture
+		}
+	}
+	return result, nil
+}
+
+func (t *id3Tag) encodeChapter(chapter Chapter, elementID string) ([]byte, error) {
+	start := chapter.GetStartTimeInSeconds() * 1000
+	end := chapter.GetEndTimeInSeconds() * 1000
+	if start < 0 || end > math.MaxUint32 {
+		return nil, fmt.Errorf("chapter '%s' is out of range", chapter.Tags.Title)
+	}
+
+	payload := encodeText(elementID, textEncodingISO88591, true)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(math.Round(start)))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(math.Round(end)))
+	payload = binary.BigEndian.AppendUint32(payload, chapterOffsetNotSet)
+	payload = binary.BigEndian.AppendUint32(payload, chapterOffsetNotSet)
+
+	subFrames := make([]id3Frame, 0)
+	if chapter.Tags.Title != "" {
+		subFrames = append(subFrames, id3Frame{id: titleFrameID, data: t.encodeTextFrame(chapter.Tags.Title)})
+	}
+	if chapter.Tags.Subtitle != "" {
+		subFrames = append(subFrames, id3Frame{id: subtitleFrameID, data: t.encodeTextFrame(chapter.Tags.Subtitle)})
+	}
+	if chapter.Tags.Artist != "" {
+		subFrames = append(subFrames, id3Frame{id: artistFrameID, data: t.encodeTextFrame(chapter.Tags.Artist)})
+	}
+	for _, link := range chapter.Links {
+		subFrames = append(subFrames, id3Frame{id: userLinkFrameID, data: t.encodeLinkFrame(link)})
+	}
+	if chapter.Image != nil {
+		subFrames = append(subFrames, id3Frame{id: pictureFrameID, data: t.encodePictureFrame(*chapter.Image)})
+	}
+
+	encodedSubFrames, err := t.encodeFrames(subFrames)
+	if err != nil {
+		return nil, err
+	}
+	return append(payload, encodedSubFrames...), nil
+}
+
+func (t *id3Tag) decodeTableOfContents(payload []byte) (result TableOfContents, err error) {
+	elementID, rest := readTerminatedText(payload, textEncodingISO88591)
+	if len(rest) < 2 {
+		return result, fmt.Errorf("invalid CTOC frame '%s'", elementID)
+	}
+
+	result.ElementID = elementID
+	result.TopLevel = rest[0]&tableOfContentsTopLevelFlag != 0
+	result.Ordered = rest[0]&tableOfContentsOrderedFlag != 0
+	entryCount := int(rest[1])
+	rest = rest[2:]
+	result.Children = make([]string, 0, entryCount)
+	for i := 0; i < entryCount && len(rest) > 0; i++ {
+		var child string
+		child, rest = readTerminatedText(rest, textEncodingISO88591)
+		result.Children = append(result.Children, child)
+	}
+
+	subFrames, err := t.parseFrames(rest, false)
+	if err != nil {
+		return result, err
+	}
+	for _, frame := range subFrames {
+		if payload, ok := t.getPayload(frame); ok && frame.id == titleFrameID {
+			result.Title = decodeTextFrame(payload)
+		}
+	}
+	return result, nil
+}
+
+func (t *id3Tag) encodeTableOfContents(tableOfContents TableOfContents) ([]byte, error) {
+	if len(tableOfContents.Children) > math.MaxUint8 {
+		return nil, fmt.Errorf("table of contents '%s' has more than %d entries", tableOfContents.ElementID, math.MaxUint8)
+	}
+	elementID := tableOfContents.ElementID
+	if elementID == "" {
+		elementID = defaultTableOfContentsID
+	}
+
+	flags := byte(0)
+	if tableOfContents.TopLevel {
+		flags |= tableOfContentsTopLevelFlag
+	}
+	if tableOfContents.Ordered {
+		flags |= tableOfContentsOrderedFlag
+	}
+
+	payload := encodeText(elementID, textEncodingISO88591, true)
+	payload = append(payload, flags, byte(len(tableOfContents.Children)))
+	for _, child := range tableOfContents.Children {
+		payload = append(payload, encodeText(child, textEncodingISO88591, true)...)
+	}
+
+	if tableOfContents.Title != "" {
+		subFrames, err := t.encodeFrames([]id3Frame{{id: titleFrameID, data: t.encodeTextFrame(tableOfContents.Title)}})
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, subFrames...)
+	}
+	return payload, nil
+}
+
+func decodeLinkFrame(payload []byte) (result Link) {
+	if len(payload) < 1 {
+		return result
+	}
+	description, rest := readTerminatedText(payload[1:], payload[0])
+	result.Description = description
+	result.URL, _ = readTerminatedText(rest, textEncodingISO88591)
+	return result
+}
+
+func (t *id3Tag) encodeLinkFrame(link Link) []byte {
+	encoding := t.getTextEncoding(link.Description)
+	payload := append([]byte{encoding}, encodeText(link.Description, encoding, true)...)
+	return append(payload, encodeText(link.URL, textEncodingISO88591, false)...)
+}
+
+func decodePictureFrame(payload []byte) (result Picture, err error) {
+	if len(payload) < 1 {
+		return result, errors.New("invalid APIC frame")
+	}
+	encoding := payload[0]
+	mimeType, rest := readTerminatedText(payload[1:], textEncodingISO88591)
+	if len(rest) < 1 {
+		return result, errors.New("invalid APIC frame")
+	}
+	result.MIMEType = mimeType
+	result.Type = rest[0]
+	result.Description, rest = readTerminatedText(rest[1:], encoding)
+	result.Data = append([]byte(nil), rest...)
+	return result, nil
+}
+
+func (t *id3Tag) encodePictureFrame(picture Picture) []byte {
+	encoding := t.getTextEncoding(picture.Description)
+	payload := []byte{encoding}
+	payload = append(payload, encodeText(picture.MIMEType, textEncodingISO88591, true)...)
+	payload = append(payload, picture.Type)
+	payload = append(payload, encodeText(picture.Description, encoding, true)...)
+	return append(payload, picture.Data...)
+}
@@ -0,0 +1,179 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestWriteID3Chapters(t *testing.T) {
+	chapters := []Chapter{
+		{
+			TimeBase:  "1/1000",
+			Start:     0,
+			End:       1500,
+			ElementID: "intro",
+			Tags:      Tags{Title: "Intro", Subtitle: "Welcome ☺", Artist: "Host"},
+			Links:     []Link{{Description: "Website", URL: "https://example.com"}},
+			Image:     &Picture{MIMEType: "image/png", Type: 3, Description: "Cover", Data: []byte{0x89, 'P', 'N', 'G'}},
+		}, {
+			TimeBase: "1/1",
+			Start:    1,
+			End:      2,
+			Tags:     Tags{Title: "Main"},
+		},
+	}
+	tablesOfContents := []TableOfContents{
+		{ElementID: "toc", Title: "Contents", TopLevel: true, Ordered: true, Children: []string{"intro", "sub"}},
+		{ElementID: "sub", Ordered: true, Children: []string{"chp1"}},
+	}
+
+	tests := []struct {
+		name             string
+		version          byte
+		tablesOfContents []TableOfContents
+		wantTables       []TableOfContents
+	}{
+		{
+			name:             "ID3v2.4 with nested tables of contents",
+			version:          4,
+			tablesOfContents: tablesOfContents,
+			wantTables:       tablesOfContents,
+		}, {
+			name:             "ID3v2.3 with generated table of contents",
+			version:          3,
+			tablesOfContents: nil,
+			wantTables: []TableOfContents{
+				{ElementID: "toc", TopLevel: true, Ordered: true, Children: []string{"intro", "chp1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := createTestMP3File(t, tt.version)
+
+			if err := WriteID3Chapters(filePath, chapters, tt.tablesOfContents); err != nil {
+				t.Fatalf("WriteID3Chapters() error = %v", err)
+			}
+			gotChapters, gotTables, err := ReadID3Chapters(filePath)
+			if err != nil {
+				t.Fatalf("ReadID3Chapters() error = %v", err)
+			}
+
+			wantChapters := []Chapter{chapters[0], {
+				TimeBase:  "1/1000",
+				Start:     1000,
+				End:       2000,
+				ElementID: "chp1",
+				Tags:      Tags{Title: "Main"},
+			}}
+			if !reflect.DeepEqual(gotChapters, wantChapters) {
+				t.Errorf("ReadID3Chapters() chapters = %+v, want %+v", gotChapters, wantChapters)
+			}
+			if !reflect.DeepEqual(gotTables, tt.wantTables) {
+				t.Errorf("ReadID3Chapters() tables of contents = %+v, want %+v", gotTables, tt.wantTables)
+			}
+
+			// other frames and audio are kept
+			tag, err := readID3TagFromFile(filePath)
+			if err != nil {
+				t.Fatalf("could not read tag %v", err)
+			}
+			if tag.version != tt.version {
+				t.Errorf("WriteID3Chapters() changed version to %v", tag.version)
+			}
+			titles := tag.getPayloads(titleFrameID)
+			if len(titles) != 1 || decodeTextFrame(titles[0]) != "Song" {
+				t.Errorf("WriteID3Chapters() did not keep title frame")
+			}
+			info, err := GetMP3Info(filePath)
+			if err != nil || info.Frames != 10 {
+				t.Errorf("WriteID3Chapters() did not keep audio, %v, %v", info, err)
+			}
+		})
+	}
+}
+
+func TestWriteID3ChaptersNonMP3(t *testing.T) {
+	content := concat([]byte("fLaC"), make([]byte, 16))
+	filePath := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chapters := []Chapter{{TimeBase: "1/1", Start: 0, End: 1, Tags: Tags{Title: "One"}}}
+	if err := WriteID3Chapters(filePath, chapters, nil); err == nil {
+		t.Error("WriteID3Chapters() expected error for FLAC file")
+	}
+	if got, err := os.ReadFile(filePath); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("WriteID3Chapters() changed the FLAC file, %v", err)
+	}
+}
+
+func TestReadID3Chapters(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(filePath, createTestFrames(testMPEG1FrameHeader, 2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chapters, tablesOfContents, err := ReadID3Chapters(filePath)
+	if err != nil || len(chapters) != 0 || len(tablesOfContents) != 0 {
+		t.Errorf("ReadID3Chapters() = %v, %v, %v, want no chapters", chapters, tablesOfContents, err)
+	}
+	if _, _, err := ReadID3Chapters("not existing"); err == nil {
+		t.Errorf("ReadID3Chapters() expected error for non existing file")
+	}
+}
+
+func TestWriteID3ChaptersWithManyChapters(t *testing.T) {
+	chapters := make([]Chapter, 600)
+	for i := range chapters {
+		chapters[i] = Chapter{TimeBase: "1/1", Start: i, End: i + 1, Tags: Tags{Title: fmt.Sprintf("Chapter %d", i+1)}}
+	}
+	filePath := createTestMP3File(t, 4)
+	if err := WriteID3Chapters(filePath, chapters, nil); err != nil {
+		t.Fatalf("WriteID3Chapters() error = %v", err)
+	}
+
+	gotChapters, gotTables, err := ReadID3Chapters(filePath)
+	if err != nil || len(gotChapters) != len(chapters) {
+		t.Fatalf("ReadID3Chapters() got %d chapters, %v", len(gotChapters), err)
+	}
+	if len(gotTables) != 4 || !slices.Equal(gotTables[0].Children, []string{"toc1_1", "toc1_2", "toc1_3"}) || !gotTables[0].TopLevel {
+		t.Fatalf("ReadID3Chapters() tables of contents = %+v", gotTables)
+	}
+	if len(gotTables[1].Children) != 255 || len(gotTables[3].Children) != 90 || gotTables[3].Children[89] != "chp599" {
+		t.Errorf("ReadID3Chapters() nested tables of contents = %+v", gotTables[1:])
+	}
+}
+
+func Test_getUniqueElementIDs(t *testing.T) {
+	chapters := []Chapter{{ElementID: "a"}, {}, {ElementID: "a"}, {ElementID: "chp3"}}
+	want := []string{"a", "chp1", "chp2", "chp3"}
+	if got := getUniqueElementIDs(chapters); !reflect.DeepEqual(got, want) {
+		t.Errorf("getUniqueElementIDs() = %v, want %v", got, want)
+	}
+}
+
+// Creates an MP3 file with an ID3v2 tag containing a title
+// followed by ten frames.
+func createTestMP3File(t *testing.T, version byte) string {
+	tag := newID3Tag()
+	tag.version = version
+	tag.frames = append(tag.frames, id3Frame{id: titleFrameID, data: tag.encodeTextFrame("Song")})
+	encodedTag, err := tag.encode(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "test.mp3")
+	content := bytes.Join([][]byte{encodedTag, createTestFrames(testMPEG1FrameHeader, 10)}, nil)
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
@@ -0,0 +1,549 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	id3v2UnsynchronisationFlag = 0x80
+	id3v2ExtendedHeaderFlag    = 0x40
+
+	// frame format flags of ID3v2.3
+	id3v23CompressionFlag = 0x80
+	id3v23EncryptionFlag  = 0x40
+	id3v23GroupingFlag    = 0x20
+	// frame format flags of ID3v2.4
+	id3v24GroupingFlag        = 0x40
+	id3v24CompressionFlag     = 0x08
+	id3v24EncryptionFlag      = 0x04
+	id3v24UnsynchronisedFlag  = 0x02
+	id3v24DataLengthIndicFlag = 0x01
+
+	id3v2FrameHeaderLength = 10
+	id3v2FrameIDLength     = 4
+	id3v2MaxSyncSafeValue  = 1<<28 - 1
+	defaultID3v2Version    = 4
+	// free space reserved in written tags for later edits
+	defaultID3v2Padding = 1024
+
+	textEncodingISO88591 = 0
+	textEncodingUTF16    = 1
+	textEncodingUTF16BE  = 2
+	textEncodingUTF8     = 3
+)
+
+var (
+	errNoID3v2Tag = errors.New("no ID3v2 tag found")
+)
+
+// ID3v2.3 or ID3v2.4 tag as described here:
+// https://id3.org/id3v2.3.0 and https://id3.org/id3v2.4.0-structure
+type id3Tag struct {
+	// major version, either 3 or 4
+	version byte
+	frames  []id3Frame
+	// length of the tag in the file including header,
+	// footer and padding, 0 if the tag is not stored yet
+	length int64
+}
+
+type id3Frame struct {
+	id string
+	// status and format flags
+	flags [2]byte
+	// frame content, without unsynchronisation
+	data []byte
+}
+
+func newID3Tag() *id3Tag {
+	return &id3Tag{
+		version: defaultID3v2Version,
+		frames:  make([]id3Frame, 0),
+	}
+}
+
+// Reads the ID3v2 tag at the current position of the reader.
+// Returns errNoID3v2Tag if there is none.
+func readID3Tag(reader io.Reader) (*id3Tag, error) {
+	header := make([]byte, id3v2HeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errNoID3v2Tag
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:3], id3v2TagIdentifier) {
+		return nil, errNoID3v2Tag
+	}
+	size, ok := decodeSyncSafe(header[6:10])
+	if !ok {
+		return nil, errNoID3v2Tag
+	}
+
+	tag := &id3Tag{
+		version: header[3],
+		frames:  make([]id3Frame, 0),
+		length:  int64(id3v2HeaderLength + size),
+	}
+	if tag.version != 3 && tag.version != 4 {
+		return nil, fmt.Errorf("unsupported ID3v2 version 2.%d", tag.version)
+	}
+	flags := header[5]
+	if flags&id3v2FooterFlag != 0 {
+		tag.length += id3v2HeaderLength
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("could not read ID3v2 tag: %w", err)
+	}
+	// ID3v2.3 applies unsynchronisation to the whole tag
+	if tag.version == 3 && flags&id3v2UnsynchronisationFlag != 0 {
+		data = removeUnsynchronisation(data)
+	}
+	if flags&id3v2ExtendedHeaderFlag != 0 {
+		extendedHeaderLength, err := tag.getExtendedHeaderLength(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[extendedHeaderLength:]
+	}
+
+	frames, err := tag.parseFrames(data, flags&id3v2UnsynchronisationFlag != 0)
+	if err != nil {
+		return nil, err
+	}
+	tag.frames = frames
+	return tag, nil
+}
+
+// Reads the ID3v2 tag at the start of the file.
+// Returns an empty tag if the file has none.
+func readID3TagFromFile(mp3Filepath string) (*id3Tag, error) {
+	file, err := os.Open(mp3Filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(file)
+
+	tag, err := readID3Tag(file)
+	if errors.Is(err, errNoID3v2Tag) {
+		return newID3Tag(), nil
+	}
+	return tag, err
+}
+
+// Replaces the ID3v2 tag at the start of the file. If the new tag
+// fits into the space of the old tag including its padding, only
+// the tag region is overwritten. Otherwise the file is written to
+// a temp file next to it, which then atomically replaces the file.
+func writeID3Tag(mp3Filepath string, tag *id3Tag) (err error) {
+	frames, err := tag.encodeFrames(tag.frames)
+	if err != nil {
+		return err
+	}
+	if tag.length > 0 && int64(id3v2HeaderLength+len(frames)) <= tag.length {
+		return overwriteID3Tag(mp3Filepath, tag)
+	}
+
+	encodedTag, err := tag.encode(defaultID3v2Padding)
+	if err != nil {
+		return err
+	}
+	tempFilePath, err := createFileWithTag(mp3Filepath, tag.length, encodedTag)
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tempFilePath, mp3Filepath); err != nil {
+		deleteFile(tempFilePath)
+		return err
+	}
+	tag.length = int64(len(encodedTag))
+	return nil
+}
+
+// Writes the tag over the existing one, using the remaining
+// space as padding. The rest of the file is not touched.
+func overwriteID3Tag(mp3Filepath string, tag *id3Tag) (err error) {
+	frames, err := tag.encodeFrames(tag.frames)
+	if err != nil {
+		return err
+	}
+	encodedTag, err := tag.encode(int(tag.length) - id3v2HeaderLength - len(frames))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(mp3Filepath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if _, err = file.WriteAt(encodedTag, 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Creates a temp file next to the file containing the encoded tag
+// followed by the content of the file after the old tag.
+func createFileWithTag(mp3Filepath string, oldTagLength int64, encodedTag []byte) (tempFilePath string, err error) {
+	source, err := os.Open(mp3Filepath)
+	if err != nil {
+		return "", err
+	}
+	defer closeFile(source)
+	sourceInfo, err := source.Stat()
+	if err != nil {
+		return "", err
+	}
+	if _, err = source.Seek(oldTagLength, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	target, err := os.CreateTemp(filepath.Dir(mp3Filepath), filepath.Base(mp3Filepath)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			closeFile(target)
+			deleteFile(target.Name())
+		}
+	}()
+
+	if _, err = target.Write(encodedTag); err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(target, source); err != nil {
+		return "", err
+	}
+	if err = target.Chmod(sourceInfo.Mode()); err != nil {
+		return "", err
+	}
+	if err = target.Sync(); err != nil {
+		return "", err
+	}
+	return target.Name(), target.Close()
+}
+
+func (t *id3Tag) getExtendedHeaderLength(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, errors.New("invalid ID3v2 extended header")
+	}
+	var length int
+	if t.version == 3 {
+		// size excludes the size field itself
+		length = int(binary.BigEndian.Uint32(data[:4])) + 4
+	} else {
+		size, ok := decodeSyncSafe(data[:4])
+		if !ok {
+			return 0, errors.New("invalid ID3v2 extended header")
+		}
+		length = size
+	}
+	if length > len(data) {
+		return 0, errors.New("invalid ID3v2 extended header size")
+	}
+	return length, nil
+}
+
+// Parses frames until the end of the data or the start of the padding.
+// The frames of sub frame sections (e.g. CHAP) are parsed the same way.
+func (t *id3Tag) parseFrames(data []byte, unsynchronised bool) ([]id3Frame, error) {
+	result := make([]id3Frame, 0)
+	for len(data) >= id3v2FrameHeaderLength && data[0] != 0 {
+		id := string(data[:id3v2FrameIDLength])
+		var size int
+		if t.version == 3 {
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+		} else {
+			var ok bool
+			if size, ok = decodeSyncSafe(data[4:8]); !ok {
+				return nil, fmt.Errorf("invalid size of ID3v2 frame %s", id)
+			}
+		}
+		if id3v2FrameHeaderLength+size > len(data) {
+			return nil, fmt.Errorf("ID3v2 frame %s exceeds tag size", id)
+		}
+
+		frame := id3Frame{
+			id:    id,
+			flags: [2]byte{data[8], data[9]},
+			data:  append([]byte(nil), data[id3v2FrameHeaderLength:id3v2FrameHeaderLength+size]...),
+		}
+		// ID3v2.4 applies unsynchronisation per frame
+		if t.version == 4 && (unsynchronised || frame.flags[1]&id3v24UnsynchronisedFlag != 0) {
+			frame.data = removeUnsynchronisation(frame.data)
+			frame.flags[1] &^= id3v24UnsynchronisedFlag
+		}
+		result = append(result, frame)
+		data = data[id3v2FrameHeaderLength+size:]
+	}
+	return result, nil
+}
+
+// Returns the encoded tag including its header. The tag
+// is written without unsynchronisation and extended header.
+func (t *id3Tag) encode(padding int) ([]byte, error) {
+	frames, err := t.encodeFrames(t.frames)
+	if err != nil {
+		return nil, err
+	}
+	size := len(frames) + padding
+	if size > id3v2MaxSyncSafeValue {
+		return nil, fmt.Errorf("ID3v2 tag with %d bytes is too large", size)
+	}
+
+	var buffer bytes.Buffer
+	buffer.Write(id3v2TagIdentifier)
+	buffer.Write([]byte{t.version, 0, 0})
+	buffer.Write(encodeSyncSafe(size))
+	buffer.Write(frames)
+	buffer.Write(make([]byte, padding))
+	return buffer.Bytes(), nil
+}
+
+func (t *id3Tag) encodeFrames(frames []id3Frame) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, frame := range frames {
+		if len(frame.id) != id3v2FrameIDLength {
+			return nil, fmt.Errorf("invalid ID3v2 frame id '%s'", frame.id)
+		}
+		buffer.WriteString(frame.id)
+		if t.version == 3 {
+			buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(frame.data))))
+		} else {
+			if len(frame.data) > id3v2MaxSyncSafeValue {
+				return nil, fmt.Errorf("ID3v2 frame %s is too large", frame.id)
+			}
+			buffer.Write(encodeSyncSafe(len(frame.data)))
+		}
+		buffer.Write(frame.flags[:])
+		buffer.Write(frame.data)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Returns the frame content without the additional data
+// added by format flags. Returns false if the content is
+// compressed or encrypted.
+func (t *id3Tag) getPayload(frame id3Frame) ([]byte, bool) {
+	data := frame.data
+	formatFlags := frame.flags[1]
+	if t.version == 3 {
+		if formatFlags&(id3v23CompressionFlag|id3v23EncryptionFlag) != 0 {
+			return nil, false
+		}
+		if formatFlags&id3v23GroupingFlag != 0 && len(data) > 0 {
+			data = data[1:]
+		}
+		return data, true
+	}
+
+	if formatFlags&(id3v24CompressionFlag|id3v24EncryptionFlag) != 0 {
+		return nil, false
+	}
+	if formatFlags&id3v24GroupingFlag != 0 && len(data) > 0 {
+		data = data[1:]
+	}
+	if formatFlags&id3v24DataLengthIndicFlag != 0 && len(data) >= 4 {
+		data = data[4:]
+	}
+	return data, true
+}
+
+// Returns the payloads of all frames with the given id.
+func (t *id3Tag) getPayloads(id string) [][]byte {
+	result := make([][]byte, 0)
+	for _, frame := range t.frames {
+		if frame.id != id {
+			continue
+		}
+		if payload, ok := t.getPayload(frame); ok {
+			result = append(result, payload)
+		}
+	}
+	return result
+}
+
+// Removes all frames with one of the given ids.
+func (t *id3Tag) removeFrames(ids ...string) {
+	result := make([]id3Frame, 0, len(t.frames))
+	for _, frame := range t.frames {
+		remove := false
+		for _, id := range ids {
+			if frame.id == id {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			result = append(result, frame)
+		}
+	}
+	t.frames = result
+}
+
+// Creates the payload of a text information frame like TIT2.
+func (t *id3Tag) encodeTextFrame(text string) []byte {
+	encoding := t.getTextEncoding(text)
+	return append([]byte{encoding}, encodeText(text, encoding, false)...)
+}
+
+// Decodes the payload of a text information frame like TIT2.
+// Multiple values are separated by a slash.
+func decodeTextFrame(payload []byte) string {
+	if len(payload) < 1 {
+		return ""
+	}
+	values := splitText(payload[1:], payload[0])
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return strings.Join(result, "/")
+}
+
+// ID3v2.3 does not support UTF-8, therefore ISO-8859-1
+// is used if possible and UTF-16 otherwise.
+func (t *id3Tag) getTextEncoding(text string) byte {
+	if t.version == 4 {
+		return textEncodingUTF8
+	}
+	for _, r := range text {
+		if r > 0xFF {
+			return textEncodingUTF16
+		}
+	}
+	return textEncodingISO88591
+}
+
+// Encodes the text, optionally followed by the terminator of the encoding.
+func encodeText(text string, encoding byte, terminate bool) []byte {
+	var result []byte
+	switch encoding {
+	case textEncodingUTF16, textEncodingUTF16BE:
+		if encoding == textEncodingUTF16 {
+			// little endian byte order mark
+			result = append(result, 0xFF, 0xFE)
+		}
+		for _, unit := range utf16.Encode([]rune(text)) {
+			if encoding == textEncodingUTF16 {
+				result = binary.LittleEndian.AppendUint16(result, unit)
+			} else {
+				result = binary.BigEndian.AppendUint16(result, unit)
+			}
+		}
+		if terminate {
+			result = append(result, 0, 0)
+		}
+	case textEncodingUTF8:
+		result = append(result, text...)
+		if terminate {
+			result = append(result, 0)
+		}
+	default:
+		for _, r := range text {
+			if r > 0xFF {
+				r = '?'
+			}
+			result = append(result, byte(r))
+		}
+		if terminate {
+			result = append(result, 0)
+		}
+	}
+	return result
+}
+
+// Reads a terminated string and returns it together with the remaining data.
+// If there is no terminator the complete data is interpreted as string.
+func readTerminatedText(data []byte, encoding byte) (text string, rest []byte) {
+	terminatorLength := 1
+	if encoding == textEncodingUTF16 || encoding == textEncodingUTF16BE {
+		terminatorLength = 2
+	}
+	for i := 0; i+terminatorLength <= len(data); i += terminatorLength {
+		if data[i] == 0 && (terminatorLength == 1 || data[i+1] == 0) {
+			return decodeText(data[:i], encoding), data[i+terminatorLength:]
+		}
+	}
+	return decodeText(data, encoding), nil
+}
+
+// Splits the data at the terminators of the encoding.
+func splitText(data []byte, encoding byte) []string {
+	result := make([]string, 0, 1)
+	for len(data) > 0 {
+		var text string
+		text, data = readTerminatedText(data, encoding)
+		result = append(result, text)
+	}
+	return result
+}
+
+func decodeText(data []byte, encoding byte) string {
+	switch encoding {
+	case textEncodingUTF16, textEncodingUTF16BE:
+		var byteOrder binary.ByteOrder = binary.BigEndian
+		if encoding == textEncodingUTF16 && len(data) >= 2 {
+			if data[0] == 0xFF && data[1] == 0xFE {
+				byteOrder = binary.LittleEndian
+				data = data[2:]
+			} else if data[0] == 0xFE && data[1] == 0xFF {
+				data = data[2:]
+			}
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, byteOrder.Uint16(data[i:]))
+		}
+		return string(utf16.Decode(units))
+	case textEncodingUTF8:
+		return string(bytes.ToValidUTF8(data, []byte(string(utf8.RuneError))))
+	default:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+}
+
+// Reverts the unsynchronisation scheme, which inserts
+// a zero byte after each 0xFF byte.
+func removeUnsynchronisation(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		result = append(result, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return result
+}
+
+func encodeSyncSafe(value int) []byte {
+	return []byte{
+		byte(value>>21) & 0x7F,
+		byte(value>>14) & 0x7F,
+		byte(value>>7) & 0x7F,
+		byte(value) & 0x7F,
+	}
+}
@@ -0,0 +1,120 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_readID3Tag(t *testing.T) {
+	// TIT2 frame with the ISO-8859-1 text "ÿ" which requires unsynchronisation
+	titleFrame := []byte{'T', 'I', 'T', '2', 0, 0, 0, 2, 0, 0, textEncodingISO88591, 0xFF}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantTitle string
+		wantErr   error
+	}{
+		{
+			name:      "ID3v2.3 with unsynchronisation and extended header",
+			data:      createTestTag(3, id3v2UnsynchronisationFlag|id3v2ExtendedHeaderFlag, []byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0}, titleFrame, []byte{0x00}),
+			wantTitle: "ÿ",
+		}, {
+			name:      "ID3v2.4 with unsynchronised frame",
+			data:      createTestTag(4, 0, nil, []byte{'T', 'I', 'T', '2', 0, 0, 0, 3, 0, id3v24UnsynchronisedFlag, textEncodingISO88591, 0xFF, 0x00}),
+			wantTitle: "ÿ",
+		}, {
+			name:      "ID3v2.4 with padding",
+			data:      createTestTag(4, 0, nil, []byte{'T', 'I', 'T', '2', 0, 0, 0, 3, 0, 0, textEncodingUTF8, 'h', 'i'}, make([]byte, 20)),
+			wantTitle: "hi",
+		}, {
+			name:    "no tag",
+			data:    createTestFrames(testMPEG1FrameHeader, 1),
+			wantErr: errNoID3v2Tag,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := readID3Tag(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readID3Tag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if tag.length != int64(len(tt.data)) {
+				t.Errorf("readID3Tag() length = %v, want %v", tag.length, len(tt.data))
+			}
+			titles := tag.getPayloads(titleFrameID)
+			if len(titles) != 1 || decodeTextFrame(titles[0]) != tt.wantTitle {
+				t.Errorf("readID3Tag() found titles %v, want %v", titles, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func Test_id3Tag_encode(t *testing.T) {
+	for _, version := range []byte{3, 4} {
+		tag := newID3Tag()
+		tag.version = version
+		tag.frames = append(tag.frames,
+			id3Frame{id: titleFrameID, data: tag.encodeTextFrame("Ünïcödé ☺")},
+			id3Frame{id: "TPE1", data: tag.encodeTextFrame("Artist")},
+		)
+
+		encoded, err := tag.encode(100)
+		if err != nil {
+			t.Fatalf("id3Tag.encode() error = %v", err)
+		}
+		decoded, err := readID3Tag(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("readID3Tag() error = %v", err)
+		}
+		if decoded.length != int64(len(encoded)) {
+			t.Errorf("id3Tag.encode() length = %v, want %v", decoded.length, len(encoded))
+		}
+		decoded.length = 0
+		if !reflect.DeepEqual(decoded, tag) {
+			t.Errorf("id3Tag.encode() v2.%d round trip = %v, want %v", version, decoded, tag)
+		}
+	}
+}
+
+func Test_decodeTextFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    string
+	}{
+		{"ISO-8859-1", []byte{textEncodingISO88591, 'a', 0xE4}, "aä"},
+		{"UTF-16 little endian", []byte{textEncodingUTF16, 0xFF, 0xFE, 'h', 0, 'i', 0, 0, 0}, "hi"},
+		{"UTF-16 big endian with BOM", []byte{textEncodingUTF16, 0xFE, 0xFF, 0, 'h', 0, 'i'}, "hi"},
+		{"UTF-16BE", []byte{textEncodingUTF16BE, 0x26, 0x3A}, "☺"},
+		{"UTF-8 multiple values", []byte{textEncodingUTF8, 'a', 0, 'b', 0}, "a/b"},
+		{"empty", []byte{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeTextFrame(tt.payload); got != tt.want {
+				t.Errorf("decodeTextFrame() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_removeUnsynchronisation(t *testing.T) {
+	input := []byte{0xFF, 0x00, 0xE0, 0xFF, 0x00, 0x00, 0xFF}
+	want := []byte{0xFF, 0xE0, 0xFF, 0x00, 0xFF}
+	if got := removeUnsynchronisation(input); !bytes.Equal(got, want) {
+		t.Errorf("removeUnsynchronisation() = %v, want %v", got, want)
+	}
+}
+
+func createTestTag(version byte, flags byte, parts ...[]byte) []byte {
+	data := bytes.Join(parts, nil)
+	header := []byte{'I', 'D', '3', version, 0, flags}
+	header = append(header, encodeSyncSafe(len(data))...)
+	return append(header, data...)
+}
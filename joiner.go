@@ -1,145 +1,462 @@
 package mp3joiner
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strconv"
-	"strings"
 )
 
 type segment struct {
-	File     string
+	File string
+	// lavfi source of generated audio, used instead of the file
+	Source   string
 	Start    float64
 	Duration float64
+	// chapters relative to the start of the segment
+	Chapters []Chapter
+	// properties of the audio stream of the file
+	Stream stream
+	// crossfade from the previous segment, see MP3Builder.SetCrossfade
+	Crossfade *Fade
+	// applied in order before joining, see MP3Builder.SetFilters
+	Filters []AudioFilter
 }
 
 type MP3Builder struct {
+	options  *options
 	streams  []segment
-	chapters []Chapter
 	metaData map[string]string
-	bitrate  int
+	// measured by the last build with loudness normalization
+	loudness []LoudnessStats
 }
 
 // Builder that holds the added MP3 sections
-func NewMP3Builder() *MP3Builder {
+func NewMP3Builder(opts ...Option) *MP3Builder {
 	return &MP3Builder{
+		options: newOptions(opts),
 		streams: make([]segment, 0),
 	}
 }
 
-// Creates the MP3 file a the chosen path
+// Creates the MP3 file a the chosen path.
+// Other formats can be created with WithOutputFormat.
 func (b *MP3Builder) Build(filePath string) (err error) {
+	return b.BuildContext(context.Background(), filePath)
+}
+
+// Same as Build but stops ffmpeg once the context is done.
+// In case of an error a partially written output file is removed.
+func (b *MP3Builder) BuildContext(ctx context.Context, filePath string) (err error) {
 	if len(b.streams) < 1 {
 		return fmt.Errorf("no streams to persist")
 	}
 
-	b.chapters = mergeChapters(b.chapters)
-	tempMetadataFile, err := createTempMetadataFile(b.metaData, b.chapters)
+	format := getOutputFormat(b.options.outputFormat, filePath)
+	if b.options.buildMode == BuildModeStreamCopy && format != OutputFormatMP3 {
+		return fmt.Errorf("stream copy is not supported for %s output", format)
+	}
+	if b.options.buildMode == BuildModeStreamCopy && b.hasTransitions() {
+		return fmt.Errorf("fades and crossfades are not supported in stream copy mode")
+	}
+	if b.options.buildMode == BuildModeStreamCopy && b.hasFilters() {
+		return fmt.Errorf("segment filters are not supported in stream copy mode")
+	}
+	if b.options.buildMode == BuildModeStreamCopy && b.options.loudness != nil {
+		return fmt.Errorf("loudness normalization is not supported in stream copy mode")
+	}
+	if err = b.validateTransitions(); err != nil {
+		return err
+	}
+	if b.options.loudness != nil {
+		if err = b.options.loudness.validate(); err != nil {
+			return err
+		}
+	}
+	for _, chapterFileFormat := range b.options.chapterFiles {
+		if _, err = getChapterFileEncoder(chapterFileFormat); err != nil {
+			return err
+		}
+	}
+	joinedSampleRate, _ := strconv.Atoi(b.getJoinedSampleRate())
+	if err = b.options.encodeOptions.validate(format, joinedSampleRate); err != nil {
+		return err
+	}
+	metadata, err := b.getOutputMetadata(format)
+	if err != nil {
+		return err
+	}
+
+	chapters := b.getChapters()
+	tempMetadataFile, err := createTempMetadataFile(metadata, chapters)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		deleteFile(tempMetadataFile)
-		// check if copying was successful
+	defer deleteFile(tempMetadataFile)
+
+	// only clean up the output if it has not existed before,
+	// ffmpeg does not overwrite existing files
+	if _, statErr := os.Stat(filePath); os.IsNotExist(statErr) {
+		defer func() {
+			if err != nil {
+				deleteFile(filePath)
+			}
+		}()
+	}
+
+	var args []string
+	switch b.options.buildMode {
+	case BuildModeStreamCopy:
+		var tempFiles []string
+		args, tempFiles, err = b.getStreamCopyArgs(ctx, tempMetadataFile, format)
+		defer func() {
+			for _, tempFile := range tempFiles {
+				deleteFile(tempFile)
+			}
+		}()
 		if err != nil {
-			return
+			return err
+		}
+	default:
+		b.loudness = nil
+		if b.options.loudness != nil {
+			if b.loudness, err = b.measureLoudness(ctx); err != nil {
+				return err
+			}
 		}
-	}()
+		args = b.getReencodeArgs(tempMetadataFile, format)
+	}
+
+	if b.options.progress != nil {
+		args = append(args, "-progress", "pipe:1", "-nostats")
+	}
+	args = append(args, filePath)
 
+	output, runErr := b.runFFmpeg(ctx, args)
+	if runErr != nil {
+		return fmt.Errorf("ffmpeg build failed: %w - output: %s", runErr, output.Stderr)
+	}
+	if len(b.loudness) > 0 {
+		if err = b.setNormalizedLoudness(output.Stderr); err != nil {
+			return err
+		}
+	}
+
+	// ffmpeg only writes chapter titles
+	if format == OutputFormatMP3 && hasChapterDetails(chapters) {
+		if err = WriteID3Chapters(filePath, chapters, nil); err != nil {
+			return err
+		}
+	}
+	if b.options.cueSheet {
+		if err = WriteCueSheet(getCueSheetPath(filePath), &CueSheet{File: filePath, Metadata: b.metaData, Chapters: chapters}); err != nil {
+			return err
+		}
+	}
+	for _, format := range b.options.chapterFiles {
+		if err = writeChapterFile(filePath, format, chapters); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the tags written into the output. For Opus output the
+// cover art is added as tag.
+func (b *MP3Builder) getOutputMetadata(format OutputFormat) (map[string]string, error) {
+	if b.options.coverArt == "" || format.hasCoverArtInput() {
+		return b.metaData, nil
+	}
+	picture, err := getPictureMetadata(b.options.coverArt)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(b.metaData)+1)
+	for key, value := range b.metaData {
+		result[key] = value
+	}
+	result[pictureMetadataKey] = picture
+	return result, nil
+}
+
+// Returns the ffmpeg input arguments of the cover art
+// if it is read as separate input.
+func (b *MP3Builder) getCoverArtInputArgs(format OutputFormat) []string {
+	if b.options.coverArt == "" || !format.hasCoverArtInput() {
+		return nil
+	}
+	return []string{"-i", b.options.coverArt}
+}
+
+// Returns the ffmpeg arguments, without the output path, to cut
+// all segments, concat them and encode the result.
+func (b *MP3Builder) getReencodeArgs(metadataFile string, format OutputFormat) []string {
 	// Build ffmpeg args to trim inputs and concat
 	args := make([]string, 0, 32+(len(b.streams)*6))
 	for _, s := range b.streams {
-		args = append(args,
-			"-ss", formatSeconds(s.Start),
-			"-t", formatSeconds(s.Duration),
-			"-i", s.File,
-		)
+		args = append(args, s.getInputArgs()...)
 	}
 
 	// Add metadata ffmetadata input; index is after the N audio inputs
-	args = append(args, "-i", tempMetadataFile)
+	args = append(args, "-i", metadataFile)
+	coverArtInputArgs := b.getCoverArtInputArgs(format)
+	args = append(args, coverArtInputArgs...)
 
 	// Build filter_complex: [0:a][1:a]...concat=n=N:v=0:a=1[aout]
-	var sb strings.Builder
-	for i := range b.streams {
-		sb.WriteString("[" + strconv.Itoa(i) + ":a]")
-	}
-	sb.WriteString(fmt.Sprintf("concat=n=%d:v=0:a=1[aout]", len(b.streams)))
-	filter := sb.String()
-	args = append(args, "-filter_complex", filter)
-	args = append(args, "-map", "[aout]")
+	args = append(args, "-filter_complex", b.getFilterGraph())
+	args = append(args, "-map", filterGraphOutput)
 	metadataIndex := len(b.streams) // metadata file comes after N stream inputs
 	args = append(args,
 		"-map_metadata", strconv.Itoa(metadataIndex),
 		"-map_chapters", strconv.Itoa(metadataIndex),
 	)
 
-	// Set audio codec/bitrate and output path
-	args = append(args,
-		"-c:a", "libmp3lame",
-		"-b:a", fmt.Sprintf("%dk", int(b.bitrate/1000)),
-		filePath,
-	)
+	if len(coverArtInputArgs) > 0 {
+		args = append(args, getCoverArtArgs(metadataIndex+1)...)
+	}
+
+	// Set audio codec/bitrate
+	return append(args, format.getCodecArgs(b.getEncodeOptions())...)
+}
 
-	if output, runErr := runCmd("ffmpeg", args...); runErr != nil {
-		return fmt.Errorf("ffmpeg build failed: %w - output: %s", runErr, output)
+// Returns the file or the source of generated audio.
+func (s segment) getName() string {
+	if s.Source != "" {
+		return s.Source
 	}
-	return nil
+	return s.File
+}
+
+// Returns the ffmpeg input arguments to read the segment.
+func (s segment) getInputArgs() []string {
+	if s.Source != "" {
+		return []string{"-f", "lavfi", "-t", formatSeconds(s.Duration), "-i", s.Source}
+	}
+	return []string{"-ss", formatSeconds(s.Start), "-t", formatSeconds(s.Duration), "-i", s.File}
+}
+
+// Runs ffmpeg and reports its progress if a callback is set.
+func (b *MP3Builder) runFFmpeg(ctx context.Context, args []string) (ExecResult, error) {
+	if b.options.progress == nil {
+		return runCmd(ctx, b.options.executor, "ffmpeg", args...)
+	}
+	writer := newProgressWriter(b.getTotalDuration(), b.options.progress)
+	return runCmdStreaming(ctx, b.options.executor, writer, "ffmpeg", args...)
+}
+
+// Returns the expected length of the output in seconds.
+// Crossfades overlap segments and shorten the output,
+// tempo filters change the length of their segment.
+func (b *MP3Builder) getTotalDuration() (result float64) {
+	for i, s := range b.streams {
+		result += s.getOutputDuration() - b.getCrossfade(i).Duration
+	}
+	return result
 }
 
 // Adds a MP3 file to the builder.
 // If endInSeconds is set to "-1" the stream will be read until the end of the file.
 func (b *MP3Builder) Append(mp3Filepath string, startInSeconds float64, endInSeconds float64) (err error) {
+	return b.AppendContext(context.Background(), mp3Filepath, startInSeconds, endInSeconds)
+}
+
+// Same as Append but stops probing the file once the context is done.
+func (b *MP3Builder) AppendContext(ctx context.Context, mp3Filepath string, startInSeconds float64, endInSeconds float64) (err error) {
+	s, err := b.newFileSegment(ctx, mp3Filepath, startInSeconds, endInSeconds)
+	if err != nil {
+		return err
+	}
+	b.streams = append(b.streams, s)
+	return nil
+}
+
+// Properties of a file shared by all segments of the file.
+type fileProbe struct {
+	length   float64
+	chapters []Chapter
+	stream   stream
+}
+
+// Probes the file and returns the segment of the range with its chapters.
+func (b *MP3Builder) newFileSegment(ctx context.Context, mp3Filepath string, startInSeconds float64, endInSeconds float64) (result segment, err error) {
 	// input validation test
 	if endInSeconds != -1 && startInSeconds > endInSeconds {
-		return fmt.Errorf("start %v set after end %v", startInSeconds, endInSeconds)
+		return result, fmt.Errorf("start %v set after end %v", startInSeconds, endInSeconds)
 	}
-
-	// set end to last position
-	length, err := GetLengthInSeconds(mp3Filepath)
+	probe, err := b.probeFile(ctx, mp3Filepath)
 	if err != nil {
-		return err
+		return result, err
 	}
-	endPos := length
-	// set defined pos is not set to -1 end and end is in length of mp3
-	if endInSeconds != -1 && endInSeconds < length {
-		endPos = float64(endInSeconds)
+	return b.newProbedSegment(ctx, mp3Filepath, probe, startInSeconds, endInSeconds)
+}
+
+// Reads length, chapters and stream properties of the file. The tags
+// of the first probed file become the global tags of the output.
+func (b *MP3Builder) probeFile(ctx context.Context, mp3Filepath string) (result fileProbe, err error) {
+	if result.length, err = b.getLengthInSeconds(ctx, mp3Filepath); err != nil {
+		return result, err
 	}
 
 	// retrieve chapters
-	allChapters, err := GetChapterMetadata(mp3Filepath)
-	if err != nil {
-		return err
+	if result.chapters, err = readChapters(ctx, b.options, mp3Filepath); err != nil {
+		return result, err
 	}
-	chaptersInTimeFrame := getChapterInTimeFrame(allChapters, startInSeconds, endPos)
-	b.chapters = chaptersInTimeFrame
 
-	// cache segment definition (use -ss/-t before -i for each segment)
+	if b.metaData == nil {
+		metadata, err := getFFmpegMetadataTag(ctx, b.options, mp3Filepath)
+		if err != nil {
+			return result, err
+		}
+		b.metaData = metadata
+	}
+	if result.stream, err = getStreamInfo(ctx, b.options, mp3Filepath); err != nil {
+		return result, err
+	}
+	if _, err = result.stream.getTargetBitrate(); err != nil {
+		return result, err
+	}
+	if len(result.chapters) == 0 && b.options.silenceChapters != nil {
+		result.chapters, err = detectSilenceChapters(ctx, b.options, mp3Filepath, result.stream, *b.options.silenceChapters, result.length)
+	}
+	return result, err
+}
+
+// Returns the segment of the range of the probed file.
+// If endInSeconds is set to "-1" the segment ends with the file.
+func (b *MP3Builder) newProbedSegment(ctx context.Context, mp3Filepath string, probe fileProbe, startInSeconds float64, endInSeconds float64) (result segment, err error) {
+	// set end to last position
+	endPos := probe.length
+	// set defined pos is not set to -1 end and end is in length of mp3
+	if endInSeconds != -1 && endInSeconds < probe.length {
+		endPos = float64(endInSeconds)
+	}
 	duration := endPos - startInSeconds
 	if duration < 0 {
-		return fmt.Errorf("calculated negative duration")
+		return result, fmt.Errorf("calculated negative duration")
 	}
-	b.streams = append(b.streams, segment{
+
+	// cache segment definition (use -ss/-t before -i for each segment)
+	result = segment{
 		File:     mp3Filepath,
 		Start:    startInSeconds,
 		Duration: duration,
-	})
+		Chapters: rebaseChapters(getChapterInTimeFrame(probe.chapters, startInSeconds, endPos), -startInSeconds),
+		Stream:   probe.stream,
+	}
 
-	if b.metaData == nil {
-		metadata, err := GetFFmpegMetadataTag(mp3Filepath)
-		if err != nil {
-			return err
-		}
-		b.metaData = metadata
+	if b.options.silenceTrim != nil {
+		err = b.trimSilence(ctx, &result, *b.options.silenceTrim)
 	}
-	bitrate, err := GetBitrate(mp3Filepath)
+	return result, err
+}
+
+// Appends the complete audio file referenced by the CUE sheet using
+// the tracks of the sheet as chapters. If it is the first appended
+// file, the tags of the sheet are added to the global tags.
+func (b *MP3Builder) AppendCueSheet(cueFilepath string) (err error) {
+	return b.AppendCueSheetContext(context.Background(), cueFilepath)
+}
+
+// Same as AppendCueSheet but stops ffmpeg once the context is done.
+func (b *MP3Builder) AppendCueSheetContext(ctx context.Context, cueFilepath string) (err error) {
+	sheet, err := readCueSheet(ctx, b.options, cueFilepath)
 	if err != nil {
 		return err
 	}
-	if bitrate > b.bitrate {
-		b.bitrate = bitrate
+
+	isFirst := len(b.streams) == 0
+	if err = b.AppendContext(ctx, sheet.File, 0, -1); err != nil {
+		return err
 	}
+	s := &b.streams[len(b.streams)-1]
+	s.Chapters = rebaseChapters(getChapterInTimeFrame(sheet.Chapters, 0, s.Duration), 0)
 
-	return err
+	if isFirst && b.metaData == nil {
+		b.metaData = make(map[string]string, len(sheet.Metadata))
+	}
+	if isFirst {
+		for key, value := range sheet.Metadata {
+			b.metaData[key] = value
+		}
+	}
+	return nil
+}
+
+// Reads the length from the MP3 frames if possible and only
+// decodes the file if it can not be read by the frame scanner.
+func (b *MP3Builder) getLengthInSeconds(ctx context.Context, mp3Filepath string) (float64, error) {
+	if info, err := GetMP3Info(mp3Filepath); err == nil {
+		return info.Duration, nil
+	}
+	return getLengthInSeconds(ctx, b.options, mp3Filepath)
+}
+
+// Reads the chapters natively depending on the container, from the
+// ID3v2 tag, the MP4 atoms or the Vorbis comments. The ID3v2 tag keeps
+// subtitles, links and images. Falls back to ffprobe otherwise.
+func readChapters(ctx context.Context, o *options, audioFilepath string) ([]Chapter, error) {
+	var chapters []Chapter
+	switch getContainer(audioFilepath) {
+	case ContainerMP4:
+		chapters, _ = ReadMP4Chapters(audioFilepath)
+	case ContainerFLAC, ContainerOgg:
+		chapters, _ = ReadVorbisChapters(audioFilepath)
+	default:
+		chapters, _, _ = ReadID3Chapters(audioFilepath)
+	}
+	if len(chapters) > 0 {
+		return chapters, nil
+	}
+	return getChapterMetadata(ctx, o, audioFilepath)
+}
+
+// Returns the chapters of all segments placed on the timeline
+// of the output file.
+func (b *MP3Builder) getChapters() []Chapter {
+	result := make([]Chapter, 0)
+	offset := 0.0
+	for i := range b.streams {
+		result = append(result, b.getSegmentChapters(i, offset)...)
+		offset += b.getSegmentOutputLength(i)
+	}
+	return mergeChapters(result)
+}
+
+// Returns the chapters of the segment at the index in the output,
+// where the segment starts at the offset in seconds.
+func (b *MP3Builder) getSegmentChapters(index int, offset float64) []Chapter {
+	return rebaseChapters(getChapterInTimeFrame(b.streams[index].getOutputChapters(), 0, b.getSegmentOutputLength(index)), offset)
+}
+
+// Returns the seconds until the next segment starts in the output.
+// Chapters end where the crossfade into the next segment starts.
+func (b *MP3Builder) getSegmentOutputLength(index int) float64 {
+	return b.streams[index].getOutputDuration() - b.getCrossfade(index+1).Duration
+}
+
+// Returns the encoder settings of re-encoded audio
+// with the bitrate set to the automatic choice if unset.
+func (b *MP3Builder) getEncodeOptions() EncodeOptions {
+	result := b.options.encodeOptions
+	if result.Bitrate <= 0 {
+		result.Bitrate = b.getAutomaticBitrate()
+	}
+	return result
+}
+
+// Returns the highest bitrate of all segments, so segments which
+// are removed or replaced no longer count.
+func (b *MP3Builder) getAutomaticBitrate() (result int) {
+	for _, s := range b.streams {
+		// generated audio has no bitrate of its own
+		if s.Source != "" {
+			continue
+		}
+		if bitrate, err := s.Stream.getTargetBitrate(); err == nil {
+			result = max(result, bitrate)
+		}
+	}
+	return result
 }
 
 func formatSeconds(v float64) string {
@@ -1,10 +1,14 @@
 package mp3joiner
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"testing"
 )
@@ -212,6 +216,151 @@ func TestMP3Builder_Build(t *testing.T) {
 	}
 }
 
+func TestMP3Builder_getChapters(t *testing.T) {
+	builder := NewMP3Builder()
+	builder.streams = []segment{
+		{
+			File:     "first.mp3",
+			Duration: 10,
+			Chapters: []Chapter{
+				{TimeBase: "1/1000", Start: 0, End: 4000, Tags: Tags{Title: "Intro"}},
+				{TimeBase: "1/1000", Start: 4000, End: 10000, Tags: Tags{Title: "Story"}},
+			},
+		}, {
+			File:     "second.mp3",
+			Duration: 5,
+			Chapters: []Chapter{
+				{TimeBase: "1/1", Start: 0, End: 5, Tags: Tags{Title: "Outro"}},
+			},
+		},
+	}
+
+	chapters := builder.getChapters()
+
+	expected := []struct {
+		title      string
+		start, end float64
+	}{
+		{"Intro", 0, 4},
+		{"Story", 4, 10},
+		{"Outro", 10, 15},
+	}
+	if len(chapters) != len(expected) {
+		t.Fatalf("MP3Builder.getChapters() expected %v chapters, found %v", len(expected), len(chapters))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || chapter.TimeBase != DEFAULT_TIME_BASE ||
+			chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("MP3Builder.getChapters() chapter %d = %v, want %v", i, chapter, want)
+		}
+	}
+}
+
+func TestMP3Builder_BuildWithExecutor(t *testing.T) {
+	executions := appendExecutions("00:00:10.00", `[{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Intro"}}]`, `{"bit_rate":"64000"}`, true)
+	executions = append(executions, appendExecutions("00:00:20.00", `[]`, `{"bit_rate":"128000"}`, false)...)
+	executions = append(executions, Execution{Name: "ffmpeg"})
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	if err := builder.Append("first.mp3", 2, 5); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Append("second.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Build("out.mp3"); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("MP3Builder.Build() expected all executions to be used, %v remaining", executor.Remaining())
+	}
+
+	calls := executor.Calls()
+	args := calls[len(calls)-1].Args
+	expectedSequences := [][]string{
+		{"-ss", "2.000", "-t", "3.000", "-i", "first.mp3"},
+		{"-ss", "0.000", "-t", "20.000", "-i", "second.mp3"},
+		{"-filter_complex", "[0:a][1:a]concat=n=2:v=0:a=1[aout]"},
+		{"-c:a", "libmp3lame", "-b:a", "128k", "out.mp3"},
+	}
+	for _, sequence := range expectedSequences {
+		if !containsSequence(args, sequence) {
+			t.Errorf("MP3Builder.Build() expected arguments %v in %v", sequence, args)
+		}
+	}
+}
+
+func TestMP3Builder_BuildWithProgress(t *testing.T) {
+	executions := appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"64000"}`, true)
+	executions = append(executions, Execution{Name: "ffmpeg", Result: ExecResult{
+		Stdout: "out_time_us=5000000\nspeed=10x\nprogress=continue\nout_time_us=10000000\nprogress=end\n",
+	}})
+	executor := NewReplayExecutor(executions...)
+
+	reports := make([]Progress, 0)
+	builder := NewMP3Builder(WithExecutor(executor), WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Build("out.mp3"); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	if !containsSequence(calls[len(calls)-1].Args, []string{"-progress", "pipe:1"}) {
+		t.Errorf("MP3Builder.Build() expected progress arguments, found %v", calls[len(calls)-1].Args)
+	}
+	if len(reports) != 2 || reports[0].Percent != 50 || !reports[1].Done || reports[1].Percent != 100 {
+		t.Errorf("MP3Builder.Build() unexpected progress reports %v", reports)
+	}
+}
+
+func TestMP3Builder_BuildContextCancel(t *testing.T) {
+	executor := NewReplayExecutor(appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"64000"}`, true)...)
+	builder := NewMP3Builder(WithExecutor(executor))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	outputPath := generateMP3FileName(t)
+	if err := builder.BuildContext(ctx, outputPath); !errors.Is(err, context.Canceled) {
+		t.Errorf("MP3Builder.BuildContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("MP3Builder.BuildContext() expected no output file, found %v", err)
+	}
+	if err := builder.AppendContext(ctx, "second.mp3", 0, -1); !errors.Is(err, context.Canceled) {
+		t.Errorf("MP3Builder.AppendContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// Returns the executions that are expected for a single MP3Builder.Append call.
+func appendExecutions(length string, chapters string, stream string, withMetadata bool) []Execution {
+	result := []Execution{
+		{Name: "ffmpeg", Result: ExecResult{Stderr: "size=N/A time=" + length + " bitrate=N/A speed=1x"}},
+		{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":` + chapters + `}`}},
+	}
+	if withMetadata {
+		result = append(result, Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"format":{"tags":{"title":"demo"}}}`}})
+	}
+	return append(result, Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[` + stream + `]}`}})
+}
+
+func containsSequence(args []string, sequence []string) bool {
+	for i := range args {
+		if len(args)-i >= len(sequence) && slices.Equal(args[i:i+len(sequence)], sequence) {
+			return true
+		}
+	}
+	return false
+}
+
 func getFileSizeInBytes(t *testing.T, filePath string) int64 {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -244,7 +393,7 @@ func getMP3TestFolder(t *testing.T) string {
 }
 
 func generateMP3FileName(t *testing.T) string {
-	filePath := filepath.Join(os.TempDir(), strconv.Itoa(random.Intn(9999999999999))+".mp3")
+	filePath := filepath.Join(os.TempDir(), strconv.Itoa(rand.Intn(9999999999999))+".mp3")
 	storeFileForCleanUp(t, filePath)
 	return filePath
 }
@@ -266,7 +415,7 @@ func createContainerWithDifferentFiles(t *testing.T, windows []SecondsWindow) *M
 	builder := NewMP3Builder()
 	filenames := make([]string, 0)
 	for range windows {
-		filename := fmt.Sprintf("%s.%s", filepath.Join(os.TempDir(), strconv.Itoa(random.Intn(9999999999999))), "mp3")
+		filename := fmt.Sprintf("%s.%s", filepath.Join(os.TempDir(), strconv.Itoa(rand.Intn(9999999999999))), "mp3")
 		err := copy(filepath.Join(getMP3TestFolder(t), testFileName), filename)
 		if err != nil {
 			t.Errorf("could copy file %v", err)
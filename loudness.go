@@ -0,0 +1,315 @@
+package mp3joiner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Defines which audio is normalized by WithLoudnessNormalization.
+type LoudnessScope int
+
+const (
+	// Normalizes the joined output as a whole, which keeps the
+	// loudness differences between segments.
+	LoudnessScopeOutput LoudnessScope = iota
+	// Normalizes each segment before it is joined, which evens
+	// out segments from different sources.
+	LoudnessScopeSegment
+)
+
+const (
+	defaultIntegratedLoudness = -16
+	defaultTruePeak           = -1.5
+	defaultLoudnessRange      = 11
+)
+
+// ffmpeg prints the stats of each loudnorm filter, e.g.
+// [Parsed_loudnorm_0 @ 0x5581c0a0] followed by a JSON object.
+var LOUDNORM_STATS_REGEX = regexp.MustCompile(`\[Parsed_loudnorm_(\d+) @ [^\]]*\]\s*(\{[^}]*\})`)
+
+// Loudness to which audio is normalized according to EBU R128.
+type LoudnessTarget struct {
+	// integrated loudness in LUFS, -16 if 0
+	IntegratedLoudness float64
+	// maximum true peak in dBTP, -1.5 if 0. As 0 selects the
+	// default, a limit of 0 dBTP is approximated with e.g. -0.01.
+	TruePeak float64
+	// loudness range in LU, 11 if 0
+	LoudnessRange float64
+	Scope         LoudnessScope
+}
+
+// Loudness of audio as measured by the loudnorm filter of ffmpeg.
+type Loudness struct {
+	// integrated loudness in LUFS
+	Integrated float64 `json:"integrated"`
+	// true peak in dBTP
+	TruePeak float64 `json:"true_peak"`
+	// loudness range in LU
+	LoudnessRange float64 `json:"loudness_range"`
+	// gating threshold in LUFS
+	Threshold float64 `json:"threshold"`
+}
+
+// Loudness of a normalized part of the output before and after
+// the normalization.
+type LoudnessStats struct {
+	// index of the normalized segment, -1 for the whole output
+	Segment int      `json:"segment"`
+	Input   Loudness `json:"input"`
+	Output  Loudness `json:"output"`
+	// gain in LU applied after the normalization to reach the target
+	TargetOffset float64 `json:"target_offset"`
+}
+
+// JSON printed by loudnorm with print_format=json.
+type loudnormOutput struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	OutputI      string `json:"output_i"`
+	OutputTP     string `json:"output_tp"`
+	OutputLRA    string `json:"output_lra"`
+	OutputThresh string `json:"output_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+func (t LoudnessTarget) getIntegratedLoudness() float64 {
+	if t.IntegratedLoudness == 0 {
+		return defaultIntegratedLoudness
+	}
+	return t.IntegratedLoudness
+}
+
+func (t LoudnessTarget) getTruePeak() float64 {
+	if t.TruePeak == 0 {
+		return defaultTruePeak
+	}
+	return t.TruePeak
+}
+
+func (t LoudnessTarget) getLoudnessRange() float64 {
+	if t.LoudnessRange == 0 {
+		return defaultLoudnessRange
+	}
+	return t.LoudnessRange
+}
+
+// Checks the target against the limits of the loudnorm filter.
+func (t LoudnessTarget) validate() error {
+	if i := t.getIntegratedLoudness(); i < -70 || i > -5 {
+		return fmt.Errorf("integrated loudness %v LUFS is not between -70 and -5", i)
+	}
+	if tp := t.getTruePeak(); tp < -9 || tp > 0 {
+		return fmt.Errorf("true peak %v dBTP is not between -9 and 0", tp)
+	}
+	if lra := t.getLoudnessRange(); lra < 1 || lra > 50 {
+		return fmt.Errorf("loudness range %v LU is not between 1 and 50", lra)
+	}
+	if t.Scope != LoudnessScopeOutput && t.Scope != LoudnessScopeSegment {
+		return fmt.Errorf("unknown loudness scope %d", t.Scope)
+	}
+	return nil
+}
+
+// Returns the loudnorm filter of the first pass, which only measures the loudness.
+func (t LoudnessTarget) getMeasureFilter() string {
+	return fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s:print_format=json",
+		formatLoudness(t.getIntegratedLoudness()), formatLoudness(t.getTruePeak()), formatLoudness(t.getLoudnessRange()))
+}
+
+// Returns the loudnorm filter of the second pass, which normalizes
+// the audio based on the measured stats. loudnorm resamples to
+// 192 kHz, so the audio is resampled to the given rate afterwards.
+func (t LoudnessTarget) getNormalizeFilter(measured LoudnessStats, sampleRate string) string {
+	result := fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true:print_format=json",
+		formatLoudness(t.getIntegratedLoudness()), formatLoudness(t.getTruePeak()), formatLoudness(t.getLoudnessRange()),
+		formatLoudness(measured.Input.Integrated), formatLoudness(measured.Input.TruePeak),
+		formatLoudness(measured.Input.LoudnessRange), formatLoudness(measured.Input.Threshold),
+		formatLoudness(measured.TargetOffset))
+	if sampleRate != "" {
+		result += ",aresample=" + sampleRate
+	}
+	return result
+}
+
+// Returns the loudness stats measured by the last call of
+// MP3Builder.Build if WithLoudnessNormalization is set. Segments
+// without measurable loudness, e.g. silence, are not normalized
+// and not contained.
+func (b *MP3Builder) GetLoudnessStats() []LoudnessStats {
+	return append([]LoudnessStats(nil), b.loudness...)
+}
+
+// Runs the first pass of the normalization, which measures the
+// loudness of the output or of each segment.
+func (b *MP3Builder) measureLoudness(ctx context.Context) ([]LoudnessStats, error) {
+	target := b.options.loudness
+	if target.Scope == LoudnessScopeOutput {
+		args := make([]string, 0, 8+len(b.streams)*6)
+		for _, s := range b.streams {
+			args = append(args, s.getInputArgs()...)
+		}
+		args = append(args,
+			"-filter_complex", b.joinSegments(append(b.getFadeFilters(), target.getMeasureFilter())),
+			"-map", filterGraphOutput, "-f", "null", "-")
+		stats, err := b.runLoudnessMeasurement(ctx, args, -1)
+		if err != nil || stats == nil {
+			return nil, err
+		}
+		return []LoudnessStats{*stats}, nil
+	}
+
+	result := make([]LoudnessStats, 0, len(b.streams))
+	for i, s := range b.streams {
+		args := append(s.getInputArgs(), "-map", "0:"+s.Stream.getAudioSpecifier(),
+			"-af", strings.Join(append(b.getSegmentFilters(i), target.getMeasureFilter()), ","), "-f", "null", "-")
+		stats, err := b.runLoudnessMeasurement(ctx, args, i)
+		if err != nil {
+			return nil, err
+		}
+		if stats != nil {
+			result = append(result, *stats)
+		}
+	}
+	return result, nil
+}
+
+// Runs ffmpeg with a measuring loudnorm filter and returns its stats,
+// or nil if the audio is silent.
+func (b *MP3Builder) runLoudnessMeasurement(ctx context.Context, args []string, segment int) (*LoudnessStats, error) {
+	output, err := runCmd(ctx, b.options.executor, "ffmpeg", append([]string{"-hide_banner", "-nostats"}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg loudness measurement failed: %w - output: %s", err, output.Stderr)
+	}
+	measured, err := parseLoudnormStats(output.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(measured) != 1 {
+		return nil, fmt.Errorf("could not find loudness stats in ffmpeg output")
+	}
+	stats := measured[0]
+	if math.IsInf(stats.Input.Integrated, 0) || math.IsInf(stats.Input.Threshold, 0) {
+		return nil, nil
+	}
+	stats.Segment = segment
+	return &stats, nil
+}
+
+// Returns the measured stats of the segment or nil if
+// the segment is not normalized on its own.
+func (b *MP3Builder) getSegmentLoudness(index int) *LoudnessStats {
+	if b.options.loudness == nil || b.options.loudness.Scope != LoudnessScopeSegment {
+		return nil
+	}
+	for i := range b.loudness {
+		if b.loudness[i].Segment == index {
+			return &b.loudness[i]
+		}
+	}
+	return nil
+}
+
+// Returns the measured stats of the output or nil if
+// the output is not normalized as a whole.
+func (b *MP3Builder) getOutputLoudness() *LoudnessStats {
+	if b.options.loudness == nil || b.options.loudness.Scope != LoudnessScopeOutput || len(b.loudness) == 0 {
+		return nil
+	}
+	return &b.loudness[0]
+}
+
+// Sets the output loudness of the second pass, printed by ffmpeg
+// in the same order as the normalized parts.
+func (b *MP3Builder) setNormalizedLoudness(stderr string) error {
+	normalized, err := parseLoudnormStats(stderr)
+	if err != nil {
+		return err
+	}
+	if len(normalized) != len(b.loudness) {
+		return nil
+	}
+	for i := range b.loudness {
+		b.loudness[i].Output = normalized[i].Output
+	}
+	return nil
+}
+
+// Returns the highest sample rate of all segments, which is the
+// sample rate of the concatenated audio.
+func (b *MP3Builder) getJoinedSampleRate() string {
+	if sampleRate := b.getEncodeOptions().SampleRate; sampleRate > 0 {
+		return strconv.Itoa(sampleRate)
+	}
+	result := 0
+	for _, s := range b.streams {
+		if sampleRate, err := strconv.Atoi(s.Stream.SampleRate); err == nil && sampleRate > result {
+			result = sampleRate
+		}
+	}
+	if result == 0 {
+		return ""
+	}
+	return strconv.Itoa(result)
+}
+
+// Parses the stats printed by all loudnorm filters in the order
+// of the filters in the filter graph.
+func parseLoudnormStats(stderr string) ([]LoudnessStats, error) {
+	matches := LOUDNORM_STATS_REGEX.FindAllStringSubmatch(stderr, -1)
+	sort.SliceStable(matches, func(i, j int) bool {
+		left, _ := strconv.Atoi(matches[i][1])
+		right, _ := strconv.Atoi(matches[j][1])
+		return left < right
+	})
+
+	result := make([]LoudnessStats, 0, len(matches))
+	for _, match := range matches {
+		var output loudnormOutput
+		if err := json.Unmarshal([]byte(match[2]), &output); err != nil {
+			return nil, fmt.Errorf("could not parse loudness stats: %w", err)
+		}
+		stats, err := output.toLoudnessStats()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+func (o loudnormOutput) toLoudnessStats() (result LoudnessStats, err error) {
+	values := []struct {
+		text   string
+		target *float64
+	}{
+		{o.InputI, &result.Input.Integrated},
+		{o.InputTP, &result.Input.TruePeak},
+		{o.InputLRA, &result.Input.LoudnessRange},
+		{o.InputThresh, &result.Input.Threshold},
+		{o.OutputI, &result.Output.Integrated},
+		{o.OutputTP, &result.Output.TruePeak},
+		{o.OutputLRA, &result.Output.LoudnessRange},
+		{o.OutputThresh, &result.Output.Threshold},
+		{o.TargetOffset, &result.TargetOffset},
+	}
+	for _, value := range values {
+		// ffmpeg prints -inf for silence
+		if *value.target, err = strconv.ParseFloat(strings.TrimSpace(value.text), 64); err != nil {
+			return result, fmt.Errorf("invalid loudness value '%s': %w", value.text, err)
+		}
+	}
+	return result, nil
+}
+
+func formatLoudness(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,176 @@
+package mp3joiner
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func loudnormStderr(index int, inputI string, outputI string) string {
+	return "[Parsed_loudnorm_" + strconv.Itoa(index) + " @ 0x55d1c0a0e540] \n{\n" +
+		`	"input_i" : "` + inputI + `",` + "\n" +
+		`	"input_tp" : "-4.50",` + "\n" +
+		`	"input_lra" : "6.20",` + "\n" +
+		`	"input_thresh" : "-33.10",` + "\n" +
+		`	"output_i" : "` + outputI + `",` + "\n" +
+		`	"output_tp" : "-1.50",` + "\n" +
+		`	"output_lra" : "5.10",` + "\n" +
+		`	"output_thresh" : "-26.00",` + "\n" +
+		`	"normalization_type" : "linear",` + "\n" +
+		`	"target_offset" : "0.10"` + "\n}\n"
+}
+
+func TestParseLoudnormStats(t *testing.T) {
+	stderr := "size=N/A time=00:00:10.00 bitrate=N/A speed=1x\n" +
+		loudnormStderr(3, "-20.00", "-16.10") + loudnormStderr(1, "-23.00", "-16.00") +
+		loudnormStderr(5, "-inf", "-inf")
+
+	got, err := parseLoudnormStats(stderr)
+	if err != nil {
+		t.Fatalf("parseLoudnormStats() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("parseLoudnormStats() got %d stats, want 3", len(got))
+	}
+	if got[0].Input.Integrated != -23 || got[1].Input.Integrated != -20 {
+		t.Errorf("parseLoudnormStats() stats not in filter order, got %+v", got)
+	}
+	want := LoudnessStats{
+		Input:        Loudness{Integrated: -20, TruePeak: -4.5, LoudnessRange: 6.2, Threshold: -33.1},
+		Output:       Loudness{Integrated: -16.1, TruePeak: -1.5, LoudnessRange: 5.1, Threshold: -26},
+		TargetOffset: 0.1,
+	}
+	if got[1] != want {
+		t.Errorf("parseLoudnormStats() = %+v, want %+v", got[1], want)
+	}
+	if !math.IsInf(got[2].Input.Integrated, -1) {
+		t.Errorf("parseLoudnormStats() expected -inf for silence, got %v", got[2].Input.Integrated)
+	}
+}
+
+func TestLoudnessTarget_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  LoudnessTarget
+		wantErr bool
+	}{
+		{name: "defaults", target: LoudnessTarget{}},
+		{name: "broadcast", target: LoudnessTarget{IntegratedLoudness: -23, TruePeak: -1, LoudnessRange: 7, Scope: LoudnessScopeSegment}},
+		{name: "too loud", target: LoudnessTarget{IntegratedLoudness: -2}, wantErr: true},
+		{name: "positive true peak", target: LoudnessTarget{TruePeak: 1}, wantErr: true},
+		{name: "loudness range", target: LoudnessTarget{LoudnessRange: 60}, wantErr: true},
+		{name: "unknown scope", target: LoudnessTarget{Scope: 7}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.target.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("LoudnessTarget.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithOutputLoudnessNormalization(t *testing.T) {
+	streamJSON := `{"codec_type":"audio","codec_name":"mp3","bit_rate":"64000","sample_rate":"44100","channels":2}`
+	executions := appendExecutions("00:00:10.00", `[]`, streamJSON, true)
+	executions = append(executions, appendExecutions("00:00:10.00", `[]`, streamJSON, false)...)
+	executions = append(executions,
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: loudnormStderr(2, "-23.00", "-16.40")}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: loudnormStderr(3, "-23.00", "-16.00")}},
+	)
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithFadeIn(Fade{Duration: 1}), WithLoudnessNormalization(LoudnessTarget{}))
+	for _, file := range []string{"first.mp3", "second.mp3"} {
+		if err := builder.Append(file, 0, -1); err != nil {
+			t.Fatalf("MP3Builder.Append() error = %v", err)
+		}
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	measureArgs := calls[len(calls)-2].Args
+	if !containsSequence(measureArgs, []string{"-filter_complex",
+		"[0:a][1:a]concat=n=2:v=0:a=1[f0];[f0]afade=t=in:st=0:d=1.000:curve=tri,loudnorm=I=-16.00:TP=-1.50:LRA=11.00:print_format=json[aout]",
+		"-map", "[aout]", "-f", "null", "-"}) {
+		t.Errorf("MP3Builder.Build() measure args = %v", measureArgs)
+	}
+	buildArgs := calls[len(calls)-1].Args
+	if !containsSequence(buildArgs, []string{"-filter_complex",
+		"[0:a][1:a]concat=n=2:v=0:a=1[f0];[f0]afade=t=in:st=0:d=1.000:curve=tri," +
+			"loudnorm=I=-16.00:TP=-1.50:LRA=11.00:measured_I=-23.00:measured_TP=-4.50:measured_LRA=6.20:measured_thresh=-33.10:offset=0.10:linear=true:print_format=json," +
+			"aresample=44100[aout]"}) {
+		t.Errorf("MP3Builder.Build() build args = %v", buildArgs)
+	}
+
+	stats := builder.GetLoudnessStats()
+	if len(stats) != 1 {
+		t.Fatalf("MP3Builder.GetLoudnessStats() got %d stats, want 1", len(stats))
+	}
+	if stats[0].Segment != -1 || stats[0].Input.Integrated != -23 || stats[0].Output.Integrated != -16 {
+		t.Errorf("MP3Builder.GetLoudnessStats() = %+v", stats[0])
+	}
+}
+
+func TestMP3Builder_BuildWithSegmentLoudnessNormalization(t *testing.T) {
+	streamJSON := `{"codec_type":"audio","codec_name":"mp3","bit_rate":"64000","sample_rate":"22050","channels":1}`
+	executions := appendExecutions("00:00:10.00", `[]`, streamJSON, true)
+	executions = append(executions, appendExecutions("00:00:10.00", `[]`, streamJSON, false)...)
+	executions = append(executions,
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: loudnormStderr(0, "-23.00", "-16.40")}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: loudnormStderr(0, "-inf", "-inf")}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: loudnormStderr(0, "-12.00", "-16.20")}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: loudnormStderr(0, "-23.00", "-16.10") + loudnormStderr(3, "-12.00", "-15.90")}},
+	)
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithLoudnessNormalization(LoudnessTarget{Scope: LoudnessScopeSegment}))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.AppendSilence(1); err != nil {
+		t.Fatalf("MP3Builder.AppendSilence() error = %v", err)
+	}
+	if err := builder.Append("second.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	if args := calls[len(calls)-4].Args; !containsSequence(args, []string{"-i", "first.mp3", "-map", "0:a",
+		"-af", "loudnorm=I=-16.00:TP=-1.50:LRA=11.00:print_format=json", "-f", "null", "-"}) {
+		t.Errorf("MP3Builder.Build() measure args = %v", args)
+	}
+	graph := calls[len(calls)-1].Args[slices.Index(calls[len(calls)-1].Args, "-filter_complex")+1]
+	if !strings.HasPrefix(graph, "[0:a]loudnorm=I=-16.00:TP=-1.50:LRA=11.00:measured_I=-23.00:") ||
+		!strings.Contains(graph, "aresample=22050[f0];[2:a]loudnorm=I=-16.00:TP=-1.50:LRA=11.00:measured_I=-12.00:") ||
+		!strings.HasSuffix(graph, "aresample=22050[f1];[f0][1:a][f1]concat=n=3:v=0:a=1[aout]") {
+		t.Errorf("MP3Builder.Build() filter graph = %v", graph)
+	}
+
+	stats := builder.GetLoudnessStats()
+	if len(stats) != 2 {
+		t.Fatalf("MP3Builder.GetLoudnessStats() got %d stats, want 2", len(stats))
+	}
+	if stats[0].Segment != 0 || stats[0].Output.Integrated != -16.1 || stats[1].Segment != 2 || stats[1].Output.Integrated != -15.9 {
+		t.Errorf("MP3Builder.GetLoudnessStats() = %+v", stats)
+	}
+}
+
+func TestMP3Builder_BuildWithLoudnessNormalizationInStreamCopyMode(t *testing.T) {
+	executor := NewReplayExecutor(appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"64000"}`, true)...)
+	builder := NewMP3Builder(WithExecutor(executor), WithBuildMode(BuildModeStreamCopy), WithLoudnessNormalization(LoudnessTarget{}))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err == nil {
+		t.Error("MP3Builder.Build() expected error")
+	}
+}
@@ -0,0 +1,338 @@
+package mp3joiner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const CURRENT_MANIFEST_VERSION = 1
+
+// Declarative description of a compilation, read from a JSON or
+// YAML file with LoadManifest and built with BuildFromManifest.
+//
+//	version: 1
+//	output:
+//	  path: episode.mp3
+//	  mode: copy
+//	tags:
+//	  title: Episode 1
+//	inputs:
+//	  - path: intro.mp3
+//	    chapter: Intro
+//	  - path: interview.mp3
+//	    start: 30
+//	    end: 1800
+//	post:
+//	  split:
+//	    dir: chapters
+type Manifest struct {
+	Version int            `yaml:"version" json:"version"`
+	Output  ManifestOutput `yaml:"output" json:"output"`
+	// global tags of the output, overriding the tags of the first input
+	Tags   map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Inputs []ManifestInput   `yaml:"inputs" json:"inputs"`
+	Post   ManifestPost      `yaml:"post,omitempty" json:"post,omitempty"`
+
+	// path of the manifest file, relative paths are resolved against its folder
+	manifestFilepath string
+}
+
+// Output file and encoding of a manifest.
+type ManifestOutput struct {
+	Path string `yaml:"path" json:"path"`
+	// either "reencode" (default) or "copy", see BuildMode
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// bitrate in bit/s of re-encoded audio, see WithBitrate
+	Bitrate int `yaml:"bitrate,omitempty" json:"bitrate,omitempty"`
+
+	line int
+}
+
+// Range of an input file, times are in seconds.
+type ManifestInput struct {
+	Path  string  `yaml:"path" json:"path"`
+	Start float64 `yaml:"start,omitempty" json:"start,omitempty"`
+	// end of the range, reads until the end of the file if not set
+	End *float64 `yaml:"end,omitempty" json:"end,omitempty"`
+	// replaces the chapters of the range by a single chapter with this title
+	Chapter string `yaml:"chapter,omitempty" json:"chapter,omitempty"`
+	// replaces the chapters of the range, times are relative to the input file
+	Chapters []ManifestChapter `yaml:"chapters,omitempty" json:"chapters,omitempty"`
+
+	line int
+}
+
+// Chapter of an input, times are in seconds relative to the input file.
+type ManifestChapter struct {
+	Title string  `yaml:"title" json:"title"`
+	Start float64 `yaml:"start" json:"start"`
+	End   float64 `yaml:"end" json:"end"`
+
+	line int
+}
+
+// Steps run after the output has been built.
+type ManifestPost struct {
+	// splits the output by its chapters
+	Split *ManifestSplit `yaml:"split,omitempty" json:"split,omitempty"`
+}
+
+// Options of Split run on the output.
+type ManifestSplit struct {
+	Dir          string `yaml:"dir,omitempty" json:"dir,omitempty"`
+	Template     string `yaml:"template,omitempty" json:"template,omitempty"`
+	KeepChapters bool   `yaml:"keep_chapters,omitempty" json:"keep_chapters,omitempty"`
+}
+
+// Problem found in a manifest file.
+type ManifestError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+func (o *ManifestOutput) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestOutput
+	o.line = node.Line
+	return node.Decode((*plain)(o))
+}
+
+func (i *ManifestInput) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestInput
+	i.line = node.Line
+	return node.Decode((*plain)(i))
+}
+
+func (c *ManifestChapter) UnmarshalYAML(node *yaml.Node) error {
+	type plain ManifestChapter
+	c.line = node.Line
+	return node.Decode((*plain)(c))
+}
+
+// Reads and validates a manifest. As JSON is a subset of YAML both
+// formats are supported. All problems, like unknown keys, missing
+// input files or inverted ranges, are returned as ManifestError
+// pointing to the line of the problem.
+func LoadManifest(manifestFilepath string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestFilepath)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestFilepath, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, &ManifestError{File: manifestFilepath, Line: 1, Message: "manifest is empty"}
+	}
+
+	errs := checkKnownKeys(manifestFilepath, root.Content[0], reflect.TypeOf(Manifest{}))
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	result := &Manifest{manifestFilepath: manifestFilepath}
+	if err = root.Decode(result); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestFilepath, err)
+	}
+	if result.Output.line == 0 {
+		result.Output.line = getKeyLine(root.Content[0], "output")
+	}
+
+	if errs = result.validate(getKeyLine(root.Content[0], "version")); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// Joins the inputs of the manifest, writes the output and runs the
+// post processing steps. Options like the executor are passed to
+// the builder, the output encoding of the manifest takes precedence.
+func BuildFromManifest(manifest *Manifest, opts ...Option) (err error) {
+	return BuildFromManifestContext(context.Background(), manifest, opts...)
+}
+
+// Same as BuildFromManifest but stops ffmpeg once the context is done.
+func BuildFromManifestContext(ctx context.Context, manifest *Manifest, opts ...Option) (err error) {
+	switch manifest.Output.Mode {
+	case "copy":
+		opts = append(opts, WithBuildMode(BuildModeStreamCopy))
+	case "reencode":
+		opts = append(opts, WithBuildMode(BuildModeReencode))
+	}
+	if manifest.Output.Bitrate > 0 {
+		opts = append(opts, WithBitrate(manifest.Output.Bitrate))
+	}
+
+	builder := NewMP3Builder(opts...)
+	for _, input := range manifest.Inputs {
+		end := -1.0
+		if input.End != nil {
+			end = *input.End
+		}
+		if err = builder.AppendContext(ctx, manifest.resolvePath(input.Path), input.Start, end); err != nil {
+			return err
+		}
+		input.overrideChapters(&builder.streams[len(builder.streams)-1])
+	}
+
+	if len(manifest.Tags) > 0 && builder.metaData == nil {
+		builder.metaData = make(map[string]string, len(manifest.Tags))
+	}
+	for key, value := range manifest.Tags {
+		builder.metaData[key] = value
+	}
+
+	output := manifest.resolvePath(manifest.Output.Path)
+	if err = builder.BuildContext(ctx, output); err != nil {
+		return err
+	}
+
+	if split := manifest.Post.Split; split != nil {
+		splitOptions := SplitOptions{NameTemplate: split.Template, KeepChapters: split.KeepChapters}
+		if split.Dir != "" {
+			splitOptions.OutputDir = manifest.resolvePath(split.Dir)
+		}
+		if _, err = SplitContext(ctx, output, splitOptions, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaces the chapters of the segment if the input defines chapters.
+func (i ManifestInput) overrideChapters(s *segment) {
+	if i.Chapter != "" {
+		s.Chapters = []Chapter{{
+			TimeBase: DEFAULT_TIME_BASE,
+			Start:    0,
+			End:      toDefaultTimeBase(s.Duration),
+			Tags:     Tags{Title: i.Chapter},
+		}}
+		return
+	}
+	if i.Chapters == nil {
+		return
+	}
+
+	chapters := make([]Chapter, 0, len(i.Chapters))
+	for _, manifestChapter := range i.Chapters {
+		chapter := Chapter{TimeBase: DEFAULT_TIME_BASE, Tags: Tags{Title: manifestChapter.Title}}
+		chapter.SetStartTime(manifestChapter.Start)
+		chapter.SetEndTime(manifestChapter.End)
+		chapters = append(chapters, chapter)
+	}
+	s.Chapters = rebaseChapters(getChapterInTimeFrame(chapters, s.Start, s.Start+s.Duration), -s.Start)
+}
+
+func (m *Manifest) validate(versionLine int) (errs []error) {
+	addError := func(line int, format string, a ...any) {
+		errs = append(errs, &ManifestError{File: m.manifestFilepath, Line: line, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if m.Version != CURRENT_MANIFEST_VERSION {
+		addError(versionLine, "unsupported version %d, expected %d", m.Version, CURRENT_MANIFEST_VERSION)
+	}
+
+	if m.Output.Path == "" {
+		addError(m.Output.line, "output path is not set")
+	}
+	if m.Output.Mode != "" && m.Output.Mode != "reencode" && m.Output.Mode != "copy" {
+		addError(m.Output.line, "unknown mode '%s', expected 'reencode' or 'copy'", m.Output.Mode)
+	}
+	if m.Output.Bitrate < 0 {
+		addError(m.Output.line, "bitrate %d is negative", m.Output.Bitrate)
+	}
+
+	if len(m.Inputs) == 0 {
+		addError(m.Output.line, "no inputs defined")
+	}
+	for _, input := range m.Inputs {
+		if input.Path == "" {
+			addError(input.line, "input path is not set")
+		} else if _, err := os.Stat(m.resolvePath(input.Path)); err != nil {
+			addError(input.line, "input '%s' does not exist", input.Path)
+		}
+		if input.Start < 0 {
+			addError(input.line, "start %v is negative", input.Start)
+		}
+		if input.End != nil && input.Start >= *input.End {
+			addError(input.line, "start %v set after end %v", input.Start, *input.End)
+		}
+		if input.Chapter != "" && input.Chapters != nil {
+			addError(input.line, "only one of chapter and chapters can be set")
+		}
+		for _, chapter := range input.Chapters {
+			if chapter.Start >= chapter.End {
+				addError(chapter.line, "chapter '%s' starts at %v after its end %v", chapter.Title, chapter.Start, chapter.End)
+			}
+		}
+	}
+	return errs
+}
+
+func (m *Manifest) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(m.manifestFilepath), path)
+}
+
+// Returns an error for each key of a mapping which has no
+// matching field in the yaml tags of the type.
+func checkKnownKeys(manifestFilepath string, node *yaml.Node, t reflect.Type) (errs []error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := getFieldByYAMLKey(t, key.Value)
+			if !ok {
+				errs = append(errs, &ManifestError{File: manifestFilepath, Line: key.Line, Message: fmt.Sprintf("unknown key '%s'", key.Value)})
+				continue
+			}
+			errs = append(errs, checkKnownKeys(manifestFilepath, value, field.Type)...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			errs = append(errs, checkKnownKeys(manifestFilepath, item, t.Elem())...)
+		}
+	}
+	return errs
+}
+
+func getFieldByYAMLKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.IsExported() && name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Returns the line of the key in the mapping or the
+// line of the mapping if the key does not exist.
+func getKeyLine(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i].Line
+		}
+	}
+	return node.Line
+}
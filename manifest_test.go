@@ -0,0 +1,220 @@
+package mp3joiner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+	}{
+		{
+			name:     "yaml",
+			fileName: "manifest.yaml",
+			content: `version: 1
+output:
+  path: out.mp3
+  mode: copy
+tags:
+  title: Episode
+inputs:
+  - path: first.mp3
+    chapter: Intro
+  - path: second.mp3
+    start: 0.5
+    end: 1
+    chapters:
+      - title: Main
+        start: 0
+        end: 1
+`,
+		}, {
+			name:     "json",
+			fileName: "manifest.json",
+			content: `{
+	"version": 1,
+	"output": {"path": "out.mp3", "mode": "copy"},
+	"tags": {"title": "Episode"},
+	"inputs": [
+		{"path": "first.mp3", "chapter": "Intro"},
+		{"path": "second.mp3", "start": 0.5, "end": 1, "chapters": [{"title": "Main", "start": 0, "end": 1}]}
+	]
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestFilepath := createTestManifest(t, tt.fileName, tt.content)
+
+			manifest, err := LoadManifest(manifestFilepath)
+			if err != nil {
+				t.Fatalf("LoadManifest() error = %v", err)
+			}
+			if manifest.Output.Mode != "copy" || manifest.Tags["title"] != "Episode" || len(manifest.Inputs) != 2 {
+				t.Errorf("LoadManifest() = %+v", manifest)
+			}
+			second := manifest.Inputs[1]
+			if second.Start != 0.5 || second.End == nil || *second.End != 1 || len(second.Chapters) != 1 {
+				t.Errorf("LoadManifest() second input = %+v", second)
+			}
+		})
+	}
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "unknown keys",
+			content: `version: 1
+output:
+  path: out.mp3
+  codec: aac
+inputs:
+  - path: first.mp3
+    stop: 10
+`,
+			want: []string{":4: unknown key 'codec'", ":7: unknown key 'stop'"},
+		}, {
+			name: "invalid values",
+			content: `version: 2
+output:
+  path: out.mp3
+inputs:
+  - path: first.mp3
+  - path: missing.mp3
+  - path: second.mp3
+    start: 10
+    end: 5
+    chapters:
+      - title: Inverted
+        start: 3
+        end: 1
+`,
+			want: []string{
+				":1: unsupported version 2",
+				":6: input 'missing.mp3' does not exist",
+				":7: start 10 set after end 5",
+				":11: chapter 'Inverted' starts at 3 after its end 1",
+			},
+		}, {
+			name:    "missing output",
+			content: `{"version": 1, "inputs": []}`,
+			want:    []string{":1: output path is not set", ":1: no inputs defined"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestFilepath := createTestManifest(t, "manifest.yaml", tt.content)
+
+			_, err := LoadManifest(manifestFilepath)
+			if err == nil {
+				t.Fatal("LoadManifest() expected error")
+			}
+			var manifestErr *ManifestError
+			if !errors.As(err, &manifestErr) {
+				t.Errorf("LoadManifest() expected ManifestError but got %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), manifestFilepath+want) {
+					t.Errorf("LoadManifest() error = %v, expected to contain %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildFromManifest(t *testing.T) {
+	manifestFilepath := createTestManifest(t, "manifest.yaml", `version: 1
+output:
+  path: out.mp3
+  bitrate: 192000
+tags:
+  title: Episode
+inputs:
+  - path: first.mp3
+    chapter: Intro
+  - path: second.mp3
+    start: 0.1
+`)
+	manifest, err := LoadManifest(manifestFilepath)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+
+	executor := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":[]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"format":{"tags":{"title":"demo"}}}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"bit_rate":"128000"}]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":[]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"bit_rate":"128000"}]}`}},
+		Execution{Name: "ffmpeg"},
+	)
+	if err = BuildFromManifest(manifest, WithExecutor(executor)); err != nil {
+		t.Fatalf("BuildFromManifest() error = %v", err)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("BuildFromManifest() expected all executions to be used, %v remaining", executor.Remaining())
+	}
+
+	dir := filepath.Dir(manifestFilepath)
+	args := executor.Calls()[5].Args
+	expectedSequences := [][]string{
+		{"-i", filepath.Join(dir, "first.mp3")},
+		{"-ss", "0.100", "-t", "0.161", "-i", filepath.Join(dir, "second.mp3")},
+		{"-b:a", "192k", filepath.Join(dir, "out.mp3")},
+	}
+	for _, sequence := range expectedSequences {
+		if !containsSequence(args, sequence) {
+			t.Errorf("BuildFromManifest() expected arguments %v in %v", sequence, args)
+		}
+	}
+}
+
+func TestManifestInput_overrideChapters(t *testing.T) {
+	s := segment{Start: 10, Duration: 20}
+	input := ManifestInput{Chapters: []ManifestChapter{
+		{Title: "Before", Start: 0, End: 5},
+		{Title: "First", Start: 5, End: 15},
+		{Title: "Second", Start: 15, End: 40},
+	}}
+	input.overrideChapters(&s)
+
+	if len(s.Chapters) != 2 {
+		t.Fatalf("overrideChapters() = %v, expected 2 chapters", s.Chapters)
+	}
+	if s.Chapters[0].GetStartTimeInSeconds() != 0 || s.Chapters[0].GetEndTimeInSeconds() != 5 ||
+		s.Chapters[1].GetStartTimeInSeconds() != 5 || s.Chapters[1].GetEndTimeInSeconds() != 20 {
+		t.Errorf("overrideChapters() = %v", s.Chapters)
+	}
+
+	input = ManifestInput{Chapter: "Whole"}
+	input.overrideChapters(&s)
+	if len(s.Chapters) != 1 || s.Chapters[0].Tags.Title != "Whole" || s.Chapters[0].GetEndTimeInSeconds() != 20 {
+		t.Errorf("overrideChapters() = %v", s.Chapters)
+	}
+}
+
+// Writes the manifest next to two MP3 files called first.mp3 and second.mp3.
+func createTestManifest(t *testing.T, fileName string, content string) string {
+	dir := t.TempDir()
+	for _, name := range []string{"first.mp3", "second.mp3"} {
+		if err := os.WriteFile(filepath.Join(dir, name), createTestFrames(testMPEG1FrameHeader, 10), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifestFilepath := filepath.Join(dir, fileName)
+	if err := os.WriteFile(manifestFilepath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return manifestFilepath
+}
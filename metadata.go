@@ -1,23 +1,22 @@
 package mp3joiner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 )
 
 var (
 	ILLEGAL_METADATA_CHARACTERS = regexp.MustCompile(`(#|;|=|\\)`)
 	FFMPEG_STATS_REGEX          = regexp.MustCompile(`.+time=(?:.*)([0-9]{2,99}):([0-9]{2}):([0-9]{2}).([0-9]{2})`)
-	random                      = rand.New(rand.NewSource(time.Now().UnixNano()))
+	STREAM_ENTRIES              = "stream=index,codec_type,codec_name,bit_rate,sample_rate,channels,channel_layout,bits_per_sample,bits_per_raw_sample" +
+		":stream_disposition=default,attached_pic:format=format_name,bit_rate"
 )
 
 type chapters struct {
@@ -26,28 +25,67 @@ type chapters struct {
 
 type filemetadata struct {
 	Streams []stream `json:"streams,omitempty"`
+	Format  struct {
+		FormatName string `json:"format_name,omitempty"`
+		Bitrate    string `json:"bit_rate,omitempty"`
+	} `json:"format,omitempty"`
 }
 
 type stream struct {
-	Bitrate string `json:"bit_rate,omitempty"`
+	Index            int    `json:"index,omitempty"`
+	CodecType        string `json:"codec_type,omitempty"`
+	Bitrate          string `json:"bit_rate,omitempty"`
+	CodecName        string `json:"codec_name,omitempty"`
+	SampleRate       string `json:"sample_rate,omitempty"`
+	Channels         int    `json:"channels,omitempty"`
+	ChannelLayout    string `json:"channel_layout,omitempty"`
+	BitsPerSample    int    `json:"bits_per_sample,omitempty"`
+	BitsPerRawSample string `json:"bits_per_raw_sample,omitempty"`
+	Disposition      struct {
+		Default     int `json:"default,omitempty"`
+		AttachedPic int `json:"attached_pic,omitempty"`
+	} `json:"disposition,omitempty"`
+
+	// bitrate of the container, used if the stream has none
+	formatBitrate string
+	// position among the audio streams of the file
+	audioIndex int
 }
 
 // Gets a map of ffmpeg MP3 metadata tags. Note that the ID3 tags
 // and ffmpeg tags are not equivalent. See this documentation for
 // the mapping:
 // https://wiki.multimedia.cx/index.php/FFmpeg_Metadata#MP3
-func GetFFmpegMetadataTag(mp3Filepath string) (result map[string]string, err error) {
+func GetFFmpegMetadataTag(mp3Filepath string, opts ...Option) (result map[string]string, err error) {
+	return GetFFmpegMetadataTagContext(context.Background(), mp3Filepath, opts...)
+}
+
+// Same as GetFFmpegMetadataTag but stops the underlying process once the context is done.
+func GetFFmpegMetadataTagContext(ctx context.Context, mp3Filepath string, opts ...Option) (result map[string]string, err error) {
+	return getFFmpegMetadataTag(ctx, newOptions(opts), mp3Filepath)
+}
+
+func getFFmpegMetadataTag(ctx context.Context, o *options, mp3Filepath string) (result map[string]string, err error) {
 	var data metadata
 	// ffprobe -hide_banner -v 0 -show_entries format -of json "path/to/file.mp3"
-	err = ffprobe(mp3Filepath, map[string]any{"hide_banner": "", "v": 0, "show_entries": "format", "of": "json"}, &data)
+	err = ffprobe(ctx, o, mp3Filepath, map[string]any{"hide_banner": "", "v": 0, "show_entries": "format", "of": "json"}, &data)
 	result = data.Format.Tags
 	return result, err
 }
 
-func GetChapterMetadata(mp3Filepath string) (result []Chapter, err error) {
+func GetChapterMetadata(mp3Filepath string, opts ...Option) (result []Chapter, err error) {
+	return GetChapterMetadataContext(context.Background(), mp3Filepath, opts...)
+}
+
+// Same as GetChapterMetadata but stops the underlying process once the context is done.
+func GetChapterMetadataContext(ctx context.Context, mp3Filepath string, opts ...Option) (result []Chapter, err error) {
+	return getChapterMetadata(ctx, newOptions(opts), mp3Filepath)
+}
+
+func getChapterMetadata(ctx context.Context, o *options, mp3Filepath string) (result []Chapter, err error) {
 	var data chapters
 	// ffprobe -hide_banner -v 0 "path/to/file.mp3" -print_format json -show_chapters
-	err = ffprobe(mp3Filepath, map[string]any{"hide_banner": "", "v": 0, "print_format": "json", "show_chapters": ""}, &data)
+	err = ffprobe(ctx, o, mp3Filepath, map[string]any{"hide_banner": "", "v": 0, "print_format": "json", "show_chapters": ""}, &data)
 	result = data.Chapters
 	// sort by start
 	sort.SliceStable(result, func(i, j int) bool {
@@ -60,8 +98,17 @@ func GetChapterMetadata(mp3Filepath string) (result []Chapter, err error) {
 // as metadata and actual length can be inconsistent. Instead this implementation
 // decodes the file and returns the actual length of the audio stream.
 // This is slower but more accurate then reading the length from the metadata.
-func GetLengthInSeconds(mp3Filepath string) (result float64, err error) {
-	output, err := getFFmpegStats(mp3Filepath)
+func GetLengthInSeconds(mp3Filepath string, opts ...Option) (result float64, err error) {
+	return GetLengthInSecondsContext(context.Background(), mp3Filepath, opts...)
+}
+
+// Same as GetLengthInSeconds but stops the underlying process once the context is done.
+func GetLengthInSecondsContext(ctx context.Context, mp3Filepath string, opts ...Option) (result float64, err error) {
+	return getLengthInSeconds(ctx, newOptions(opts), mp3Filepath)
+}
+
+func getLengthInSeconds(ctx context.Context, o *options, mp3Filepath string) (result float64, err error) {
+	output, err := getFFmpegStats(ctx, o, mp3Filepath)
 	if err != nil {
 		return -1, err
 	}
@@ -69,17 +116,39 @@ func GetLengthInSeconds(mp3Filepath string) (result float64, err error) {
 	return parseMP3Length(output)
 }
 
-func GetBitrate(mp3Filepath string) (result int, err error) {
-	var bitrate filemetadata
-	result = -1
+func GetBitrate(mp3Filepath string, opts ...Option) (result int, err error) {
+	return GetBitrateContext(context.Background(), mp3Filepath, opts...)
+}
+
+// Same as GetBitrate but stops the underlying process once the context is done.
+func GetBitrateContext(ctx context.Context, mp3Filepath string, opts ...Option) (result int, err error) {
+	return getBitrate(ctx, newOptions(opts), mp3Filepath)
+}
 
-	// ffprobe "input.mp3" -v 0 -show_entries stream -print_format json
-	err = ffprobe(mp3Filepath, map[string]any{"v": 0, "show_entries": "stream=bit_rate", "print_format": "json"}, &bitrate)
+func getBitrate(ctx context.Context, o *options, mp3Filepath string) (result int, err error) {
+	info, err := getStreamInfo(ctx, o, mp3Filepath)
+	if err != nil {
+		return -1, err
+	}
+	return info.getBitrate()
+}
+
+// Returns the properties of the audio stream of the file, see selectAudioStream.
+func getStreamInfo(ctx context.Context, o *options, mp3Filepath string) (result stream, err error) {
+	var data filemetadata
+
+	// ffprobe "input.mp3" -v 0 -show_entries stream=bit_rate,...:stream_disposition=...:format=... -print_format json
+	err = ffprobe(ctx, o, mp3Filepath, map[string]any{"v": 0, "show_entries": STREAM_ENTRIES, "print_format": "json"}, &data)
 	if err != nil {
 		return result, err
 	}
+	result, ok := selectAudioStream(data.Streams)
+	if !ok {
+		return result, fmt.Errorf("no stream found in '%s'", mp3Filepath)
+	}
+	result.formatBitrate = data.Format.Bitrate
 
-	return strconv.Atoi(bitrate.Streams[0].Bitrate)
+	return result, nil
 }
 
 // Sets FFmpeg MP3 metadata tag. Note that the ID3 tags and
@@ -87,16 +156,45 @@ func GetBitrate(mp3Filepath string) (result int, err error) {
 // for the mapping:
 // https://wiki.multimedia.cx/index.php/FFmpeg_Metadata#MP3
 //
-// This function creates a new temp file and replaces the initial file.
-func SetFFmpegMetadataTag(mp3Filepath string, metadata map[string]string, chapters []Chapter) (err error) {
-	bitrate, err := GetBitrate(mp3Filepath)
+// The ID3v2 tag of MP3 files is edited in place, see TagEditor.
+// Other files are remuxed into a temp file next to the initial
+// file, which then replaces it.
+func SetFFmpegMetadataTag(mp3Filepath string, metadata map[string]string, chapters []Chapter, opts ...Option) (err error) {
+	return SetFFmpegMetadataTagContext(context.Background(), mp3Filepath, metadata, chapters, opts...)
+}
+
+// Same as SetFFmpegMetadataTag but stops the underlying process once the context is done.
+// The original file is left untouched in this case.
+func SetFFmpegMetadataTagContext(ctx context.Context, mp3Filepath string, metadata map[string]string, chapters []Chapter, opts ...Option) (err error) {
+	o := newOptions(opts)
+	if getContainer(mp3Filepath) == ContainerMP3 {
+		if editor, err := NewTagEditor(mp3Filepath); err == nil {
+			return setTagEditorMetadata(ctx, editor, metadata, chapters)
+		}
+	}
+
+	// fall back to ffmpeg for other containers and unsupported tags
+	bitrate, err := getBitrate(ctx, o, mp3Filepath)
 	if err != nil {
 		return err
 	}
-	return setMetadataWithBitrate(mp3Filepath, metadata, chapters, bitrate)
+	return setMetadataWithBitrate(ctx, o, mp3Filepath, metadata, chapters, bitrate)
 }
 
-func ffprobe(mp3Filepath string, args map[string]any, v any) (err error) {
+// Replaces metadata and chapters of the tag like the ffmpeg
+// metadata file does. Images and other binary frames are kept.
+func setTagEditorMetadata(ctx context.Context, editor *TagEditor, metadata map[string]string, chapters []Chapter) error {
+	editor.SetMetadata(metadata)
+	if err := editor.SetChapters(chapters, nil); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return editor.Save()
+}
+
+func ffprobe(ctx context.Context, o *options, mp3Filepath string, args map[string]any, v any) (err error) {
 	cmdArgs := make([]string, 0, 12)
 
 	// preserve a sensible order of arguments
@@ -123,23 +221,29 @@ func ffprobe(mp3Filepath string, args map[string]any, v any) (err error) {
 	// input file at the end (explicit -i to satisfy some ffprobe builds)
 	cmdArgs = append(cmdArgs, "-i", mp3Filepath)
 
-	output, err := runCmd("ffprobe", cmdArgs...)
+	output, err := runCmd(ctx, o.executor, "ffprobe", cmdArgs...)
 	if err != nil {
-		return fmt.Errorf("ffprobe failed: %w - output: %s", err, output)
+		return fmt.Errorf("ffprobe failed: %w - output: %s", err, output.Stderr)
 	}
-	return json.Unmarshal([]byte(output), v)
+	return json.Unmarshal([]byte(output.Stdout), v)
 }
 
-func setMetadataWithBitrate(mp3Filepath string, metadata map[string]string, chapters []Chapter, bitrate int) (err error) {
+func setMetadataWithBitrate(ctx context.Context, o *options, mp3Filepath string, metadata map[string]string, chapters []Chapter, bitrate int) (err error) {
 	tempMetadataFile, err := createTempMetadataFile(metadata, chapters)
 	if err != nil {
 		return err
 	}
 	defer deleteFile(tempMetadataFile)
 
-	tempFile := filepath.Join(os.TempDir(), strconv.Itoa(random.Intn(9999999999999))+".mp3")
+	// the temp file is created next to the file, so it can be renamed
+	// over it and the file stays intact if the process dies before
+	tempFile, err := createTempFileNextTo(mp3Filepath)
+	if err != nil {
+		return err
+	}
+	defer deleteFile(tempFile)
 
-	// ffmpeg -i INPUT.mp3 -i METADATA -map_chapters 1 -map_metadata 1 -b:a 32k -codec copy OUTPUT.mp3
+	// ffmpeg -i INPUT.mp3 -i METADATA -map_chapters 1 -map_metadata 1 -b:a 32k -codec copy -y OUTPUT.mp3
 	args := []string{
 		"-i", mp3Filepath,
 		"-i", tempMetadataFile,
@@ -147,30 +251,38 @@ func setMetadataWithBitrate(mp3Filepath string, metadata map[string]string, chap
 		"-map_chapters", "1",
 		"-b:a", fmt.Sprintf("%dk", int(bitrate/1000)),
 		"-codec", "copy",
+		"-y",
 		tempFile,
 	}
-	if output, errRun := runCmd("ffmpeg", args...); errRun != nil {
-		return fmt.Errorf("ffmpeg metadata set failed: %w - output: %s", errRun, output)
+	if output, errRun := runCmd(ctx, o.executor, "ffmpeg", args...); errRun != nil {
+		return fmt.Errorf("ffmpeg metadata set failed: %w - output: %s", errRun, output.Stderr)
 	}
-	defer deleteFile(tempFile)
 
 	return overwriteFile(tempFile, mp3Filepath)
 }
 
+// Creates an empty temp file in the directory of the file
+// with the same extension, so ffmpeg detects the same format.
+func createTempFileNextTo(filePath string) (string, error) {
+	extension := filepath.Ext(filePath)
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), strings.TrimSuffix(filepath.Base(filePath), extension)+".*"+extension)
+	if err != nil {
+		return "", err
+	}
+	return tempFile.Name(), tempFile.Close()
+}
+
+// Atomically replaces the output file by the input file,
+// keeping the permissions of the output file.
 func overwriteFile(inputFilePath, outputFilePath string) (err error) {
-	targetFile, err := os.OpenFile(outputFilePath, os.O_RDWR|os.O_TRUNC, 0755)
+	outputInfo, err := os.Stat(outputFilePath)
 	if err != nil {
 		return err
 	}
-	defer closeFile(targetFile)
-	sourceFile, err := os.Open(inputFilePath)
-	if err != nil {
+	if err = os.Chmod(inputFilePath, outputInfo.Mode()); err != nil {
 		return err
 	}
-	defer closeFile(sourceFile)
-	_, err = io.Copy(targetFile, sourceFile)
-
-	return err
+	return os.Rename(inputFilePath, outputFilePath)
 }
 
 // Creates an meta data file in the temp folder.
@@ -230,7 +342,7 @@ func sanitizeMetadata(input string) (output string) {
 	return output
 }
 
-func getFFmpegStats(mp3Filepath string) (output string, err error) {
+func getFFmpegStats(ctx context.Context, o *options, mp3Filepath string) (output string, err error) {
 	// Equivalent to:
 	// ffmpeg -i input.mp3 -map 0:a -f null - -stats -v quiet
 	args := []string{
@@ -240,8 +352,9 @@ func getFFmpegStats(mp3Filepath string) (output string, err error) {
 		"-stats",
 		"-v", "quiet",
 	}
-	output, err = runCmd("ffmpeg", args...)
-	return output, err
+	// ffmpeg writes its stats to stderr
+	result, err := runCmd(ctx, o.executor, "ffmpeg", args...)
+	return result.Stderr, err
 }
 
 func parseMP3Length(ffmpegStats string) (float64, error) {
@@ -266,6 +266,117 @@ func TestGetBitrate(t *testing.T) {
 	}
 }
 
+func TestGetChapterMetadataWithExecutor(t *testing.T) {
+	executor := NewReplayExecutor(Execution{
+		Name: "ffprobe",
+		Result: ExecResult{Stdout: `{"chapters":[` +
+			`{"time_base":"1/1000","start":5000,"end":9000,"tags":{"title":"Second"}},` +
+			`{"time_base":"1/1000","start":0,"end":5000,"tags":{"title":"First"}}]}`},
+	})
+
+	gotResult, err := GetChapterMetadata("file.mp3", WithExecutor(executor))
+	if err != nil {
+		t.Fatalf("GetChapterMetadata() error = %v", err)
+	}
+	if len(gotResult) != 2 || gotResult[0].Tags.Title != "First" || gotResult[1].Tags.Title != "Second" {
+		t.Errorf("GetChapterMetadata() expected sorted chapters, found %v", gotResult)
+	}
+	args := executor.Calls()[0].Args
+	if args[len(args)-1] != "file.mp3" {
+		t.Errorf("GetChapterMetadata() expected file as last argument, found %v", args)
+	}
+}
+
+func TestGetBitrateWithExecutor(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		wantResult int
+		wantErr    bool
+	}{
+		{
+			name:       "positive test",
+			output:     `{"streams":[{"bit_rate":"64000"}]}`,
+			wantResult: 64000,
+			wantErr:    false,
+		}, {
+			name:       "container bitrate",
+			output:     `{"streams":[{"codec_type":"video","disposition":{"attached_pic":1}},{"codec_type":"audio","codec_name":"flac"}],"format":{"bit_rate":"912000"}}`,
+			wantResult: 912000,
+			wantErr:    false,
+		}, {
+			name:       "derived from sample format",
+			output:     `{"streams":[{"codec_type":"audio","codec_name":"pcm_s16le","sample_rate":"44100","channels":2,"bits_per_sample":16}]}`,
+			wantResult: 1411200,
+			wantErr:    false,
+		}, {
+			name:       "no streams",
+			output:     `{"streams":[]}`,
+			wantResult: -1,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewReplayExecutor(Execution{Name: "ffprobe", Result: ExecResult{Stdout: tt.output}})
+			gotResult, err := GetBitrate("file.mp3", WithExecutor(executor))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetBitrate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotResult != tt.wantResult {
+				t.Errorf("GetBitrate() = %v, want %v", gotResult, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestSetFFmpegMetadataTagWithTagEditor(t *testing.T) {
+	filePath := createTestMP3File(t, 4)
+	executor := NewReplayExecutor()
+	chapters := []Chapter{{TimeBase: "1/1000", Start: 0, End: 500, Tags: Tags{Title: "Intro"}}}
+
+	err := SetFFmpegMetadataTag(filePath, map[string]string{"artist": "Someone"}, chapters, WithExecutor(executor))
+	if err != nil {
+		t.Fatalf("SetFFmpegMetadataTag() error = %v", err)
+	}
+	if len(executor.Calls()) != 0 {
+		t.Errorf("SetFFmpegMetadataTag() expected no ffmpeg calls for MP3 files, found %v", executor.Calls())
+	}
+	assertTagEditorMetadata(t, filePath, map[string]string{"artist": "Someone"})
+	gotChapters, _, err := ReadID3Chapters(filePath)
+	if err != nil || len(gotChapters) != 1 || gotChapters[0].Tags.Title != "Intro" {
+		t.Errorf("SetFFmpegMetadataTag() chapters = %v, %v", gotChapters, err)
+	}
+}
+
+func TestSetFFmpegMetadataTagWithFFmpeg(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "audio.flac")
+	if err := os.WriteFile(filePath, []byte("fLaC and some audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	executor := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"bit_rate":"64000"}]}`}},
+		Execution{Name: "ffmpeg"},
+	)
+
+	if err := SetFFmpegMetadataTag(filePath, map[string]string{"title": "test"}, nil, WithExecutor(executor)); err != nil {
+		t.Fatalf("SetFFmpegMetadataTag() error = %v", err)
+	}
+	args := executor.Calls()[1].Args
+	output := args[len(args)-1]
+	if filepath.Dir(output) != filepath.Dir(filePath) || filepath.Ext(output) != ".flac" || args[len(args)-2] != "-y" {
+		t.Errorf("SetFFmpegMetadataTag() expected temp file next to the file, found %v", args)
+	}
+	// the replay executor does not write the temp file, so it replaces the file empty
+	if size := getFileSizeInBytes(t, filePath); size != 0 {
+		t.Errorf("SetFFmpegMetadataTag() expected file to be replaced, found size %v", size)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(filePath)); len(entries) != 1 {
+		t.Errorf("SetFFmpegMetadataTag() expected temp files to be removed, found %v", entries)
+	}
+}
+
 func Test_createTempMetadataFile(t *testing.T) {
 	type args struct {
 		metadata map[string]string
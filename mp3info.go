@@ -0,0 +1,415 @@
+package mp3joiner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	mpegVersion25 = 0
+	mpegVersion2  = 2
+	mpegVersion1  = 3
+
+	mpegLayer3 = 1
+	mpegLayer2 = 2
+	mpegLayer1 = 3
+
+	channelModeMono = 3
+
+	id3v2HeaderLength = 10
+	id3v2FooterFlag   = 0x10
+	id3v1Length       = 128
+	apeFooterLength   = 32
+	// largest possible frame is a MPEG 2.5 layer II frame with 160kbit/s
+	// at 8kHz and padding, 144 * 160000 / 8000 + 1 bytes. Layer III frames
+	// of MPEG 2 and 2.5 use a factor of 72 and have at most 1441 bytes.
+	maxFrameLength = 2881
+
+	xingFramesFlag        = 0x1
+	xingBytesFlag         = 0x2
+	xingTOCFlag           = 0x4
+	xingQualityFlag       = 0x8
+	xingTOCLength         = 100
+	lameTagLength         = 24
+	lameEncoderDelayIndex = 21
+)
+
+var (
+	// bitrates in kbit/s indexed by the bitrate index of the frame header
+	mpeg1Layer1Bitrates  = []int{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448}
+	mpeg1Layer2Bitrates  = []int{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384}
+	mpeg1Layer3Bitrates  = []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mpeg2Layer1Bitrates  = []int{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256}
+	mpeg2Layer23Bitrates = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+	// sample rates in Hz indexed by the sample rate index of the frame header
+	mpegSampleRates = map[int][]int{
+		mpegVersion1:  {44100, 48000, 32000},
+		mpegVersion2:  {22050, 24000, 16000},
+		mpegVersion25: {11025, 12000, 8000},
+	}
+
+	xingTagIdentifiers = [][]byte{[]byte("Xing"), []byte("Info")}
+	vbriTagIdentifier  = []byte("VBRI")
+	apeTagIdentifier   = []byte("APETAGEX")
+	id3v1TagIdentifier = []byte("TAG")
+	id3v2TagIdentifier = []byte("ID3")
+
+	errNoMPEGFramesFound = errors.New("no MPEG audio frames found")
+)
+
+// Properties of an MP3 file determined by reading its frame
+// headers without decoding the audio.
+type MP3Info struct {
+	// length of the audio in seconds with encoder delay and padding removed
+	Duration float64
+	// number of audio frames, not counting Xing, Info or VBRI frames
+	Frames int
+	// number of samples per channel with encoder delay and padding removed
+	Samples    int64
+	SampleRate int
+	Channels   int
+	// average bitrate in bit/s
+	Bitrate int
+	// true if the file starts with a Xing or VBRI header
+	VBR bool
+	// samples added by the encoder before the audio, read from the LAME tag
+	EncoderDelay int
+	// samples added by the encoder after the audio, read from the LAME tag
+	EncoderPadding int
+}
+
+type frameHeader struct {
+	version     int
+	layer       int
+	bitrate     int
+	sampleRate  int
+	padding     bool
+	channelMode int
+}
+
+// Reads the properties of an MP3 file by walking its frames.
+// Leading ID3v2 tags as well as trailing ID3v1 and APE tags are skipped.
+// This is much faster than GetLengthInSeconds, which decodes the audio.
+func GetMP3Info(mp3Filepath string) (result MP3Info, err error) {
+	file, err := os.Open(mp3Filepath)
+	if err != nil {
+		return result, err
+	}
+	defer closeFile(file)
+
+	// frames may be falsely detected in the samples of other containers
+	if container := getContainer(mp3Filepath); container != ContainerMP3 && container != ContainerUnknown {
+		return result, fmt.Errorf("'%s' is not an MP3 file but %s", mp3Filepath, container)
+	}
+	return ReadMP3Info(file)
+}
+
+// Same as GetMP3Info but reads from the given reader.
+func ReadMP3Info(reader io.ReadSeeker) (result MP3Info, err error) {
+	audioEnd, err := findAudioEnd(reader)
+	if err != nil {
+		return result, err
+	}
+	audioStart, err := findAudioStart(reader)
+	if err != nil {
+		return result, err
+	}
+	if _, err = reader.Seek(audioStart, io.SeekStart); err != nil {
+		return result, err
+	}
+
+	bufferedReader := bufio.NewReaderSize(reader, 64*1024)
+	position := audioStart
+	audioBytes := int64(0)
+	var first *frameHeader
+	for position+4 <= audioEnd {
+		buffer, err := bufferedReader.Peek(int(min(audioEnd-position, maxFrameLength+4)))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return result, err
+		}
+
+		header, ok := parseFrameHeader(buffer)
+		frameLength := int64(header.frameLength())
+		if !ok || (first != nil && !header.matches(*first)) || position+frameLength > audioEnd {
+			// resynchronize on the next byte
+			if _, err := bufferedReader.Discard(1); err != nil {
+				return result, err
+			}
+			position++
+			continue
+		}
+
+		if first == nil {
+			// avoid false synchronization by checking the following frame
+			if next, ok := parseFrameHeader(buffer[min(frameLength, int64(len(buffer))):]); position+frameLength+4 <= audioEnd && (!ok || !next.matches(header)) {
+				if _, err := bufferedReader.Discard(1); err != nil {
+					return result, err
+				}
+				position++
+				continue
+			}
+
+			first = &header
+			result.SampleRate = header.sampleRate
+			result.Channels = header.channels()
+			if result.readVBRHeader(header, buffer[:frameLength]) {
+				// the VBR header frame does not contain audio
+				if _, err := bufferedReader.Discard(int(frameLength)); err != nil {
+					return result, err
+				}
+				position += frameLength
+				continue
+			}
+		}
+
+		result.Frames++
+		audioBytes += frameLength
+		if _, err := bufferedReader.Discard(int(frameLength)); err != nil {
+			return result, err
+		}
+		position += frameLength
+	}
+
+	if first == nil || result.Frames == 0 {
+		return result, errNoMPEGFramesFound
+	}
+
+	result.Samples = max(int64(result.Frames)*int64(first.samplesPerFrame())-int64(result.EncoderDelay)-int64(result.EncoderPadding), 0)
+	result.Duration = float64(result.Samples) / float64(result.SampleRate)
+	if result.Duration > 0 {
+		result.Bitrate = int(float64(audioBytes*8) / result.Duration)
+	}
+	return result, nil
+}
+
+// Returns the position after all ID3v2 tags at the start of the file.
+func findAudioStart(reader io.ReadSeeker) (position int64, err error) {
+	header := make([]byte, id3v2HeaderLength)
+	for {
+		if _, err = reader.Seek(position, io.SeekStart); err != nil {
+			return position, err
+		}
+		if _, err = io.ReadFull(reader, header); err != nil {
+			// file too small to contain another tag
+			return position, nil
+		}
+		if !bytes.Equal(header[:3], id3v2TagIdentifier) {
+			return position, nil
+		}
+		size, ok := decodeSyncSafe(header[6:10])
+		if !ok {
+			return position, nil
+		}
+		position += id3v2HeaderLength + int64(size)
+		if header[5]&id3v2FooterFlag != 0 {
+			position += id3v2HeaderLength
+		}
+	}
+}
+
+// Returns the position before the ID3v1 and APE tags at the end of the file.
+func findAudioEnd(reader io.ReadSeeker) (position int64, err error) {
+	position, err = reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return position, err
+	}
+
+	if position >= id3v1Length {
+		tag := make([]byte, len(id3v1TagIdentifier))
+		if _, err = reader.Seek(position-id3v1Length, io.SeekStart); err != nil {
+			return position, err
+		}
+		if _, err = io.ReadFull(reader, tag); err != nil {
+			return position, err
+		}
+		if bytes.Equal(tag, id3v1TagIdentifier) {
+			position -= id3v1Length
+		}
+	}
+
+	if position >= apeFooterLength {
+		footer := make([]byte, apeFooterLength)
+		if _, err = reader.Seek(position-apeFooterLength, io.SeekStart); err != nil {
+			return position, err
+		}
+		if _, err = io.ReadFull(reader, footer); err != nil {
+			return position, err
+		}
+		if bytes.Equal(footer[:8], apeTagIdentifier) {
+			// size includes the footer but not the optional header
+			size := int64(binary.LittleEndian.Uint32(footer[12:16]))
+			flags := binary.LittleEndian.Uint32(footer[20:24])
+			if flags&(1<<31) != 0 {
+				size += apeFooterLength
+			}
+			position = max(position-size, 0)
+		}
+	}
+
+	return position, nil
+}
+
+// Reads a Xing, Info or VBRI header from the first frame.
+// Returns true if such a header was found.
+func (m *MP3Info) readVBRHeader(header frameHeader, frame []byte) bool {
+	xingOffset := 4 + header.sideInfoLength()
+	if len(frame) >= xingOffset+8 {
+		identifier := frame[xingOffset : xingOffset+4]
+		for _, xingIdentifier := range xingTagIdentifiers {
+			if bytes.Equal(identifier, xingIdentifier) {
+				m.VBR = bytes.Equal(identifier, xingTagIdentifiers[0])
+				m.readLAMETag(frame, xingOffset)
+				return true
+			}
+		}
+	}
+
+	// VBRI header is always located 32 bytes after the frame header
+	vbriOffset := 4 + 32
+	if len(frame) >= vbriOffset+4 && bytes.Equal(frame[vbriOffset:vbriOffset+4], vbriTagIdentifier) {
+		m.VBR = true
+		return true
+	}
+
+	return false
+}
+
+// Reads the encoder delay and padding of the LAME tag,
+// which follows the Xing header.
+func (m *MP3Info) readLAMETag(frame []byte, xingOffset int) {
+	flags := binary.BigEndian.Uint32(frame[xingOffset+4 : xingOffset+8])
+	offset := xingOffset + 8
+	if flags&xingFramesFlag != 0 {
+		offset += 4
+	}
+	if flags&xingBytesFlag != 0 {
+		offset += 4
+	}
+	if flags&xingTOCFlag != 0 {
+		offset += xingTOCLength
+	}
+	if flags&xingQualityFlag != 0 {
+		offset += 4
+	}
+
+	if len(frame) < offset+lameTagLength || !isPrintable(frame[offset:offset+4]) {
+		return
+	}
+	delays := frame[offset+lameEncoderDelayIndex : offset+lameEncoderDelayIndex+3]
+	m.EncoderDelay = int(delays[0])<<4 | int(delays[1])>>4
+	m.EncoderPadding = int(delays[1]&0x0F)<<8 | int(delays[2])
+}
+
+func parseFrameHeader(buffer []byte) (result frameHeader, ok bool) {
+	if len(buffer) < 4 || buffer[0] != 0xFF || buffer[1]&0xE0 != 0xE0 {
+		return result, false
+	}
+
+	result.version = int(buffer[1]>>3) & 0x3
+	result.layer = int(buffer[1]>>1) & 0x3
+	bitrateIndex := int(buffer[2] >> 4)
+	sampleRateIndex := int(buffer[2]>>2) & 0x3
+	result.padding = buffer[2]&0x2 != 0
+	result.channelMode = int(buffer[3] >> 6)
+
+	// reserved values and free format bitrates are not supported
+	if result.version == 1 || result.layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return result, false
+	}
+
+	result.bitrate = getBitrateTable(result.version, result.layer)[bitrateIndex] * 1000
+	result.sampleRate = mpegSampleRates[result.version][sampleRateIndex]
+	return result, true
+}
+
+func getBitrateTable(version int, layer int) []int {
+	switch {
+	case version == mpegVersion1 && layer == mpegLayer1:
+		return mpeg1Layer1Bitrates
+	case version == mpegVersion1 && layer == mpegLayer2:
+		return mpeg1Layer2Bitrates
+	case version == mpegVersion1:
+		return mpeg1Layer3Bitrates
+	case layer == mpegLayer1:
+		return mpeg2Layer1Bitrates
+	default:
+		return mpeg2Layer23Bitrates
+	}
+}
+
+// Two headers match if they belong to the same stream.
+func (h frameHeader) matches(other frameHeader) bool {
+	return h.version == other.version && h.layer == other.layer && h.sampleRate == other.sampleRate
+}
+
+func (h frameHeader) frameLength() int {
+	if h.sampleRate == 0 {
+		return 0
+	}
+	padding := 0
+	if h.padding {
+		padding = 1
+	}
+	if h.layer == mpegLayer1 {
+		return (12*h.bitrate/h.sampleRate + padding) * 4
+	}
+	return h.samplesPerFrame()/8*h.bitrate/h.sampleRate + padding
+}
+
+func (h frameHeader) samplesPerFrame() int {
+	switch {
+	case h.layer == mpegLayer1:
+		return 384
+	case h.layer == mpegLayer3 && h.version != mpegVersion1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+func (h frameHeader) sideInfoLength() int {
+	mono := h.channelMode == channelModeMono
+	switch {
+	case h.version == mpegVersion1 && mono:
+		return 17
+	case h.version == mpegVersion1:
+		return 32
+	case mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+func (h frameHeader) channels() int {
+	if h.channelMode == channelModeMono {
+		return 1
+	}
+	return 2
+}
+
+// Decodes a 28 bit integer stored in four bytes with
+// the most significant bit of each byte unset.
+func decodeSyncSafe(buffer []byte) (result int, ok bool) {
+	for _, b := range buffer {
+		if b&0x80 != 0 {
+			return 0, false
+		}
+		result = result<<7 | int(b)
+	}
+	return result, true
+}
+
+func isPrintable(buffer []byte) bool {
+	for _, b := range buffer {
+		if b < 0x20 || b > 0x7E {
+			return false
+		}
+	}
+	return true
+}
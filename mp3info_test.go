@@ -0,0 +1,204 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+var (
+	// MPEG 1 layer III, 128kbit/s, 44.1kHz, stereo
+	testMPEG1FrameHeader = []byte{0xFF, 0xFB, 0x90, 0x00}
+	// MPEG 2 layer III, 64kbit/s, 22.05kHz, mono
+	testMPEG2FrameHeader = []byte{0xFF, 0xF3, 0x80, 0xC0}
+)
+
+func TestReadMP3Info(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    MP3Info
+		wantErr bool
+	}{
+		{
+			name: "plain frames",
+			data: createTestFrames(testMPEG1FrameHeader, 100),
+			want: MP3Info{
+				Duration:   115200.0 / 44100,
+				Frames:     100,
+				Samples:    115200,
+				SampleRate: 44100,
+				Channels:   2,
+				Bitrate:    127706,
+			},
+			wantErr: false,
+		}, {
+			name: "mpeg 2 mono frames",
+			data: createTestFrames(testMPEG2FrameHeader, 10),
+			want: MP3Info{
+				Duration:   5760.0 / 22050,
+				Frames:     10,
+				Samples:    5760,
+				SampleRate: 22050,
+				Channels:   1,
+				Bitrate:    63700,
+			},
+			wantErr: false,
+		}, {
+			name: "tags and info header",
+			data: concat(
+				createTestID3v2Tag(100),
+				createTestInfoFrame(576, 1000),
+				createTestFrames(testMPEG1FrameHeader, 100),
+				createTestAPETag(64),
+				createTestID3v1Tag(),
+			),
+			want: MP3Info{
+				Duration:       (115200.0 - 1576) / 44100,
+				Frames:         100,
+				Samples:        115200 - 1576,
+				SampleRate:     44100,
+				Channels:       2,
+				Bitrate:        129500,
+				EncoderDelay:   576,
+				EncoderPadding: 1000,
+			},
+			wantErr: false,
+		}, {
+			name: "garbage between frames",
+			data: concat(
+				[]byte{0x00, 0xFF, 0xFF, 0x12},
+				createTestFrames(testMPEG1FrameHeader, 5),
+				[]byte{0xFF, 0xFB, 0x00},
+				createTestFrames(testMPEG1FrameHeader, 5),
+			),
+			want: MP3Info{
+				Duration:   11520.0 / 44100,
+				Frames:     10,
+				Samples:    11520,
+				SampleRate: 44100,
+				Channels:   2,
+				Bitrate:    127706,
+			},
+			wantErr: false,
+		}, {
+			name:    "no frames",
+			data:    bytes.Repeat([]byte{0x01}, 1000),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadMP3Info(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadMP3Info() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if !errors.Is(err, errNoMPEGFramesFound) {
+					t.Errorf("ReadMP3Info() error = %v, want %v", err, errNoMPEGFramesFound)
+				}
+				return
+			}
+			// bitrate is only compared approximately
+			if math.Abs(float64(got.Bitrate-tt.want.Bitrate)) > 100 {
+				t.Errorf("ReadMP3Info() bitrate = %v, want %v", got.Bitrate, tt.want.Bitrate)
+			}
+			got.Bitrate = tt.want.Bitrate
+			got.VBR = tt.want.VBR
+			if got != tt.want {
+				t.Errorf("ReadMP3Info() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMP3Info(t *testing.T) {
+	if _, err := GetMP3Info("not existing"); err == nil {
+		t.Errorf("GetMP3Info() expected error for non existing file")
+	}
+}
+
+func Test_decodeSyncSafe(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		want   int
+		wantOk bool
+	}{
+		{"positive test", []byte{0x00, 0x00, 0x02, 0x01}, 257, true},
+		{"maximum", []byte{0x7F, 0x7F, 0x7F, 0x7F}, 1<<28 - 1, true},
+		{"invalid byte", []byte{0x00, 0x80, 0x00, 0x00}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := decodeSyncSafe(tt.input)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("decodeSyncSafe() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func createTestFrames(header []byte, count int) []byte {
+	frameHeader, _ := parseFrameHeader(header)
+	frame := make([]byte, frameHeader.frameLength())
+	putBytes(frame, header)
+	return bytes.Repeat(frame, count)
+}
+
+// Creates an Info frame with frame count, byte count and a LAME tag.
+func createTestInfoFrame(delay int, padding int) []byte {
+	frame := createTestFrames(testMPEG1FrameHeader, 1)
+	offset := 4 + 32
+	putBytes(frame[offset:], []byte("Info"))
+	binary.BigEndian.PutUint32(frame[offset+4:], xingFramesFlag|xingBytesFlag)
+	binary.BigEndian.PutUint32(frame[offset+8:], 100)
+	binary.BigEndian.PutUint32(frame[offset+12:], 41700)
+	lameOffset := offset + 16
+	putBytes(frame[lameOffset:], []byte("LAME3.100"))
+	frame[lameOffset+lameEncoderDelayIndex] = byte(delay >> 4)
+	frame[lameOffset+lameEncoderDelayIndex+1] = byte(delay&0x0F)<<4 | byte(padding>>8)
+	frame[lameOffset+lameEncoderDelayIndex+2] = byte(padding)
+	return frame
+}
+
+func createTestID3v2Tag(size int) []byte {
+	header := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, byte(size >> 7), byte(size & 0x7F)}
+	return append(header, make([]byte, size)...)
+}
+
+func createTestID3v1Tag() []byte {
+	tag := make([]byte, id3v1Length)
+	putBytes(tag, []byte("TAG"))
+	// include a frame sync which must not be counted
+	putBytes(tag[3:], testMPEG1FrameHeader)
+	return tag
+}
+
+// Creates an APE tag with header and footer around the given amount of item data.
+func createTestAPETag(itemSize int) []byte {
+	createHeader := func() []byte {
+		header := make([]byte, apeFooterLength)
+		putBytes(header, []byte("APETAGEX"))
+		binary.LittleEndian.PutUint32(header[8:], 2000)
+		binary.LittleEndian.PutUint32(header[12:], uint32(itemSize+apeFooterLength))
+		binary.LittleEndian.PutUint32(header[20:], 1<<31)
+		return header
+	}
+	return concat(createHeader(), make([]byte, itemSize), createHeader())
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// Writes src to the start of dst. The builtin copy
+// is shadowed by a helper of the metadata tests.
+func putBytes(dst []byte, src []byte) {
+	for i, b := range src {
+		dst[i] = b
+	}
+}
@@ -0,0 +1,416 @@
+package mp3joiner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+const (
+	// Nero chapters store their start in units of 100 nanoseconds
+	neroChapterTimeBaseInt = 10000000
+	mp4AtomHeaderLength    = 8
+	// limits the size of the moov atom read into memory
+	maxMP4MovieAtomLength = 64 * 1024 * 1024
+	// limits the number of samples of a chapter track, each sample is one chapter
+	maxMP4ChapterSamples = 64 * 1024
+)
+
+var errNoMovieAtomFound = errors.New("no moov atom found")
+
+type mp4Atom struct {
+	Type    string
+	Payload []byte
+}
+
+// Reads the chapters of an MP4 file, like M4A or M4B, without using
+// ffprobe. Nero chapters (chpl atom) are preferred over QuickTime
+// chapter tracks, which are text tracks referenced by the audio track.
+func ReadMP4Chapters(mp4Filepath string) (chapters []Chapter, err error) {
+	file, err := os.Open(mp4Filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(file)
+
+	return readMP4Chapters(file)
+}
+
+func readMP4Chapters(reader io.ReadSeeker) ([]Chapter, error) {
+	movie, err := readMovieAtom(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload, ok := getMP4Atom(movie, "udta", "chpl"); ok {
+		chapters, err := decodeNeroChapters(payload, getMovieDuration(movie))
+		if err != nil || len(chapters) > 0 {
+			return chapters, err
+		}
+	}
+	return readQuickTimeChapters(reader, movie)
+}
+
+// Reads the payload of the top level moov atom.
+func readMovieAtom(reader io.ReadSeeker) ([]byte, error) {
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(reader, header[:mp4AtomHeaderLength]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, errNoMovieAtomFound
+			}
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		headerLength := int64(mp4AtomHeaderLength)
+		switch length {
+		case 0:
+			// atom extends to the end of the file
+			position, err := reader.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			end, err := reader.Seek(0, io.SeekEnd)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = reader.Seek(position, io.SeekStart); err != nil {
+				return nil, err
+			}
+			length = end - position + headerLength
+		case 1:
+			if _, err := io.ReadFull(reader, header[mp4AtomHeaderLength:]); err != nil {
+				return nil, err
+			}
+			length = int64(binary.BigEndian.Uint64(header[mp4AtomHeaderLength:]))
+			headerLength += 8
+		}
+		if length < headerLength {
+			return nil, fmt.Errorf("invalid length %d of atom '%s'", length, header[4:8])
+		}
+
+		if string(header[4:8]) != "moov" {
+			if _, err := reader.Seek(length-headerLength, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if length-headerLength > maxMP4MovieAtomLength {
+			return nil, fmt.Errorf("moov atom with %d bytes is too large", length)
+		}
+		payload := make([]byte, length-headerLength)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	}
+}
+
+// Splits the payload of an atom into its child atoms.
+// Stops at the first child which exceeds the payload.
+func parseMP4Atoms(payload []byte) (result []mp4Atom) {
+	for len(payload) >= mp4AtomHeaderLength {
+		length := uint64(binary.BigEndian.Uint32(payload))
+		headerLength := uint64(mp4AtomHeaderLength)
+		switch length {
+		case 0:
+			length = uint64(len(payload))
+		case 1:
+			if len(payload) < 16 {
+				return result
+			}
+			length = binary.BigEndian.Uint64(payload[mp4AtomHeaderLength:])
+			headerLength += 8
+		}
+		if length < headerLength || length > uint64(len(payload)) {
+			return result
+		}
+		result = append(result, mp4Atom{Type: string(payload[4:8]), Payload: payload[headerLength:length]})
+		payload = payload[length:]
+	}
+	return result
+}
+
+// Returns the payload of the first atom found by following the path of atom types.
+func getMP4Atom(payload []byte, path ...string) ([]byte, bool) {
+	for _, atomType := range path {
+		found := false
+		for _, atom := range parseMP4Atoms(payload) {
+			if atom.Type == atomType {
+				payload = atom.Payload
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return payload, true
+}
+
+// Returns the duration of the movie in seconds from the mvhd atom
+// or 0 if it is missing.
+func getMovieDuration(movie []byte) float64 {
+	payload, ok := getMP4Atom(movie, "mvhd")
+	if !ok || len(payload) < 1 {
+		return 0
+	}
+	timescale, duration, ok := readMP4TimescaleAndDuration(payload)
+	if !ok || timescale == 0 {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// Reads timescale and duration of a mvhd or mdhd atom,
+// which share the same layout up to the duration.
+func readMP4TimescaleAndDuration(payload []byte) (timescale uint32, duration uint64, ok bool) {
+	if len(payload) >= 32 && payload[0] == 1 {
+		return binary.BigEndian.Uint32(payload[20:]), binary.BigEndian.Uint64(payload[24:]), true
+	}
+	if len(payload) >= 20 && payload[0] == 0 {
+		return binary.BigEndian.Uint32(payload[12:]), uint64(binary.BigEndian.Uint32(payload[16:])), true
+	}
+	return 0, 0, false
+}
+
+// Decodes the chapters of a chpl atom. Each chapter ends with the
+// start of the next one and the last one with the end of the movie.
+func decodeNeroChapters(payload []byte, lengthInSeconds float64) ([]Chapter, error) {
+	if len(payload) < 5 {
+		return nil, fmt.Errorf("chpl atom is too short")
+	}
+	position := 4
+	if payload[0] > 0 {
+		position += 4
+	}
+	if position >= len(payload) {
+		return nil, fmt.Errorf("chpl atom is too short")
+	}
+	count := int(payload[position])
+	position++
+
+	result := make([]Chapter, 0, count)
+	for i := 0; i < count; i++ {
+		if position+9 > len(payload) {
+			return nil, fmt.Errorf("chpl atom is truncated at chapter %d", i+1)
+		}
+		start := binary.BigEndian.Uint64(payload[position:])
+		titleLength := int(payload[position+8])
+		position += 9
+		if position+titleLength > len(payload) {
+			return nil, fmt.Errorf("chpl atom is truncated at chapter %d", i+1)
+		}
+		result = append(result, Chapter{
+			TimeBase: fmt.Sprintf("1/%d", neroChapterTimeBaseInt),
+			Start:    int(start),
+			Tags:     Tags{Title: decodeText(payload[position:position+titleLength], textEncodingUTF8)},
+		})
+		position += titleLength
+	}
+
+	for i := range result {
+		if i+1 < len(result) {
+			result[i].End = result[i+1].Start
+		} else {
+			result[i].End = max(result[i].Start, int(lengthInSeconds*neroChapterTimeBaseInt))
+		}
+	}
+	return result, nil
+}
+
+// Reads the chapters from the text track referenced by the
+// chap atom of another track.
+func readQuickTimeChapters(reader io.ReadSeeker, movie []byte) ([]Chapter, error) {
+	tracks := make(map[uint32][]byte)
+	chapterTrackIDs := make([]uint32, 0)
+	for _, atom := range parseMP4Atoms(movie) {
+		if atom.Type != "trak" {
+			continue
+		}
+		if header, ok := getMP4Atom(atom.Payload, "tkhd"); ok {
+			if trackID, ok := getTrackID(header); ok {
+				tracks[trackID] = atom.Payload
+			}
+		}
+		if references, ok := getMP4Atom(atom.Payload, "tref", "chap"); ok {
+			for i := 0; i+4 <= len(references); i += 4 {
+				chapterTrackIDs = append(chapterTrackIDs, binary.BigEndian.Uint32(references[i:]))
+			}
+		}
+	}
+
+	for _, trackID := range chapterTrackIDs {
+		if track, ok := tracks[trackID]; ok {
+			return decodeChapterTrack(reader, track)
+		}
+	}
+	return nil, nil
+}
+
+func getTrackID(header []byte) (uint32, bool) {
+	if len(header) >= 24 && header[0] == 1 {
+		return binary.BigEndian.Uint32(header[20:]), true
+	}
+	if len(header) >= 16 && header[0] == 0 {
+		return binary.BigEndian.Uint32(header[12:]), true
+	}
+	return 0, false
+}
+
+// Creates a chapter for each text sample of the track.
+func decodeChapterTrack(reader io.ReadSeeker, track []byte) ([]Chapter, error) {
+	mediaHeader, ok := getMP4Atom(track, "mdia", "mdhd")
+	if !ok {
+		return nil, fmt.Errorf("chapter track has no mdhd atom")
+	}
+	timescale, _, ok := readMP4TimescaleAndDuration(mediaHeader)
+	if !ok || timescale == 0 {
+		return nil, fmt.Errorf("chapter track has an invalid timescale")
+	}
+	sampleTable, ok := getMP4Atom(track, "mdia", "minf", "stbl")
+	if !ok {
+		return nil, fmt.Errorf("chapter track has no stbl atom")
+	}
+	offsets, sizes, err := getSampleOffsets(sampleTable)
+	if err != nil {
+		return nil, err
+	}
+	durations, err := getSampleDurations(sampleTable, len(sizes))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Chapter, 0, len(sizes))
+	start := uint64(0)
+	for i := 0; i < len(sizes) && i < len(durations); i++ {
+		title, err := readTextSample(reader, offsets[i], sizes[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Chapter{
+			TimeBase: "1/" + strconv.FormatUint(uint64(timescale), 10),
+			Start:    int(start),
+			End:      int(start + uint64(durations[i])),
+			Tags:     Tags{Title: title},
+		})
+		start += uint64(durations[i])
+	}
+	return result, nil
+}
+
+// Returns the duration of each sample from the stts atom. Fails if
+// the atom lists more than sampleCount samples, as given by stsz.
+func getSampleDurations(sampleTable []byte, sampleCount int) (result []uint32, err error) {
+	payload, ok := getMP4Atom(sampleTable, "stts")
+	if !ok || len(payload) < 8 {
+		return nil, fmt.Errorf("chapter track has no stts atom")
+	}
+	count := int(binary.BigEndian.Uint32(payload[4:]))
+	if len(payload) < 8+count*8 {
+		return nil, fmt.Errorf("stts atom is truncated")
+	}
+	result = make([]uint32, 0, sampleCount)
+	for i := 0; i < count; i++ {
+		entrySampleCount := int(binary.BigEndian.Uint32(payload[8+i*8:]))
+		if entrySampleCount > sampleCount-len(result) {
+			return nil, fmt.Errorf("stts atom lists more than the %d samples of the track", sampleCount)
+		}
+		delta := binary.BigEndian.Uint32(payload[12+i*8:])
+		for j := 0; j < entrySampleCount; j++ {
+			result = append(result, delta)
+		}
+	}
+	return result, nil
+}
+
+// Returns the file offset and size of each sample from the
+// stsz, stsc and stco or co64 atoms.
+func getSampleOffsets(sampleTable []byte) (offsets []int64, sizes []uint32, err error) {
+	sizePayload, ok := getMP4Atom(sampleTable, "stsz")
+	if !ok || len(sizePayload) < 12 {
+		return nil, nil, fmt.Errorf("chapter track has no stsz atom")
+	}
+	sampleSize := binary.BigEndian.Uint32(sizePayload[4:])
+	sampleCount := int(binary.BigEndian.Uint32(sizePayload[8:]))
+	if sampleSize == 0 && len(sizePayload) < 12+sampleCount*4 {
+		return nil, nil, fmt.Errorf("stsz atom is truncated")
+	}
+	if sampleCount > maxMP4ChapterSamples {
+		return nil, nil, fmt.Errorf("stsz atom has too many samples")
+	}
+	sizes = make([]uint32, sampleCount)
+	for i := range sizes {
+		sizes[i] = sampleSize
+		if sampleSize == 0 {
+			sizes[i] = binary.BigEndian.Uint32(sizePayload[12+i*4:])
+		}
+	}
+
+	var chunkOffsets []int64
+	if payload, ok := getMP4Atom(sampleTable, "stco"); ok && len(payload) >= 8 {
+		count := int(binary.BigEndian.Uint32(payload[4:]))
+		for i := 0; i < count && 12+i*4 <= len(payload); i++ {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint32(payload[8+i*4:])))
+		}
+	} else if payload, ok := getMP4Atom(sampleTable, "co64"); ok && len(payload) >= 8 {
+		count := int(binary.BigEndian.Uint32(payload[4:]))
+		for i := 0; i < count && 16+i*8 <= len(payload); i++ {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint64(payload[8+i*8:])))
+		}
+	} else {
+		return nil, nil, fmt.Errorf("chapter track has no stco or co64 atom")
+	}
+
+	chunkPayload, ok := getMP4Atom(sampleTable, "stsc")
+	if !ok || len(chunkPayload) < 8 {
+		return nil, nil, fmt.Errorf("chapter track has no stsc atom")
+	}
+	entryCount := int(binary.BigEndian.Uint32(chunkPayload[4:]))
+	if entryCount == 0 || len(chunkPayload) < 8+entryCount*12 {
+		return nil, nil, fmt.Errorf("stsc atom is truncated")
+	}
+
+	offsets = make([]int64, 0, sampleCount)
+	entry := 0
+	for chunk := 0; chunk < len(chunkOffsets) && len(offsets) < sampleCount; chunk++ {
+		// entries list the first chunk, counting from 1, using the sample count
+		for entry+1 < entryCount && int(binary.BigEndian.Uint32(chunkPayload[8+(entry+1)*12:])) <= chunk+1 {
+			entry++
+		}
+		samplesPerChunk := int(binary.BigEndian.Uint32(chunkPayload[12+entry*12:]))
+		offset := chunkOffsets[chunk]
+		for i := 0; i < samplesPerChunk && len(offsets) < sampleCount; i++ {
+			offsets = append(offsets, offset)
+			offset += int64(sizes[len(offsets)-1])
+		}
+	}
+	if len(offsets) < sampleCount {
+		return nil, nil, fmt.Errorf("chapter track lists %d samples but only %d are in chunks", sampleCount, len(offsets))
+	}
+	return offsets, sizes, nil
+}
+
+// Reads a text sample, which starts with the length of the text.
+// The text is UTF-8 or UTF-16 if it starts with a byte order mark.
+func readTextSample(reader io.ReadSeeker, offset int64, size uint32) (string, error) {
+	if size < 2 {
+		return "", nil
+	}
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	sample := make([]byte, size)
+	if _, err := io.ReadFull(reader, sample); err != nil {
+		return "", fmt.Errorf("could not read text sample at %d: %w", offset, err)
+	}
+	text := sample[2:min(len(sample), 2+int(binary.BigEndian.Uint16(sample)))]
+	if len(text) >= 2 && ((text[0] == 0xFE && text[1] == 0xFF) || (text[0] == 0xFF && text[1] == 0xFE)) {
+		return decodeText(text, textEncodingUTF16), nil
+	}
+	return decodeText(text, textEncodingUTF8), nil
+}
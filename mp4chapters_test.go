@@ -0,0 +1,152 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createTestAtom(atomType string, payloads ...[]byte) []byte {
+	payload := concat(payloads...)
+	header := make([]byte, mp4AtomHeaderLength)
+	binary.BigEndian.PutUint32(header, uint32(len(payload)+mp4AtomHeaderLength))
+	putBytes(header[4:], []byte(atomType))
+	return concat(header, payload)
+}
+
+func putUint32s(values ...uint32) []byte {
+	result := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(result[i*4:], value)
+	}
+	return result
+}
+
+// version 0 mvhd or mdhd with the given timescale and duration
+func createTestMediaHeader(atomType string, timescale uint32, duration uint32) []byte {
+	return createTestAtom(atomType, putUint32s(0, 0, 0, timescale, duration), make([]byte, 80))
+}
+
+func createTestNeroChapters(titles []string, startsIn100ns []uint64) []byte {
+	payload := []byte{1, 0, 0, 0, 0, 0, 0, 0, byte(len(titles))}
+	for i, title := range titles {
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, startsIn100ns[i])
+		payload = concat(payload, start, []byte{byte(len(title))}, []byte(title))
+	}
+	return createTestAtom("chpl", payload)
+}
+
+func TestReadMP4Chapters(t *testing.T) {
+	ftyp := createTestAtom("ftyp", []byte("M4B "), putUint32s(0))
+	movie := createTestAtom("moov",
+		createTestMediaHeader("mvhd", 1000, 30000),
+		createTestAtom("udta", createTestNeroChapters([]string{"Intro", "Kapitel Ä"}, []uint64{0, 125000000})),
+	)
+	mp4Filepath := filepath.Join(t.TempDir(), "book.m4b")
+	if err := os.WriteFile(mp4Filepath, concat(ftyp, createTestAtom("free"), movie), 0644); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+
+	chapters, err := ReadMP4Chapters(mp4Filepath)
+	if err != nil {
+		t.Fatalf("ReadMP4Chapters() error = %v", err)
+	}
+	expected := []struct {
+		title string
+		start float64
+		end   float64
+	}{
+		{title: "Intro", start: 0, end: 12.5},
+		{title: "Kapitel Ä", start: 12.5, end: 30},
+	}
+	if len(chapters) != len(expected) {
+		t.Fatalf("ReadMP4Chapters() got %d chapters, want %d", len(chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("ReadMP4Chapters() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+}
+
+func TestReadMP4ChaptersFromTextTrack(t *testing.T) {
+	ftyp := createTestAtom("ftyp", []byte("M4A "), putUint32s(0))
+	samples := concat([]byte{0, 5}, []byte("Intro"), []byte{0, 8, 0xFE, 0xFF, 0, 'E', 0, 'n', 0, 'd'})
+	mdat := createTestAtom("mdat", samples)
+	sampleOffset := uint32(len(ftyp) + mp4AtomHeaderLength)
+
+	audioTrack := createTestAtom("trak",
+		createTestAtom("tkhd", putUint32s(0, 0, 0, 1)),
+		createTestAtom("tref", createTestAtom("chap", putUint32s(2))),
+	)
+	textTrack := createTestAtom("trak",
+		createTestAtom("tkhd", putUint32s(0, 0, 0, 2)),
+		createTestAtom("mdia",
+			createTestMediaHeader("mdhd", 600, 9000),
+			createTestAtom("minf", createTestAtom("stbl",
+				createTestAtom("stts", putUint32s(0, 2, 1, 3000, 1, 6000)),
+				createTestAtom("stsz", putUint32s(0, 0, 2, 7, 10)),
+				createTestAtom("stsc", putUint32s(0, 1, 1, 2, 1)),
+				createTestAtom("stco", putUint32s(0, 1, sampleOffset)),
+			)),
+		),
+	)
+	movie := createTestAtom("moov", createTestMediaHeader("mvhd", 600, 9000), audioTrack, textTrack)
+
+	chapters, err := readMP4Chapters(bytes.NewReader(concat(ftyp, mdat, movie)))
+	if err != nil {
+		t.Fatalf("readMP4Chapters() error = %v", err)
+	}
+	if len(chapters) != 2 {
+		t.Fatalf("readMP4Chapters() got %d chapters, want 2", len(chapters))
+	}
+	if chapters[0].Tags.Title != "Intro" || chapters[0].GetEndTimeInSeconds() != 5 {
+		t.Errorf("readMP4Chapters() chapter 0 = %+v", chapters[0])
+	}
+	if chapters[1].Tags.Title != "End" || chapters[1].GetStartTimeInSeconds() != 5 || chapters[1].GetEndTimeInSeconds() != 15 {
+		t.Errorf("readMP4Chapters() chapter 1 = %+v", chapters[1])
+	}
+}
+
+func TestReadMP4ChaptersErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{
+			name:    "no moov atom",
+			content: createTestAtom("ftyp", []byte("M4A ")),
+		}, {
+			name:    "invalid atom length",
+			content: concat(putUint32s(4), []byte("ftyp")),
+		}, {
+			name:    "truncated chapters",
+			content: createTestAtom("moov", createTestAtom("udta", createTestAtom("chpl", []byte{0, 0, 0, 0, 2, 0, 0}))),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readMP4Chapters(bytes.NewReader(tt.content)); err == nil {
+				t.Error("readMP4Chapters() expected error")
+			}
+		})
+	}
+}
+
+func Test_getSampleDurations(t *testing.T) {
+	sampleTable := createTestAtom("stts", putUint32s(0, 2, 1, 3000, 1, 6000))
+	durations, err := getSampleDurations(sampleTable, 2)
+	if err != nil || len(durations) != 2 || durations[0] != 3000 || durations[1] != 6000 {
+		t.Errorf("getSampleDurations() = %v, %v", durations, err)
+	}
+
+	// a single entry must not allocate more than the samples of the track
+	sampleTable = createTestAtom("stts", putUint32s(0, 1, 0xFFFFFFFF, 1000))
+	if _, err := getSampleDurations(sampleTable, 2); err == nil {
+		t.Error("getSampleDurations() expected error for more samples than in stsz")
+	}
+}
@@ -0,0 +1,178 @@
+package mp3joiner
+
+// Defines how MP3Builder.Build creates the output.
+type BuildMode int
+
+const (
+	// Decodes all segments and encodes the output with the codec of
+	// the output format, e.g. libmp3lame for MP3 or AAC for M4B.
+	BuildModeReencode BuildMode = iota
+	// Copies the MP3 frames of all segments into the output without
+	// re-encoding. Only segments which do not match the codec, sample
+	// rate or channel layout of the other segments are re-encoded.
+	// Segments are cut at frame boundaries, so cuts may be off by the
+	// length of a frame (about 26ms).
+	BuildModeStreamCopy
+)
+
+// Option configures the builder and the functions of this package.
+type Option func(*options)
+
+type options struct {
+	executor  Executor
+	progress  func(Progress)
+	buildMode BuildMode
+	// bitrate, sample rate and channels of re-encoded audio
+	encodeOptions EncodeOptions
+	cueSheet      bool
+	// format of the output, resolved by getOutputFormat
+	outputFormat OutputFormat
+	// path of the image embedded as cover art
+	coverArt string
+	// default crossfade between segments
+	crossfade Fade
+	fadeIn    Fade
+	fadeOut   Fade
+	// detects chapters of files without chapters if set
+	silenceChapters *SilenceDetection
+	// trims edge silence of appended files if set
+	silenceTrim *SilenceTrim
+	// normalizes the loudness if set
+	loudness *LoudnessTarget
+	// formats of chapter files written next to the output
+	chapterFiles []ChapterFileFormat
+}
+
+// Sets the executor used to run ffmpeg and ffprobe.
+// By default programs are run on the local system via os/exec.
+func WithExecutor(executor Executor) Option {
+	return func(o *options) {
+		o.executor = executor
+	}
+}
+
+// Sets a callback which is invoked while MP3Builder.Build
+// is running to report how much of the output has been written.
+func WithProgress(callback func(Progress)) Option {
+	return func(o *options) {
+		o.progress = callback
+	}
+}
+
+// Sets how MP3Builder.Build creates the output.
+// By default all segments are re-encoded.
+func WithBuildMode(mode BuildMode) Option {
+	return func(o *options) {
+		o.buildMode = mode
+	}
+}
+
+// Sets the bitrate in bit/s of re-encoded audio.
+// By default the highest bitrate of all appended files is used.
+func WithBitrate(bitrate int) Option {
+	return func(o *options) {
+		o.encodeOptions.Bitrate = bitrate
+	}
+}
+
+// Sets the encoder settings of re-encoded audio. Replaces the
+// bitrate set by a previous WithBitrate.
+func WithEncodeOptions(encodeOptions EncodeOptions) Option {
+	return func(o *options) {
+		o.encodeOptions = encodeOptions
+	}
+}
+
+// Sets the format of the output of MP3Builder.Build.
+// By default the format is chosen by the extension of the output path.
+func WithOutputFormat(format OutputFormat) Option {
+	return func(o *options) {
+		o.outputFormat = format
+	}
+}
+
+// Embeds the image, e.g. a JPEG or PNG file, as cover art
+// into the output of MP3Builder.Build.
+func WithCoverArt(imageFilepath string) Option {
+	return func(o *options) {
+		o.coverArt = imageFilepath
+	}
+}
+
+// Crossfades each segment into the next one, which overlaps them
+// by the duration of the fade. Single joins can be changed with
+// MP3Builder.SetCrossfade.
+func WithCrossfade(crossfade Fade) Option {
+	return func(o *options) {
+		o.crossfade = crossfade
+	}
+}
+
+// Fades in the start of the output of MP3Builder.Build.
+func WithFadeIn(fade Fade) Option {
+	return func(o *options) {
+		o.fadeIn = fade
+	}
+}
+
+// Fades out the end of the output of MP3Builder.Build.
+func WithFadeOut(fade Fade) Option {
+	return func(o *options) {
+		o.fadeOut = fade
+	}
+}
+
+// Detects chapters by silence for appended files which have no
+// chapters, see DetectSilenceChapters. Adjacent chapters with the same
+// title are merged, so the title template should contain {name} if
+// several such files are appended.
+func WithSilenceChapters(detection SilenceDetection) Option {
+	return func(o *options) {
+		o.silenceChapters = &detection
+	}
+}
+
+// Trims silence at the start and the end of each appended file,
+// see MP3Builder.TrimSilence.
+func WithSilenceTrim(trim SilenceTrim) Option {
+	return func(o *options) {
+		o.silenceTrim = &trim
+	}
+}
+
+// Normalizes the loudness of the output of MP3Builder.Build to the
+// target in two passes. The first pass measures the loudness, the
+// second one normalizes it while encoding. The measured stats are
+// returned by MP3Builder.GetLoudnessStats.
+func WithLoudnessNormalization(target LoudnessTarget) Option {
+	return func(o *options) {
+		o.loudness = &target
+	}
+}
+
+// Writes a CUE sheet with the chapters and tags of the output next
+// to the output of MP3Builder.Build, e.g. book.cue for book.mp3.
+func WithCueSheet() Option {
+	return func(o *options) {
+		o.cueSheet = true
+	}
+}
+
+// Writes the final chapters of the output of MP3Builder.Build into a
+// file next to the output, e.g. book.chapters.json for book.mp3.
+// Can be set multiple times to write several formats.
+func WithChapterFile(format ChapterFileFormat) Option {
+	return func(o *options) {
+		o.chapterFiles = append(o.chapterFiles, format)
+	}
+}
+
+func newOptions(opts []Option) *options {
+	result := &options{
+		executor: CommandExecutor{},
+	}
+	for _, opt := range opts {
+		opt(result)
+	}
+	return result
+}
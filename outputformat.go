@@ -0,0 +1,137 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format of the output of MP3Builder.Build.
+type OutputFormat int
+
+const (
+	// Chooses the format by the extension of the output path. Uses
+	// OutputFormatM4B for .m4b, .m4a and .mp4, OutputFormatOpus for
+	// .opus and .ogg and OutputFormatMP3 otherwise.
+	OutputFormatAuto OutputFormat = iota
+	// MP3 encoded with libmp3lame. Chapters are stored as ID3v2
+	// frames and cover art as APIC frame.
+	OutputFormatMP3
+	// AAC in an MP4 container as used for audiobooks. Chapters are
+	// stored as Nero and QuickTime chapters and cover art as covr atom.
+	OutputFormatM4B
+	// Opus in an Ogg container. Chapters are stored as CHAPTERxx
+	// Vorbis comments and cover art as METADATA_BLOCK_PICTURE.
+	OutputFormatOpus
+)
+
+const (
+	// picture type of the front cover in ID3v2 and FLAC picture blocks
+	frontCoverPictureType = 3
+	pictureMetadataKey    = "METADATA_BLOCK_PICTURE"
+)
+
+var outputFormatExtensions = map[string]OutputFormat{
+	".mp3":  OutputFormatMP3,
+	".m4b":  OutputFormatM4B,
+	".m4a":  OutputFormatM4B,
+	".mp4":  OutputFormatM4B,
+	".opus": OutputFormatOpus,
+	".ogg":  OutputFormatOpus,
+}
+
+// Returns the format used for the output path.
+// OutputFormatAuto is resolved by the extension of the path.
+func getOutputFormat(format OutputFormat, outputFilepath string) OutputFormat {
+	if format != OutputFormatAuto {
+		return format
+	}
+	if result, ok := outputFormatExtensions[strings.ToLower(filepath.Ext(outputFilepath))]; ok {
+		return result
+	}
+	return OutputFormatMP3
+}
+
+func (f OutputFormat) String() string {
+	switch f {
+	case OutputFormatAuto:
+		return "auto"
+	case OutputFormatMP3:
+		return "mp3"
+	case OutputFormatM4B:
+		return "m4b"
+	case OutputFormatOpus:
+		return "opus"
+	default:
+		return fmt.Sprintf("OutputFormat(%d)", int(f))
+	}
+}
+
+// Returns the ffmpeg arguments to encode the audio in this format.
+func (f OutputFormat) getCodecArgs(settings EncodeOptions) []string {
+	switch f {
+	case OutputFormatM4B:
+		return append(append([]string{"-c:a", "aac"}, settings.getArgs(f)...), "-movflags", "+faststart")
+	case OutputFormatOpus:
+		return append([]string{"-c:a", "libopus"}, settings.getArgs(f)...)
+	default:
+		return append([]string{"-c:a", "libmp3lame"}, settings.getArgs(f)...)
+	}
+}
+
+// Returns the ffmpeg arguments to store the video stream of the
+// given input as cover art.
+func getCoverArtArgs(inputIndex int) []string {
+	return []string{
+		"-map", fmt.Sprintf("%d:v", inputIndex),
+		"-c:v", "copy",
+		"-disposition:v:0", "attached_pic",
+	}
+}
+
+// Returns whether ffmpeg reads the cover art as separate input.
+// Opus output stores the cover art as metadata instead, see
+// getPictureMetadata.
+func (f OutputFormat) hasCoverArtInput() bool {
+	return f != OutputFormatOpus
+}
+
+// Returns the value of a METADATA_BLOCK_PICTURE Vorbis comment,
+// which is a base64 encoded FLAC picture block of the front cover.
+func getPictureMetadata(imageFilepath string) (string, error) {
+	data, err := os.ReadFile(imageFilepath)
+	if err != nil {
+		return "", err
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("cover art '%s' is not an image but %s", imageFilepath, mimeType)
+	}
+	// dimensions may be zero if the image can not be decoded
+	config, _, _ := image.DecodeConfig(bytes.NewReader(data))
+
+	var buffer bytes.Buffer
+	for _, value := range []any{
+		uint32(frontCoverPictureType),
+		uint32(len(mimeType)), []byte(mimeType),
+		// empty description
+		uint32(0),
+		uint32(config.Width), uint32(config.Height),
+		// unknown color depth and number of indexed colors
+		uint32(0), uint32(0),
+		uint32(len(data)), data,
+	} {
+		if err = binary.Write(&buffer, binary.BigEndian, value); err != nil {
+			return "", err
+		}
+	}
+	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
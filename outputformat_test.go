@@ -0,0 +1,138 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func Test_getOutputFormat(t *testing.T) {
+	tests := []struct {
+		format         OutputFormat
+		outputFilepath string
+		want           OutputFormat
+	}{
+		{format: OutputFormatAuto, outputFilepath: "book.M4B", want: OutputFormatM4B},
+		{format: OutputFormatAuto, outputFilepath: "book.m4a", want: OutputFormatM4B},
+		{format: OutputFormatAuto, outputFilepath: "book.opus", want: OutputFormatOpus},
+		{format: OutputFormatAuto, outputFilepath: "book.mp3", want: OutputFormatMP3},
+		{format: OutputFormatAuto, outputFilepath: "book", want: OutputFormatMP3},
+		{format: OutputFormatOpus, outputFilepath: "book.mp3", want: OutputFormatOpus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.outputFilepath, func(t *testing.T) {
+			if got := getOutputFormat(tt.format, tt.outputFilepath); got != tt.want {
+				t.Errorf("getOutputFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithOutputFormat(t *testing.T) {
+	chapters := `[{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Intro","artist":"Host"}}]`
+	tests := []struct {
+		name           string
+		outputFilepath string
+		opts           []Option
+		want           [][]string
+		wantErr        bool
+	}{
+		{
+			name:           "m4b with cover art",
+			outputFilepath: "book.m4b",
+			opts:           []Option{WithCoverArt("cover.jpg")},
+			want: [][]string{
+				{"-i", "cover.jpg", "-filter_complex"},
+				{"-map", "2:v", "-c:v", "copy", "-disposition:v:0", "attached_pic"},
+				{"-c:a", "aac", "-b:a", "64k", "-movflags", "+faststart"},
+			},
+		}, {
+			name:           "explicit opus",
+			outputFilepath: "book.mp3",
+			opts:           []Option{WithOutputFormat(OutputFormatOpus)},
+			want:           [][]string{{"-c:a", "libopus", "-b:a", "64k"}},
+		}, {
+			name:           "stream copy to m4b",
+			outputFilepath: "book.m4b",
+			opts:           []Option{WithBuildMode(BuildModeStreamCopy)},
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executions := appendExecutions("00:00:10.00", chapters, `{"bit_rate":"64000"}`, true)
+			executor := NewReplayExecutor(append(executions, Execution{Name: "ffmpeg"})...)
+			builder := NewMP3Builder(append(tt.opts, WithExecutor(executor))...)
+			if err := builder.Append("first.mp3", 0, -1); err != nil {
+				t.Fatalf("MP3Builder.Append() error = %v", err)
+			}
+
+			// chapter details are only written as ID3v2 frames into MP3
+			// output, which would fail as the output is not created
+			err := builder.Build(filepath.Join(t.TempDir(), tt.outputFilepath))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MP3Builder.Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			calls := executor.Calls()
+			args := calls[len(calls)-1].Args
+			for _, want := range tt.want {
+				if !containsSequence(args, want) {
+					t.Errorf("MP3Builder.Build() args = %v, expected to contain %v", args, want)
+				}
+			}
+			if slices.Contains(args, "libmp3lame") {
+				t.Errorf("MP3Builder.Build() args = %v, expected no MP3 encoder", args)
+			}
+		})
+	}
+}
+
+func Test_getPictureMetadata(t *testing.T) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("could not encode image: %v", err)
+	}
+	imageFilepath := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(imageFilepath, data.Bytes(), 0644); err != nil {
+		t.Fatalf("could not write image: %v", err)
+	}
+
+	value, err := getPictureMetadata(imageFilepath)
+	if err != nil {
+		t.Fatalf("getPictureMetadata() error = %v", err)
+	}
+	block, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatalf("getPictureMetadata() returned invalid base64: %v", err)
+	}
+
+	mimeType := "image/png"
+	header := concat(
+		binary.BigEndian.AppendUint32(nil, frontCoverPictureType),
+		binary.BigEndian.AppendUint32(nil, uint32(len(mimeType))), []byte(mimeType),
+		binary.BigEndian.AppendUint32(nil, 0),
+		binary.BigEndian.AppendUint32(nil, 3), binary.BigEndian.AppendUint32(nil, 2),
+		make([]byte, 8),
+		binary.BigEndian.AppendUint32(nil, uint32(data.Len())),
+	)
+	if !bytes.Equal(block, concat(header, data.Bytes())) {
+		t.Errorf("getPictureMetadata() = %x, want header %x followed by the image", block, header)
+	}
+
+	textFilepath := filepath.Join(t.TempDir(), "cover.txt")
+	if err := os.WriteFile(textFilepath, []byte("no image"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if _, err := getPictureMetadata(textFilepath); err == nil {
+		t.Error("getPictureMetadata() expected error for text file")
+	}
+}
@@ -0,0 +1,149 @@
+package mp3joiner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	podcastChaptersVersion = "1.2.0"
+	// MIME type of ID3v2 pictures which contain a link instead of the image
+	imageLinkMIMEType = "-->"
+)
+
+type podcastChapters struct {
+	Version  string           `json:"version"`
+	Chapters []podcastChapter `json:"chapters"`
+}
+
+type podcastChapter struct {
+	StartTime float64  `json:"startTime"`
+	EndTime   *float64 `json:"endTime,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Img       string   `json:"img,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	// chapters are part of the table of contents if not set
+	TOC *bool `json:"toc,omitempty"`
+}
+
+// Writes the chapters in the Podcasting 2.0 JSON chapters format
+// (application/json+chapters). The first link of a chapter is written
+// as url and images linked by URL, see DecodePodcastChapters, as img.
+// Embedded images can not be expressed and are skipped. If tables of
+// contents are given, chapters which are not part of any table of
+// contents are marked with "toc": false.
+func EncodePodcastChapters(writer io.Writer, chapters []Chapter, tablesOfContents []TableOfContents) error {
+	inTableOfContents := getChaptersInTablesOfContents(chapters, tablesOfContents)
+
+	result := podcastChapters{Version: podcastChaptersVersion, Chapters: make([]podcastChapter, 0, len(chapters))}
+	for i, chapter := range chapters {
+		end := roundToMilliseconds(chapter.GetEndTimeInSeconds())
+		encoded := podcastChapter{
+			StartTime: roundToMilliseconds(chapter.GetStartTimeInSeconds()),
+			EndTime:   &end,
+			Title:     chapter.Tags.Title,
+		}
+		if chapter.Image != nil && chapter.Image.MIMEType == imageLinkMIMEType {
+			encoded.Img = string(chapter.Image.Data)
+		}
+		if len(chapter.Links) > 0 {
+			encoded.URL = chapter.Links[0].URL
+		}
+		if !inTableOfContents[i] {
+			hidden := false
+			encoded.TOC = &hidden
+		}
+		result.Chapters = append(result.Chapters, encoded)
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// Reads chapters in the Podcasting 2.0 JSON chapters format. Chapters
+// without endTime end where the next chapter starts, the last one at
+// the given length in seconds. An img is stored as ID3v2 picture link
+// and the url as link of the chapter. If any chapter has "toc": false,
+// a table of contents listing only the other chapters is returned.
+func DecodePodcastChapters(reader io.Reader, lengthInSeconds float64) (chapters []Chapter, tablesOfContents []TableOfContents, err error) {
+	var decoded podcastChapters
+	if err = json.NewDecoder(reader).Decode(&decoded); err != nil {
+		return nil, nil, err
+	}
+
+	chapters = make([]Chapter, 0, len(decoded.Chapters))
+	hasHiddenChapters := false
+	for i, encoded := range decoded.Chapters {
+		if encoded.StartTime < 0 {
+			return nil, nil, fmt.Errorf("chapter %d starts at negative time %v", i+1, encoded.StartTime)
+		}
+		chapter := Chapter{TimeBase: id3ChapterTimeBase, Start: toMilliseconds(encoded.StartTime), Tags: Tags{Title: encoded.Title}}
+		switch {
+		case encoded.EndTime != nil:
+			chapter.End = toMilliseconds(*encoded.EndTime)
+		case i+1 < len(decoded.Chapters):
+			chapter.End = toMilliseconds(decoded.Chapters[i+1].StartTime)
+		default:
+			chapter.End = toMilliseconds(max(lengthInSeconds, encoded.StartTime))
+		}
+		if chapter.End < chapter.Start {
+			return nil, nil, fmt.Errorf("chapter %d ends at %v before its start %v", i+1, chapter.GetEndTimeInSeconds(), encoded.StartTime)
+		}
+		if encoded.Img != "" {
+			chapter.Image = &Picture{MIMEType: imageLinkMIMEType, Data: []byte(encoded.Img)}
+		}
+		if encoded.URL != "" {
+			chapter.Links = []Link{{URL: encoded.URL}}
+		}
+		hasHiddenChapters = hasHiddenChapters || (encoded.TOC != nil && !*encoded.TOC)
+		chapters = append(chapters, chapter)
+	}
+
+	tablesOfContents = make([]TableOfContents, 0)
+	if hasHiddenChapters {
+		elementIDs := getUniqueElementIDs(chapters)
+		tableOfContents := TableOfContents{ElementID: defaultTableOfContentsID, TopLevel: true, Ordered: true, Children: make([]string, 0)}
+		for i, encoded := range decoded.Chapters {
+			chapters[i].ElementID = elementIDs[i]
+			if encoded.TOC == nil || *encoded.TOC {
+				tableOfContents.Children = append(tableOfContents.Children, elementIDs[i])
+			}
+		}
+		tablesOfContents = append(tablesOfContents, tableOfContents)
+	}
+	return chapters, tablesOfContents, nil
+}
+
+// Returns for each chapter if it is listed in any table of contents.
+// Without tables of contents all chapters are listed.
+func getChaptersInTablesOfContents(chapters []Chapter, tablesOfContents []TableOfContents) []bool {
+	result := make([]bool, len(chapters))
+	if len(tablesOfContents) == 0 {
+		for i := range result {
+			result[i] = true
+		}
+		return result
+	}
+
+	children := make(map[string]bool)
+	for _, tableOfContents := range tablesOfContents {
+		for _, child := range tableOfContents.Children {
+			children[child] = true
+		}
+	}
+	for i, elementID := range getUniqueElementIDs(chapters) {
+		result[i] = children[elementID]
+	}
+	return result
+}
+
+func toMilliseconds(seconds float64) int {
+	return int(math.Round(seconds * 1000))
+}
+
+func roundToMilliseconds(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
@@ -0,0 +1,119 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodePodcastChapters(t *testing.T) {
+	content := `{
+  "version": "1.2.0",
+  "author": "Host",
+  "chapters": [
+    {"startTime": 0, "title": "Intro", "img": "https://example.com/intro.jpg"},
+    {"startTime": 12.5, "endTime": 20, "title": "Sponsor", "toc": false, "url": "https://example.com"},
+    {"startTime": 20, "title": "Main"}
+  ]
+}`
+	chapters, tablesOfContents, err := DecodePodcastChapters(strings.NewReader(content), 60)
+	if err != nil {
+		t.Fatalf("DecodePodcastChapters() error = %v", err)
+	}
+
+	expected := []struct {
+		title string
+		start float64
+		end   float64
+	}{
+		{title: "Intro", start: 0, end: 12.5},
+		{title: "Sponsor", start: 12.5, end: 20},
+		{title: "Main", start: 20, end: 60},
+	}
+	if len(chapters) != len(expected) {
+		t.Fatalf("DecodePodcastChapters() got %d chapters, want %d", len(chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("DecodePodcastChapters() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+	if chapters[0].Image == nil || chapters[0].Image.MIMEType != imageLinkMIMEType || string(chapters[0].Image.Data) != "https://example.com/intro.jpg" {
+		t.Errorf("DecodePodcastChapters() image = %+v", chapters[0].Image)
+	}
+	if len(chapters[1].Links) != 1 || chapters[1].Links[0].URL != "https://example.com" {
+		t.Errorf("DecodePodcastChapters() links = %+v", chapters[1].Links)
+	}
+
+	wantTables := []TableOfContents{{ElementID: "toc", TopLevel: true, Ordered: true, Children: []string{"chp0", "chp2"}}}
+	if !reflect.DeepEqual(tablesOfContents, wantTables) {
+		t.Errorf("DecodePodcastChapters() tables of contents = %+v, want %+v", tablesOfContents, wantTables)
+	}
+}
+
+func TestEncodePodcastChapters(t *testing.T) {
+	chapters := []Chapter{
+		{TimeBase: "1/1000", Start: 0, End: 12500, ElementID: "intro", Tags: Tags{Title: "Intro"},
+			Image: &Picture{MIMEType: imageLinkMIMEType, Data: []byte("https://example.com/intro.jpg")}},
+		{TimeBase: "1/1000", Start: 12500, End: 20000, ElementID: "ad", Tags: Tags{Title: "Sponsor"},
+			Links: []Link{{URL: "https://example.com"}}},
+		{TimeBase: "1/3", Start: 60, End: 180, ElementID: "main", Tags: Tags{Title: "Main"},
+			Image: &Picture{MIMEType: "image/png", Data: []byte{0x89}}},
+	}
+	tablesOfContents := []TableOfContents{{ElementID: "toc", TopLevel: true, Children: []string{"intro", "main"}}}
+
+	var buffer bytes.Buffer
+	if err := EncodePodcastChapters(&buffer, chapters, tablesOfContents); err != nil {
+		t.Fatalf("EncodePodcastChapters() error = %v", err)
+	}
+	for _, want := range []string{
+		`"version": "1.2.0"`,
+		`"img": "https://example.com/intro.jpg"`,
+		`"endTime": 12.5`,
+		`"url": "https://example.com",
+      "toc": false`,
+		`"startTime": 20,
+      "endTime": 60,
+      "title": "Main"
+    }`,
+	} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("EncodePodcastChapters() = %s, expected to contain %s", buffer.String(), want)
+		}
+	}
+
+	decoded, decodedTables, err := DecodePodcastChapters(&buffer, 0)
+	if err != nil {
+		t.Fatalf("DecodePodcastChapters() error = %v", err)
+	}
+	if len(decoded) != 3 || decoded[2].Image != nil || len(decodedTables) != 1 || len(decodedTables[0].Children) != 2 {
+		t.Errorf("DecodePodcastChapters() = %+v, %+v", decoded, decodedTables)
+	}
+}
+
+func TestDecodePodcastChaptersErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "invalid json",
+			content: `{"chapters": [`,
+		}, {
+			name:    "negative start",
+			content: `{"chapters": [{"startTime": -1}]}`,
+		}, {
+			name:    "end before start",
+			content: `{"chapters": [{"startTime": 10, "endTime": 5}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodePodcastChapters(strings.NewReader(tt.content), 60); err == nil {
+				t.Error("DecodePodcastChapters() expected error")
+			}
+		})
+	}
+}
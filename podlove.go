@@ -0,0 +1,133 @@
+package mp3joiner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	podloveNamespace = "http://podlove.org/simple-chapters"
+	podloveVersion   = "1.2"
+)
+
+type podloveChapters struct {
+	XMLName   xml.Name         `xml:"chapters"`
+	Namespace string           `xml:"xmlns,attr,omitempty"`
+	Version   string           `xml:"version,attr,omitempty"`
+	Chapters  []podloveChapter `xml:"chapter"`
+}
+
+type podloveChapter struct {
+	Start string `xml:"start,attr"`
+	Title string `xml:"title,attr"`
+	Href  string `xml:"href,attr,omitempty"`
+	Image string `xml:"image,attr,omitempty"`
+}
+
+// Writes the chapters as Podlove Simple Chapters XML. As the format
+// has no end times, chapters are expected to be contiguous. The first
+// link of a chapter is written as href and images linked by URL as
+// image.
+func EncodePodloveChapters(writer io.Writer, chapters []Chapter) error {
+	result := podloveChapters{Namespace: podloveNamespace, Version: podloveVersion, Chapters: make([]podloveChapter, 0, len(chapters))}
+	for _, chapter := range chapters {
+		encoded := podloveChapter{
+			Start: formatNormalPlayTime(chapter.GetStartTimeInSeconds()),
+			Title: chapter.Tags.Title,
+		}
+		if len(chapter.Links) > 0 {
+			encoded.Href = chapter.Links[0].URL
+		}
+		if chapter.Image != nil && chapter.Image.MIMEType == imageLinkMIMEType {
+			encoded.Image = string(chapter.Image.Data)
+		}
+		result.Chapters = append(result.Chapters, encoded)
+	}
+
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+// Reads Podlove Simple Chapters XML. Each chapter ends where the next
+// chapter starts, the last one at the given length in seconds.
+func DecodePodloveChapters(reader io.Reader, lengthInSeconds float64) ([]Chapter, error) {
+	var decoded podloveChapters
+	if err := xml.NewDecoder(reader).Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	result := make([]Chapter, 0, len(decoded.Chapters))
+	for _, encoded := range decoded.Chapters {
+		start, err := parseNormalPlayTime(encoded.Start)
+		if err != nil {
+			return nil, fmt.Errorf("chapter '%s': %w", encoded.Title, err)
+		}
+		chapter := Chapter{TimeBase: id3ChapterTimeBase, Start: toMilliseconds(start), Tags: Tags{Title: encoded.Title}}
+		if encoded.Href != "" {
+			chapter.Links = []Link{{URL: encoded.Href}}
+		}
+		if encoded.Image != "" {
+			chapter.Image = &Picture{MIMEType: imageLinkMIMEType, Data: []byte(encoded.Image)}
+		}
+		result = append(result, chapter)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start < result[j].Start
+	})
+	for i := range result {
+		if i+1 < len(result) {
+			result[i].End = result[i+1].Start
+		} else {
+			result[i].End = max(result[i].Start, toMilliseconds(lengthInSeconds))
+		}
+	}
+	return result, nil
+}
+
+// Parses a normal play time as used by Podlove Simple Chapters,
+// i.e. [[hh:]mm:]ss[.mmm], into seconds.
+func parseNormalPlayTime(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time '%s'", value)
+	}
+
+	result := 0.0
+	for i, part := range parts {
+		isLast := i == len(parts)-1
+		var number float64
+		var err error
+		if isLast {
+			number, err = strconv.ParseFloat(part, 64)
+		} else {
+			var integer int
+			integer, err = strconv.Atoi(part)
+			number = float64(integer)
+		}
+		if err != nil || number < 0 || (i > 0 && number >= 60) {
+			return 0, fmt.Errorf("invalid time '%s'", value)
+		}
+		result = result*60 + number
+	}
+	return result, nil
+}
+
+// Formats seconds as hh:mm:ss.mmm.
+func formatNormalPlayTime(seconds float64) string {
+	milliseconds := toMilliseconds(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, milliseconds%1000)
+}
@@ -0,0 +1,102 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodePodloveChapters(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<psc:chapters version="1.2" xmlns:psc="http://podlove.org/simple-chapters">
+  <psc:chapter start="0" title="Welcome" />
+  <psc:chapter start="3:07" title="Introducing Podlove" href="http://podlove.org/" />
+  <psc:chapter start="01:02:03.500" title="Podlove WordPress Plugin" image="http://podlove.org/logo.jpg" />
+</psc:chapters>`
+
+	chapters, err := DecodePodloveChapters(strings.NewReader(content), 4000)
+	if err != nil {
+		t.Fatalf("DecodePodloveChapters() error = %v", err)
+	}
+
+	expected := []struct {
+		title string
+		start float64
+		end   float64
+	}{
+		{title: "Welcome", start: 0, end: 187},
+		{title: "Introducing Podlove", start: 187, end: 3723.5},
+		{title: "Podlove WordPress Plugin", start: 3723.5, end: 4000},
+	}
+	if len(chapters) != len(expected) {
+		t.Fatalf("DecodePodloveChapters() got %d chapters, want %d", len(chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("DecodePodloveChapters() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+	if len(chapters[1].Links) != 1 || chapters[1].Links[0].URL != "http://podlove.org/" {
+		t.Errorf("DecodePodloveChapters() links = %+v", chapters[1].Links)
+	}
+	if chapters[2].Image == nil || string(chapters[2].Image.Data) != "http://podlove.org/logo.jpg" {
+		t.Errorf("DecodePodloveChapters() image = %+v", chapters[2].Image)
+	}
+}
+
+func TestEncodePodloveChapters(t *testing.T) {
+	chapters := []Chapter{
+		{TimeBase: "1/1000", Start: 0, End: 187000, Tags: Tags{Title: "Welcome & Hello"}},
+		{TimeBase: "1/1000", Start: 187000, End: 3723500, Tags: Tags{Title: "Plugin"}, Links: []Link{{URL: "http://podlove.org/"}}},
+	}
+
+	var buffer bytes.Buffer
+	if err := EncodePodloveChapters(&buffer, chapters); err != nil {
+		t.Fatalf("EncodePodloveChapters() error = %v", err)
+	}
+	for _, want := range []string{
+		`<chapters xmlns="http://podlove.org/simple-chapters" version="1.2">`,
+		`<chapter start="00:00:00.000" title="Welcome &amp; Hello"></chapter>`,
+		`<chapter start="00:03:07.000" title="Plugin" href="http://podlove.org/"></chapter>`,
+	} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("EncodePodloveChapters() = %s, expected to contain %s", buffer.String(), want)
+		}
+	}
+
+	decoded, err := DecodePodloveChapters(&buffer, 3723.5)
+	if err != nil {
+		t.Fatalf("DecodePodloveChapters() error = %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Tags.Title != "Welcome & Hello" || decoded[1].End != 3723500 {
+		t.Errorf("DecodePodloveChapters() = %+v", decoded)
+	}
+}
+
+func Test_parseNormalPlayTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "12", want: 12},
+		{value: "12.345", want: 12.345},
+		{value: "01:02", want: 62},
+		{value: "1:02:03.5", want: 3723.5},
+		{value: "00:60", wantErr: true},
+		{value: "1:2:3:4", wantErr: true},
+		{value: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseNormalPlayTime(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNormalPlayTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseNormalPlayTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
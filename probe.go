@@ -0,0 +1,189 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContainerMP3     = "mp3"
+	ContainerMP4     = "mp4"
+	ContainerFLAC    = "flac"
+	ContainerOgg     = "ogg"
+	ContainerWAV     = "wav"
+	ContainerAIFF    = "aiff"
+	ContainerUnknown = ""
+
+	// length of the file header needed to detect the container
+	containerHeaderLength = 12
+)
+
+// Codecs which do not lose information. Their bitrate is no
+// indication of the quality, so a target bitrate is derived instead.
+var losslessCodecs = []string{"flac", "alac", "wavpack", "ape", "tta", "mlp", "truehd", "pcm_"}
+
+// Properties of the audio stream used by MP3Builder.
+type AudioInfo struct {
+	// container detected from the file header, e.g. ContainerFLAC
+	Container string
+	// codec name as reported by ffprobe, e.g. "mp3", "aac" or "flac"
+	Codec string
+	// index of the stream within the file
+	StreamIndex int
+	SampleRate  int
+	Channels    int
+	// bitrate in bit/s, derived from the container or the sample
+	// format if the stream has none
+	Bitrate  int
+	Lossless bool
+	// bitrate in bit/s used when the stream is re-encoded to MP3
+	TargetBitrate int
+}
+
+// Probes the container and the audio stream of a file. If a file has
+// several audio streams, the default stream or else the first one is
+// picked. Streams of attached pictures, like cover art, are ignored.
+func ProbeAudio(audioFilepath string, opts ...Option) (AudioInfo, error) {
+	return ProbeAudioContext(context.Background(), audioFilepath, opts...)
+}
+
+// Same as ProbeAudio but stops the underlying process once the context is done.
+func ProbeAudioContext(ctx context.Context, audioFilepath string, opts ...Option) (result AudioInfo, err error) {
+	result.Container = getContainer(audioFilepath)
+
+	s, err := getStreamInfo(ctx, newOptions(opts), audioFilepath)
+	if err != nil {
+		return result, err
+	}
+	result.Codec = s.CodecName
+	result.StreamIndex = s.Index
+	result.SampleRate, _ = strconv.Atoi(s.SampleRate)
+	result.Channels = s.Channels
+	result.Lossless = s.isLossless()
+	if result.Bitrate, err = s.getBitrate(); err != nil && !result.Lossless {
+		return result, err
+	}
+	result.TargetBitrate, err = s.getTargetBitrate()
+	return result, err
+}
+
+// Detects the container of the file from its header.
+// Returns ContainerUnknown if the file can not be read.
+func getContainer(audioFilepath string) string {
+	file, err := os.Open(audioFilepath)
+	if err != nil {
+		return ContainerUnknown
+	}
+	defer closeFile(file)
+
+	header := make([]byte, containerHeaderLength)
+	if _, err = io.ReadFull(file, header); err != nil {
+		return ContainerUnknown
+	}
+	return detectContainer(header)
+}
+
+func detectContainer(header []byte) string {
+	switch {
+	case len(header) < containerHeaderLength:
+		return ContainerUnknown
+	case bytes.Equal(header[4:8], []byte("ftyp")):
+		return ContainerMP4
+	case bytes.Equal(header[:4], []byte("fLaC")):
+		return ContainerFLAC
+	case bytes.Equal(header[:4], []byte("OggS")):
+		return ContainerOgg
+	case bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return ContainerWAV
+	case bytes.Equal(header[:4], []byte("FORM")) && (bytes.Equal(header[8:12], []byte("AIFF")) || bytes.Equal(header[8:12], []byte("AIFC"))):
+		return ContainerAIFF
+	case bytes.Equal(header[:3], id3v2TagIdentifier):
+		return ContainerMP3
+	default:
+		if _, ok := parseFrameHeader(header); ok {
+			return ContainerMP3
+		}
+		return ContainerUnknown
+	}
+}
+
+// Returns the default audio stream or the first audio stream if
+// none is marked as default. Streams without a type are treated
+// as audio streams.
+func selectAudioStream(streams []stream) (result stream, ok bool) {
+	audioIndex := 0
+	for _, s := range streams {
+		if (s.CodecType != "" && s.CodecType != "audio") || s.Disposition.AttachedPic == 1 {
+			continue
+		}
+		s.audioIndex = audioIndex
+		audioIndex++
+		if !ok || (s.Disposition.Default == 1 && result.Disposition.Default != 1) {
+			result = s
+			ok = true
+		}
+	}
+	return result, ok
+}
+
+func (s stream) isLossless() bool {
+	for _, codec := range losslessCodecs {
+		if strings.HasPrefix(s.CodecName, codec) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the bitrate of the stream. Falls back to the bitrate of the
+// container and to the bitrate derived from the sample format.
+func (s stream) getBitrate() (int, error) {
+	for _, value := range []string{s.Bitrate, s.formatBitrate} {
+		if result, err := strconv.Atoi(value); err == nil && result > 0 {
+			return result, nil
+		}
+	}
+
+	bitsPerSample := s.BitsPerSample
+	if bitsPerSample == 0 {
+		bitsPerSample, _ = strconv.Atoi(s.BitsPerRawSample)
+	}
+	sampleRate, _ := strconv.Atoi(s.SampleRate)
+	if bitsPerSample > 0 && sampleRate > 0 && s.Channels > 0 {
+		return bitsPerSample * sampleRate * s.Channels, nil
+	}
+	return -1, fmt.Errorf("could not determine bitrate of stream %d", s.Index)
+}
+
+// Returns the bitrate used to re-encode the stream to MP3. Lossless
+// streams get the highest MP3 bitrate, mono streams half of it. Lossy
+// streams keep their bitrate, limited to the highest MP3 bitrate.
+func (s stream) getTargetBitrate() (int, error) {
+	maxBitrate := mpeg1Layer3Bitrates[len(mpeg1Layer3Bitrates)-1] * 1000
+	if s.isLossless() {
+		if s.Channels == 1 {
+			return maxBitrate / 2, nil
+		}
+		return maxBitrate, nil
+	}
+
+	bitrate, err := s.getBitrate()
+	if err != nil {
+		return -1, err
+	}
+	return min(bitrate, maxBitrate), nil
+}
+
+// Returns the stream specifier of the stream for ffmpeg, e.g. "a" for
+// the first audio stream and "a:1" for the second one.
+func (s stream) getAudioSpecifier() string {
+	if s.audioIndex == 0 {
+		return "a"
+	}
+	return "a:" + strconv.Itoa(s.audioIndex)
+}
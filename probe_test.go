@@ -0,0 +1,149 @@
+package mp3joiner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestProbeAudio(t *testing.T) {
+	audioFilepath := filepath.Join(t.TempDir(), "book.flac")
+	if err := os.WriteFile(audioFilepath, []byte("fLaC\x00\x00\x00\x22\x00\x00\x00\x00"), 0644); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+	executor := NewReplayExecutor(Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[
+		{"index":0,"codec_type":"video","codec_name":"mjpeg","disposition":{"default":1,"attached_pic":1}},
+		{"index":1,"codec_type":"audio","codec_name":"flac","sample_rate":"96000","channels":1,"bits_per_raw_sample":"24"}
+	]}`}})
+
+	got, err := ProbeAudio(audioFilepath, WithExecutor(executor))
+	if err != nil {
+		t.Fatalf("ProbeAudio() error = %v", err)
+	}
+	want := AudioInfo{
+		Container:     ContainerFLAC,
+		Codec:         "flac",
+		StreamIndex:   1,
+		SampleRate:    96000,
+		Channels:      1,
+		Bitrate:       2304000,
+		Lossless:      true,
+		TargetBitrate: 160000,
+	}
+	if got != want {
+		t.Errorf("ProbeAudio() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_detectContainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{name: "mp4", header: []byte("\x00\x00\x00\x20ftypM4A "), want: ContainerMP4},
+		{name: "flac", header: []byte("fLaC\x00\x00\x00\x22\x00\x00\x00\x00"), want: ContainerFLAC},
+		{name: "ogg", header: []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), want: ContainerOgg},
+		{name: "wav", header: []byte("RIFF\x24\x00\x00\x00WAVE"), want: ContainerWAV},
+		{name: "aiff", header: []byte("FORM\x24\x00\x00\x00AIFC"), want: ContainerAIFF},
+		{name: "id3", header: []byte("ID3\x04\x00\x00\x00\x00\x00\x00\x00\x00"), want: ContainerMP3},
+		{name: "mpeg frame", header: concat(testMPEG1FrameHeader, make([]byte, 8)), want: ContainerMP3},
+		{name: "text", header: []byte("hello world!"), want: ContainerUnknown},
+		{name: "too short", header: []byte("fLaC"), want: ContainerUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectContainer(tt.header); got != tt.want {
+				t.Errorf("detectContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectAudioStream(t *testing.T) {
+	cover := stream{Index: 0, CodecType: "video"}
+	cover.Disposition.AttachedPic = 1
+	commentary := stream{Index: 1, CodecType: "audio", CodecName: "aac"}
+	main := stream{Index: 2, CodecType: "audio", CodecName: "aac"}
+	main.Disposition.Default = 1
+	subtitle := stream{Index: 3, CodecType: "subtitle"}
+
+	tests := []struct {
+		name          string
+		streams       []stream
+		wantIndex     int
+		wantSpecifier string
+		wantOk        bool
+	}{
+		{name: "default stream", streams: []stream{cover, commentary, main, subtitle}, wantIndex: 2, wantSpecifier: "a:1", wantOk: true},
+		{name: "first audio stream", streams: []stream{cover, commentary, subtitle}, wantIndex: 1, wantSpecifier: "a", wantOk: true},
+		{name: "stream without type", streams: []stream{{Bitrate: "64000"}}, wantIndex: 0, wantSpecifier: "a", wantOk: true},
+		{name: "no audio stream", streams: []stream{cover, subtitle}, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := selectAudioStream(tt.streams)
+			if ok != tt.wantOk {
+				t.Fatalf("selectAudioStream() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && (got.Index != tt.wantIndex || got.getAudioSpecifier() != tt.wantSpecifier) {
+				t.Errorf("selectAudioStream() = %d (%s), want %d (%s)", got.Index, got.getAudioSpecifier(), tt.wantIndex, tt.wantSpecifier)
+			}
+		})
+	}
+}
+
+func Test_stream_getTargetBitrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  stream
+		want    int
+		wantErr bool
+	}{
+		{name: "lossless stereo", stream: stream{CodecName: "alac", Channels: 2}, want: 320000},
+		{name: "lossless mono", stream: stream{CodecName: "pcm_s24le", Channels: 1}, want: 160000},
+		{name: "lossy", stream: stream{CodecName: "aac", Bitrate: "96000"}, want: 96000},
+		{name: "lossy above mp3 maximum", stream: stream{CodecName: "opus", formatBitrate: "510000"}, want: 320000},
+		{name: "unknown bitrate", stream: stream{CodecName: "vorbis"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.stream.getTargetBitrate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("stream.getTargetBitrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("stream.getTargetBitrate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithNonMP3Input(t *testing.T) {
+	executions := appendExecutions("00:00:10.00", `[]`, `{"codec_type":"audio","codec_name":"mp3","bit_rate":"64000","sample_rate":"44100","channels":2}`, true)
+	executions = append(executions, appendExecutions("00:00:20.00", `[]`,
+		`{"index":0,"codec_type":"audio","codec_name":"aac","bit_rate":"64000"},{"index":1,"codec_type":"audio","codec_name":"flac","sample_rate":"96000","channels":6,"disposition":{"default":1}}`, false)...)
+	executions = append(executions, Execution{Name: "ffmpeg"})
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	if err := builder.Append("first.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Append("second.mka", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if err := builder.Build(filepath.Join(t.TempDir(), "out.mp3")); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+
+	calls := executor.Calls()
+	args := calls[len(calls)-1].Args
+	if !slices.Contains(args, "[0:a][1:a:1]concat=n=2:v=0:a=1[aout]") {
+		t.Errorf("MP3Builder.Build() expected the default audio stream to be used, args = %v", args)
+	}
+	if !containsSequence(args, []string{"-b:a", "320k"}) {
+		t.Errorf("MP3Builder.Build() expected bitrate of lossless input, args = %v", args)
+	}
+}
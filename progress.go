@@ -0,0 +1,95 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State of a running build.
+type Progress struct {
+	// seconds of audio written to the output so far
+	Elapsed float64
+	// expected length of the output in seconds
+	Total float64
+	// value between 0 and 100
+	Percent float64
+	// processing speed as multiple of real time, 0 if unknown
+	Speed float64
+	// estimated remaining time, 0 if unknown
+	ETA time.Duration
+	// true for the final report of a build
+	Done bool
+}
+
+// Parses the key=value blocks ffmpeg writes when run with
+// the "-progress" parameter and reports each completed block.
+// Each block is terminated by a "progress=continue" or
+// "progress=end" line.
+type progressWriter struct {
+	total    float64
+	callback func(Progress)
+	buffer   bytes.Buffer
+	current  Progress
+}
+
+func newProgressWriter(totalInSeconds float64, callback func(Progress)) *progressWriter {
+	return &progressWriter{
+		total:    totalInSeconds,
+		callback: callback,
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (n int, err error) {
+	w.buffer.Write(p)
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// keep incomplete line for the next write
+			w.buffer.Reset()
+			w.buffer.WriteString(line)
+			break
+		}
+		w.parseLine(strings.TrimSpace(line))
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) parseLine(line string) {
+	key, value, found := strings.Cut(line, "=")
+	if !found {
+		return
+	}
+
+	switch key {
+	case "out_time_us", "out_time_ms":
+		// despite its name ffmpeg reports out_time_ms in microseconds as well
+		if microseconds, err := strconv.ParseInt(value, 10, 64); err == nil && microseconds >= 0 {
+			w.current.Elapsed = float64(microseconds) / float64(time.Second/time.Microsecond)
+		}
+	case "speed":
+		if speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "x"), 64); err == nil {
+			w.current.Speed = speed
+		}
+	case "progress":
+		w.current.Done = value == "end"
+		w.report()
+	}
+}
+
+func (w *progressWriter) report() {
+	progress := w.current
+	progress.Total = w.total
+	if progress.Done {
+		progress.Elapsed = w.total
+	}
+	if w.total > 0 {
+		progress.Percent = min(progress.Elapsed/w.total*100, 100)
+	}
+	if progress.Speed > 0 && !progress.Done {
+		remaining := max(w.total-progress.Elapsed, 0) / progress.Speed
+		progress.ETA = time.Duration(remaining * float64(time.Second))
+	}
+	w.callback(progress)
+}
@@ -0,0 +1,65 @@
+package mp3joiner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_progressWriter_Write(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  float64
+		writes []string
+		want   []Progress
+	}{
+		{
+			name:  "positive test",
+			total: 20,
+			writes: []string{
+				"out_time_us=5000000\nspeed=2.5x\nprogress=continue\n",
+				"out_time_us=20000000\nspeed=2.5x\nprogress=end\n",
+			},
+			want: []Progress{
+				{Elapsed: 5, Total: 20, Percent: 25, Speed: 2.5, ETA: 6 * time.Second},
+				{Elapsed: 20, Total: 20, Percent: 100, Speed: 2.5, Done: true},
+			},
+		}, {
+			name:  "lines split across writes",
+			total: 10,
+			writes: []string{
+				"out_time_u",
+				"s=2500000\nspeed=N/A\nprog",
+				"ress=continue\n",
+			},
+			want: []Progress{
+				{Elapsed: 2.5, Total: 10, Percent: 25},
+			},
+		}, {
+			name:  "not available time",
+			total: 10,
+			writes: []string{
+				"out_time_us=N/A\nspeed=N/A\nprogress=continue\n",
+			},
+			want: []Progress{
+				{Total: 10},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]Progress, 0)
+			writer := newProgressWriter(tt.total, func(p Progress) {
+				got = append(got, p)
+			})
+			for _, write := range tt.writes {
+				if _, err := writer.Write([]byte(write)); err != nil {
+					t.Errorf("progressWriter.Write() error = %v", err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("progressWriter.Write() reported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Segment of the output as returned by MP3Builder.GetSegments.
+type SegmentInfo struct {
+	// path of the file, empty for generated audio
+	File string
+	// lavfi source of generated audio
+	Source string
+	// range of the file in seconds
+	Start float64
+	End   float64
+	// start of the segment in the output in seconds
+	OutputStart float64
+	// length of the segment in the output in seconds,
+	// including the crossfade into the next segment
+	Duration float64
+	// chapters of the segment, relative to the start of the output
+	Chapters []Chapter
+	// crossfade from the previous segment
+	Crossfade Fade
+	Filters   []AudioFilter
+}
+
+// Returns all segments in the order of the output. Changes of the
+// returned segments do not change the builder.
+func (b *MP3Builder) GetSegments() []SegmentInfo {
+	result := make([]SegmentInfo, 0, len(b.streams))
+	offset := 0.0
+	for i, s := range b.streams {
+		result = append(result, SegmentInfo{
+			File:        s.File,
+			Source:      s.Source,
+			Start:       s.Start,
+			End:         s.Start + s.Duration,
+			OutputStart: offset,
+			Duration:    s.getOutputDuration(),
+			Chapters:    b.getSegmentChapters(i, offset),
+			Crossfade:   b.getCrossfade(i),
+			Filters:     append([]AudioFilter(nil), s.Filters...),
+		})
+		offset += b.getSegmentOutputLength(i)
+	}
+	return result
+}
+
+// Inserts the range of the file before the segment at the index.
+// An index of the number of segments appends the range.
+// If endInSeconds is set to "-1" the stream will be read until the end of the file.
+func (b *MP3Builder) InsertSegment(index int, audioFilepath string, startInSeconds float64, endInSeconds float64) error {
+	return b.InsertSegmentContext(context.Background(), index, audioFilepath, startInSeconds, endInSeconds)
+}
+
+func (b *MP3Builder) InsertSegmentContext(ctx context.Context, index int, audioFilepath string, startInSeconds float64, endInSeconds float64) error {
+	if index < 0 || index > len(b.streams) {
+		return fmt.Errorf("can not insert at %d into %d segments", index, len(b.streams))
+	}
+	s, err := b.newFileSegment(ctx, audioFilepath, startInSeconds, endInSeconds)
+	if err != nil {
+		return err
+	}
+	b.streams = slices.Insert(b.streams, index, s)
+	return nil
+}
+
+// Removes the segment at the index.
+func (b *MP3Builder) RemoveSegment(index int) error {
+	if err := b.checkSegmentIndex(index); err != nil {
+		return err
+	}
+	b.streams = slices.Delete(b.streams, index, index+1)
+	return nil
+}
+
+// Moves the segment at the index from to the index to. The crossfade
+// and filters stay with the segment.
+func (b *MP3Builder) MoveSegment(from int, to int) error {
+	if err := b.checkSegmentIndex(from); err != nil {
+		return err
+	}
+	if err := b.checkSegmentIndex(to); err != nil {
+		return err
+	}
+	s := b.streams[from]
+	b.streams = slices.Insert(slices.Delete(b.streams, from, from+1), to, s)
+	return nil
+}
+
+// Replaces the segment at the index by the range of the file. The
+// crossfade from the previous segment is kept, filters are removed.
+// If endInSeconds is set to "-1" the stream will be read until the end of the file.
+func (b *MP3Builder) ReplaceSegment(index int, audioFilepath string, startInSeconds float64, endInSeconds float64) error {
+	return b.ReplaceSegmentContext(context.Background(), index, audioFilepath, startInSeconds, endInSeconds)
+}
+
+func (b *MP3Builder) ReplaceSegmentContext(ctx context.Context, index int, audioFilepath string, startInSeconds float64, endInSeconds float64) error {
+	if err := b.checkSegmentIndex(index); err != nil {
+		return err
+	}
+	s, err := b.newFileSegment(ctx, audioFilepath, startInSeconds, endInSeconds)
+	if err != nil {
+		return err
+	}
+	s.Crossfade = b.streams[index].Crossfade
+	b.streams[index] = s
+	return nil
+}
+
+// Changes the range of the file of the segment at the index. The
+// chapters of the new range are read from the file again, the crossfade
+// and filters are kept.
+// If endInSeconds is set to "-1" the stream will be read until the end of the file.
+func (b *MP3Builder) UpdateSegmentRange(index int, startInSeconds float64, endInSeconds float64) error {
+	return b.UpdateSegmentRangeContext(context.Background(), index, startInSeconds, endInSeconds)
+}
+
+func (b *MP3Builder) UpdateSegmentRangeContext(ctx context.Context, index int, startInSeconds float64, endInSeconds float64) error {
+	if err := b.checkSegmentIndex(index); err != nil {
+		return err
+	}
+	previous := b.streams[index]
+	if previous.Source != "" {
+		return fmt.Errorf("segment %d is generated audio", index)
+	}
+	s, err := b.newFileSegment(ctx, previous.File, startInSeconds, endInSeconds)
+	if err != nil {
+		return err
+	}
+	s.Crossfade = previous.Crossfade
+	s.Filters = previous.Filters
+	b.streams[index] = s
+	return nil
+}
+
+func (b *MP3Builder) checkSegmentIndex(index int) error {
+	if index < 0 || index >= len(b.streams) {
+		return fmt.Errorf("no segment %d of %d segments", index, len(b.streams))
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package mp3joiner
+
+import (
+	"slices"
+	"testing"
+)
+
+func newTestSegment(file string, duration float64) segment {
+	return segment{
+		File:     file,
+		Duration: duration,
+		Chapters: []Chapter{{TimeBase: DEFAULT_TIME_BASE, Start: 0, End: toDefaultTimeBase(duration), Tags: Tags{Title: file}}},
+	}
+}
+
+func getSegmentFiles(b *MP3Builder) (result []string) {
+	for _, s := range b.GetSegments() {
+		result = append(result, s.File)
+	}
+	return result
+}
+
+func TestMP3Builder_GetSegments(t *testing.T) {
+	builder := NewMP3Builder(WithCrossfade(Fade{Duration: 2}))
+	builder.streams = []segment{newTestSegment("a.mp3", 10), newTestSegment("b.mp3", 20)}
+	builder.streams[1].Start = 5
+	if err := builder.SetFilters(1, TempoFilter{Factor: 2}); err != nil {
+		t.Fatalf("MP3Builder.SetFilters() error = %v", err)
+	}
+
+	segments := builder.GetSegments()
+	if len(segments) != 2 {
+		t.Fatalf("MP3Builder.GetSegments() got %d segments, want 2", len(segments))
+	}
+	second := segments[1]
+	if second.Start != 5 || second.End != 25 || second.OutputStart != 8 || second.Duration != 10 || second.Crossfade.Duration != 2 {
+		t.Errorf("MP3Builder.GetSegments() second segment = %+v", second)
+	}
+	if len(second.Chapters) != 1 || second.Chapters[0].GetStartTimeInSeconds() != 8 || second.Chapters[0].GetEndTimeInSeconds() != 18 {
+		t.Errorf("MP3Builder.GetSegments() second segment chapters = %+v", second.Chapters)
+	}
+	if segments[0].Chapters[0].GetEndTimeInSeconds() != 8 {
+		t.Errorf("MP3Builder.GetSegments() first chapter should end at the crossfade, got %+v", segments[0].Chapters)
+	}
+
+	segments[1].Filters[0] = VolumeFilter{Gain: 3}
+	if _, ok := builder.streams[1].Filters[0].(TempoFilter); !ok {
+		t.Errorf("MP3Builder.GetSegments() returned the filters of the builder")
+	}
+}
+
+func TestMP3Builder_RemoveAndMoveSegment(t *testing.T) {
+	builder := NewMP3Builder()
+	builder.streams = []segment{newTestSegment("a.mp3", 10), newTestSegment("b.mp3", 20), newTestSegment("c.mp3", 30), newTestSegment("d.mp3", 40)}
+
+	if err := builder.MoveSegment(0, 2); err != nil {
+		t.Fatalf("MP3Builder.MoveSegment() error = %v", err)
+	}
+	if got := getSegmentFiles(builder); !slices.Equal(got, []string{"b.mp3", "c.mp3", "a.mp3", "d.mp3"}) {
+		t.Errorf("MP3Builder.MoveSegment() segments = %v", got)
+	}
+	if err := builder.MoveSegment(3, 0); err != nil {
+		t.Fatalf("MP3Builder.MoveSegment() error = %v", err)
+	}
+	if got := getSegmentFiles(builder); !slices.Equal(got, []string{"d.mp3", "b.mp3", "c.mp3", "a.mp3"}) {
+		t.Errorf("MP3Builder.MoveSegment() segments = %v", got)
+	}
+	if err := builder.RemoveSegment(1); err != nil {
+		t.Fatalf("MP3Builder.RemoveSegment() error = %v", err)
+	}
+	if got := getSegmentFiles(builder); !slices.Equal(got, []string{"d.mp3", "c.mp3", "a.mp3"}) {
+		t.Errorf("MP3Builder.RemoveSegment() segments = %v", got)
+	}
+
+	chapters := builder.getChapters()
+	if len(chapters) != 3 || chapters[2].Tags.Title != "a.mp3" || chapters[2].GetStartTimeInSeconds() != 70 {
+		t.Errorf("MP3Builder.getChapters() = %+v", chapters)
+	}
+
+	for _, err := range []error{builder.RemoveSegment(3), builder.MoveSegment(-1, 0), builder.MoveSegment(0, 3)} {
+		if err == nil {
+			t.Error("expected error for segment index out of range")
+		}
+	}
+}
+
+func TestMP3Builder_InsertAndReplaceSegment(t *testing.T) {
+	chapters := `[{"time_base":"1/1000","start":0,"end":30000,"tags":{"title":"Intro"}},{"time_base":"1/1000","start":30000,"end":60000,"tags":{"title":"Main"}}]`
+	executions := appendExecutions("00:01:00.00", chapters, `{"bit_rate":"64000"}`, true)
+	executions = append(executions, appendExecutions("00:01:00.00", chapters, `{"bit_rate":"64000"}`, false)...)
+	executions = append(executions, appendExecutions("00:01:00.00", chapters, `{"bit_rate":"64000"}`, false)...)
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	builder.streams = []segment{newTestSegment("a.mp3", 10), newTestSegment("b.mp3", 20)}
+	crossfade := Fade{Duration: 1}
+	builder.streams[1].Crossfade = &crossfade
+	builder.streams[1].Filters = []AudioFilter{VolumeFilter{Gain: 3}}
+
+	if err := builder.InsertSegment(1, "episode.mp3", 0, 30); err != nil {
+		t.Fatalf("MP3Builder.InsertSegment() error = %v", err)
+	}
+	if got := getSegmentFiles(builder); !slices.Equal(got, []string{"a.mp3", "episode.mp3", "b.mp3"}) {
+		t.Errorf("MP3Builder.InsertSegment() segments = %v", got)
+	}
+
+	if err := builder.ReplaceSegment(2, "episode.mp3", 30, -1); err != nil {
+		t.Fatalf("MP3Builder.ReplaceSegment() error = %v", err)
+	}
+	replaced := builder.streams[2]
+	if replaced.File != "episode.mp3" || replaced.Start != 30 || replaced.Crossfade != &crossfade || len(replaced.Filters) != 0 {
+		t.Errorf("MP3Builder.ReplaceSegment() segment = %+v", replaced)
+	}
+
+	builder.streams[1].Filters = []AudioFilter{VolumeFilter{Gain: 6}}
+	if err := builder.UpdateSegmentRange(1, 20, 45); err != nil {
+		t.Fatalf("MP3Builder.UpdateSegmentRange() error = %v", err)
+	}
+	updated := builder.GetSegments()[1]
+	if updated.Start != 20 || updated.End != 45 || len(updated.Filters) != 1 {
+		t.Errorf("MP3Builder.UpdateSegmentRange() segment = %+v", updated)
+	}
+	if len(updated.Chapters) != 2 || updated.Chapters[0].Tags.Title != "Intro" || updated.Chapters[1].GetEndTimeInSeconds() != 34 {
+		t.Errorf("MP3Builder.UpdateSegmentRange() chapters = %+v", updated.Chapters)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("expected %d more executions", executor.Remaining())
+	}
+
+	if err := builder.InsertSegment(4, "episode.mp3", 0, -1); err == nil {
+		t.Error("MP3Builder.InsertSegment() expected error for index out of range")
+	}
+	if err := builder.AppendSilence(1); err != nil {
+		t.Fatalf("MP3Builder.AppendSilence() error = %v", err)
+	}
+	if err := builder.UpdateSegmentRange(3, 0, 1); err == nil {
+		t.Error("MP3Builder.UpdateSegmentRange() expected error for generated audio")
+	}
+}
+
+func TestMP3Builder_RemoveSegmentBitrate(t *testing.T) {
+	builder := NewMP3Builder()
+	builder.streams = []segment{newTestSegment("a.mp3", 10), newTestSegment("b.mp3", 20), newTestSegment("c.mp3", 30)}
+	builder.streams[0].Stream.Bitrate = "64000"
+	builder.streams[1].Stream.Bitrate = "320000"
+	builder.streams[2].Stream.Bitrate = "128000"
+
+	if bitrate := builder.getEncodeOptions().Bitrate; bitrate != 320000 {
+		t.Errorf("MP3Builder.getEncodeOptions() bitrate = %v, want 320000", bitrate)
+	}
+	if err := builder.RemoveSegment(1); err != nil {
+		t.Fatalf("MP3Builder.RemoveSegment() error = %v", err)
+	}
+	if bitrate := builder.getEncodeOptions().Bitrate; bitrate != 128000 {
+		t.Errorf("MP3Builder.getEncodeOptions() bitrate after removal = %v, want 128000", bitrate)
+	}
+}
@@ -0,0 +1,191 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSilenceNoiseFloor = -50
+	defaultMinSilence        = 2
+)
+
+var (
+	DEFAULT_CHAPTER_TITLE_TEMPLATE = "Chapter {index}"
+	SILENCE_START_REGEX            = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	SILENCE_END_REGEX              = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+)
+
+// Defines how chapters are detected from the silence between them.
+type SilenceDetection struct {
+	// level in dB below which audio counts as silence, -50 if 0
+	NoiseFloor float64
+	// minimum length of a silence between chapters in seconds, 2 if 0
+	MinSilence float64
+	// minimum length of a chapter in seconds, shorter chapters are
+	// merged into the next one and a short last chapter into the
+	// previous one
+	MinChapter float64
+	// Template for the chapter titles. Supports the placeholders
+	// {index} and {name} (file name without extension). Numbers can
+	// be zero padded, e.g. {index:02}. Defaults to "Chapter {index}".
+	TitleTemplate string
+}
+
+// Time range of silence in seconds.
+type silence struct {
+	start float64
+	end   float64
+}
+
+func (d SilenceDetection) getNoiseFloor() float64 {
+	if d.NoiseFloor == 0 {
+		return defaultSilenceNoiseFloor
+	}
+	return d.NoiseFloor
+}
+
+func (d SilenceDetection) getMinSilence() float64 {
+	if d.MinSilence == 0 {
+		return defaultMinSilence
+	}
+	return d.MinSilence
+}
+
+func (d SilenceDetection) getTitleTemplate() string {
+	if d.TitleTemplate == "" {
+		return DEFAULT_CHAPTER_TITLE_TEMPLATE
+	}
+	return d.TitleTemplate
+}
+
+func (d SilenceDetection) validate() error {
+	if d.NoiseFloor > 0 {
+		return fmt.Errorf("noise floor has to be negative but is %v dB", d.NoiseFloor)
+	}
+	if d.MinSilence < 0 || d.MinChapter < 0 {
+		return fmt.Errorf("invalid negative minimum length")
+	}
+	return nil
+}
+
+// Detects chapters of the file by the silence between them. A chapter
+// boundary is placed in the middle of each silence which is at least
+// MinSilence long. Silence at the start and the end of the file does
+// not create a chapter.
+func DetectSilenceChapters(audioFilepath string, detection SilenceDetection, opts ...Option) ([]Chapter, error) {
+	return DetectSilenceChaptersContext(context.Background(), audioFilepath, detection, opts...)
+}
+
+func DetectSilenceChaptersContext(ctx context.Context, audioFilepath string, detection SilenceDetection, opts ...Option) ([]Chapter, error) {
+	o := newOptions(opts)
+	length, err := getLengthInSeconds(ctx, o, audioFilepath)
+	if err != nil {
+		return nil, err
+	}
+	audioStream, err := getStreamInfo(ctx, o, audioFilepath)
+	if err != nil {
+		return nil, err
+	}
+	return detectSilenceChapters(ctx, o, audioFilepath, audioStream, detection, length)
+}
+
+func detectSilenceChapters(ctx context.Context, o *options, audioFilepath string, audioStream stream, detection SilenceDetection, length float64) ([]Chapter, error) {
+	if err := detection.validate(); err != nil {
+		return nil, err
+	}
+
+	silences, err := runSilenceDetect(ctx, o, []string{"-i", audioFilepath}, audioStream.getAudioSpecifier(), detection.getNoiseFloor(), detection.getMinSilence(), length)
+	if err != nil {
+		return nil, err
+	}
+	return getSilenceChapters(silences, length, detection, audioFilepath), nil
+}
+
+// Runs silencedetect on the audio stream of the input and returns all
+// silences of at least minSilence seconds.
+func runSilenceDetect(ctx context.Context, o *options, inputArgs []string, audioSpecifier string, noiseFloor float64, minSilence float64, length float64) ([]silence, error) {
+	// Equivalent to:
+	// ffmpeg -i input.mp3 -map 0:a -af silencedetect=n=-50dB:d=2 -f null -
+	filter := fmt.Sprintf("silencedetect=n=%sdB:d=%s",
+		strconv.FormatFloat(noiseFloor, 'f', -1, 64),
+		strconv.FormatFloat(minSilence, 'f', -1, 64))
+	args := append([]string{"-hide_banner", "-nostats"}, inputArgs...)
+	args = append(args,
+		"-map", "0:"+audioSpecifier,
+		"-af", filter,
+		"-f", "null", "-",
+	)
+	// silencedetect logs to stderr
+	output, err := runCmd(ctx, o.executor, "ffmpeg", args...)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg silence detection failed: %w - output: %s", err, output.Stderr)
+	}
+	return parseSilences(output.Stderr, length)
+}
+
+// Parses the silences logged by silencedetect. A silence which
+// lasts until the end of the file has no end in the log.
+func parseSilences(stderr string, length float64) ([]silence, error) {
+	result := make([]silence, 0)
+	for _, line := range strings.Split(stderr, "\n") {
+		if matches := SILENCE_START_REGEX.FindStringSubmatch(line); matches != nil {
+			start, err := strconv.ParseFloat(matches[1], 64)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, silence{start: max(start, 0), end: length})
+		}
+		if matches := SILENCE_END_REGEX.FindStringSubmatch(line); matches != nil && len(result) > 0 {
+			end, err := strconv.ParseFloat(matches[1], 64)
+			if err != nil {
+				return nil, err
+			}
+			result[len(result)-1].end = min(end, length)
+		}
+	}
+	return result, nil
+}
+
+// Returns chapters split in the middle of each silence.
+func getSilenceChapters(silences []silence, length float64, detection SilenceDetection, audioFilepath string) []Chapter {
+	boundaries := []float64{0}
+	for _, s := range silences {
+		// leading and trailing silence stays part of the first and last chapter
+		if s.start <= 0 || s.end >= length {
+			continue
+		}
+		boundary := (s.start + s.end) / 2
+		if boundary-boundaries[len(boundaries)-1] < detection.MinChapter {
+			continue
+		}
+		boundaries = append(boundaries, boundary)
+	}
+	// a too short last chapter is merged into the previous one
+	if len(boundaries) > 1 && length-boundaries[len(boundaries)-1] < detection.MinChapter {
+		boundaries = boundaries[:len(boundaries)-1]
+	}
+	boundaries = append(boundaries, length)
+
+	result := make([]Chapter, 0, len(boundaries)-1)
+	for i := 0; i < len(boundaries)-1; i++ {
+		result = append(result, Chapter{
+			TimeBase: DEFAULT_TIME_BASE,
+			Start:    toDefaultTimeBase(boundaries[i]),
+			End:      toDefaultTimeBase(boundaries[i+1]),
+			Tags:     Tags{Title: getChapterTitle(detection.getTitleTemplate(), audioFilepath, i+1)},
+		})
+	}
+	return result
+}
+
+// Replaces the placeholders of the title template.
+func getChapterTitle(template string, audioFilepath string, index int) string {
+	return expandTemplate(template, map[string]any{
+		"index": index,
+		"name":  getBaseName(audioFilepath),
+	})
+}
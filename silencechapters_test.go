@@ -0,0 +1,122 @@
+package mp3joiner
+
+import (
+	"testing"
+)
+
+const silenceDetectStderr = `[silencedetect @ 0x5633c8c0] silence_start: -0.00367
+[silencedetect @ 0x5633c8c0] silence_end: 1.2 | silence_duration: 1.20367
+[silencedetect @ 0x5633c8c0] silence_start: 99
+[silencedetect @ 0x5633c8c0] silence_end: 101 | silence_duration: 2
+[silencedetect @ 0x5633c8c0] silence_start: 110
+[silencedetect @ 0x5633c8c0] silence_end: 112 | silence_duration: 2
+[silencedetect @ 0x5633c8c0] silence_start: 249
+[silencedetect @ 0x5633c8c0] silence_end: 251 | silence_duration: 2
+[silencedetect @ 0x5633c8c0] silence_start: 298
+`
+
+func Test_parseSilences(t *testing.T) {
+	got, err := parseSilences(silenceDetectStderr, 300)
+	if err != nil {
+		t.Fatalf("parseSilences() error = %v", err)
+	}
+	want := []silence{{0, 1.2}, {99, 101}, {110, 112}, {249, 251}, {298, 300}}
+	if len(got) != len(want) {
+		t.Fatalf("parseSilences() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseSilences() silence %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func Test_getSilenceChapters(t *testing.T) {
+	silences := []silence{{0, 1.2}, {99, 101}, {110, 112}, {249, 251}, {298, 300}}
+	tests := []struct {
+		name      string
+		detection SilenceDetection
+		want      []float64
+		titles    []string
+	}{
+		{
+			name:   "all silences",
+			want:   []float64{0, 100, 111, 250, 300},
+			titles: []string{"Chapter 1", "Chapter 2", "Chapter 3", "Chapter 4"},
+		}, {
+			name:      "minimum chapter length",
+			detection: SilenceDetection{MinChapter: 45, TitleTemplate: "{name} {index:02}"},
+			want:      []float64{0, 100, 250, 300},
+			titles:    []string{"interview 01", "interview 02", "interview 03"},
+		}, {
+			name:      "short last chapter",
+			detection: SilenceDetection{MinChapter: 60},
+			want:      []float64{0, 100, 300},
+			titles:    []string{"Chapter 1", "Chapter 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getSilenceChapters(silences, 300, tt.detection, "/path/to/interview.mp3")
+			if len(got) != len(tt.titles) {
+				t.Fatalf("getSilenceChapters() = %v, want %d chapters", got, len(tt.titles))
+			}
+			for i, chapter := range got {
+				if chapter.GetStartTimeInSeconds() != tt.want[i] || chapter.GetEndTimeInSeconds() != tt.want[i+1] || chapter.Tags.Title != tt.titles[i] {
+					t.Errorf("getSilenceChapters() chapter %d = %+v, want %v - %v %s", i, chapter, tt.want[i], tt.want[i+1], tt.titles[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDetectSilenceChapters(t *testing.T) {
+	executor := NewReplayExecutor(
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: "size=N/A time=00:05:00.00 bitrate=N/A speed=1x"}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"codec_type":"audio"}]}`}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: silenceDetectStderr}},
+	)
+	chapters, err := DetectSilenceChapters("recording.mp3", SilenceDetection{NoiseFloor: -40, MinSilence: 1.5}, WithExecutor(executor))
+	if err != nil {
+		t.Fatalf("DetectSilenceChapters() error = %v", err)
+	}
+	if len(chapters) != 4 {
+		t.Errorf("DetectSilenceChapters() got %d chapters, want 4", len(chapters))
+	}
+	if args := executor.Calls()[2].Args; !containsSequence(args, []string{"-map", "0:a", "-af", "silencedetect=n=-40dB:d=1.5"}) {
+		t.Errorf("DetectSilenceChapters() args = %v", args)
+	}
+
+	if _, err := DetectSilenceChapters("recording.mp3", SilenceDetection{NoiseFloor: 3}, WithExecutor(NewReplayExecutor(executor.Calls()[:2]...))); err == nil {
+		t.Error("DetectSilenceChapters() expected error for positive noise floor")
+	}
+}
+
+func TestMP3Builder_AppendWithSilenceChapters(t *testing.T) {
+	executor := NewReplayExecutor(
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: "size=N/A time=00:05:00.00 bitrate=N/A speed=1x"}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":[]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"format":{"tags":{"title":"demo"}}}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"streams":[{"codec_type":"audio","bit_rate":"64000"},` +
+			`{"codec_type":"audio","bit_rate":"64000","disposition":{"default":1}}]}`}},
+		Execution{Name: "ffmpeg", Result: ExecResult{Stderr: silenceDetectStderr}},
+	)
+	builder := NewMP3Builder(WithExecutor(executor), WithSilenceChapters(SilenceDetection{TitleTemplate: "Part {index}"}))
+	if err := builder.Append("recording.mp3", 105, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+
+	chapters := builder.getChapters()
+	if len(chapters) != 3 {
+		t.Fatalf("MP3Builder.getChapters() got %d chapters, want 3", len(chapters))
+	}
+	if chapters[0].Tags.Title != "Part 2" || chapters[0].GetStartTimeInSeconds() != 0 || chapters[0].GetEndTimeInSeconds() != 6 {
+		t.Errorf("MP3Builder.getChapters() first chapter = %+v", chapters[0])
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("MP3Builder.Append() expected silence detection to run")
+	}
+	if args := executor.Calls()[4].Args; !containsSequence(args, []string{"-map", "0:a:1"}) {
+		t.Errorf("MP3Builder.Append() silence detection args = %v", args)
+	}
+}
@@ -0,0 +1,231 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	DEFAULT_SPLIT_NAME_TEMPLATE = "{index:02} - {title}.mp3"
+	SPLIT_TEMPLATE_REGEX        = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+	ILLEGAL_FILENAME_CHARACTERS = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1F]`)
+)
+
+// Time range of a file which is written into a separate file by Split.
+type SplitRange struct {
+	Start float64
+	// set to "-1" to read until the end of the file
+	End   float64
+	Title string
+}
+
+// Defines how Split cuts a file.
+type SplitOptions struct {
+	// folder for the created files, defaults to the folder of the input file
+	OutputDir string
+	// Template for the file names of the created files. Supports the
+	// placeholders {index}, {title} and {name} (input file name without
+	// extension). Numbers can be zero padded, e.g. {index:02}.
+	// Defaults to "{index:02} - {title}.mp3".
+	NameTemplate string
+	// ranges to cut, if not set the file is split by its chapters
+	Ranges []SplitRange
+	// keeps the chapters within each range in the created files
+	KeepChapters bool
+}
+
+// Splits the file into one file per chapter or per range and returns
+// the paths of the created files. Each file gets the global tags of
+// the input file with the title set to the chapter title and the
+// track number set to its position. The audio is copied without
+// re-encoding, so only MP3 files can be split. Existing files are
+// not overwritten.
+func Split(mp3Filepath string, splitOptions SplitOptions, opts ...Option) ([]string, error) {
+	return SplitContext(context.Background(), mp3Filepath, splitOptions, opts...)
+}
+
+// Same as Split but stops ffmpeg once the context is done.
+func SplitContext(ctx context.Context, mp3Filepath string, splitOptions SplitOptions, opts ...Option) (result []string, err error) {
+	o := newOptions(opts)
+	result = make([]string, 0)
+
+	// files which can not be read are reported by ffprobe
+	if container := getContainer(mp3Filepath); container != ContainerMP3 && container != ContainerUnknown {
+		return result, fmt.Errorf("can not split %s file '%s', only MP3 files can be split without re-encoding", container, mp3Filepath)
+	}
+
+	chapters, err := readChapters(ctx, o, mp3Filepath)
+	if err != nil {
+		return result, err
+	}
+	ranges := slices.Clone(splitOptions.Ranges)
+	if len(ranges) == 0 {
+		ranges = getSplitRangesFromChapters(chapters)
+	}
+	if len(ranges) == 0 {
+		return result, fmt.Errorf("no chapters or ranges to split '%s'", mp3Filepath)
+	}
+	for _, r := range ranges {
+		if r.End != -1 && r.Start >= r.End {
+			return result, fmt.Errorf("start %v set after end %v", r.Start, r.End)
+		}
+	}
+
+	outputDir := splitOptions.OutputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(mp3Filepath)
+	}
+	nameTemplate := splitOptions.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = DEFAULT_SPLIT_NAME_TEMPLATE
+	}
+
+	outputPaths := make([]string, len(ranges))
+	for i := range ranges {
+		if ranges[i].Title == "" {
+			ranges[i].Title = fmt.Sprintf("Part %d", i+1)
+		}
+		outputPaths[i] = filepath.Join(outputDir, getSplitFileName(nameTemplate, mp3Filepath, i+1, ranges[i].Title))
+		if previous := slices.Index(outputPaths[:i], outputPaths[i]); previous >= 0 {
+			return result, fmt.Errorf("ranges %d and %d are both written to '%s', add {index} to the name template", previous+1, i+1, outputPaths[i])
+		}
+	}
+
+	metadata, err := getFFmpegMetadataTag(ctx, o, mp3Filepath)
+	if err != nil {
+		return result, err
+	}
+
+	for i, r := range ranges {
+		outputPath := outputPaths[i]
+
+		var rangeChapters []Chapter
+		if splitOptions.KeepChapters {
+			end := r.End
+			if end == -1 {
+				end = maxChapterEnd(chapters)
+			}
+			rangeChapters = rebaseChapters(getChapterInTimeFrame(chapters, r.Start, end), -r.Start)
+		}
+
+		rangeMetadata := make(map[string]string, len(metadata)+2)
+		for key, value := range metadata {
+			rangeMetadata[key] = value
+		}
+		rangeMetadata["title"] = r.Title
+		rangeMetadata["track"] = fmt.Sprintf("%d/%d", i+1, len(ranges))
+
+		if err = cutRange(ctx, o, mp3Filepath, r, rangeMetadata, rangeChapters, outputPath); err != nil {
+			return result, err
+		}
+		result = append(result, outputPath)
+	}
+
+	return result, nil
+}
+
+func getSplitRangesFromChapters(chapters []Chapter) []SplitRange {
+	result := make([]SplitRange, 0, len(chapters))
+	for i, chapter := range chapters {
+		title := chapter.Tags.Title
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		result = append(result, SplitRange{
+			Start: chapter.GetStartTimeInSeconds(),
+			End:   chapter.GetEndTimeInSeconds(),
+			Title: title,
+		})
+	}
+	return result
+}
+
+// Writes a range of the file with the given metadata and chapters.
+// A partially written file is removed in case of an error.
+func cutRange(ctx context.Context, o *options, mp3Filepath string, r SplitRange, metadata map[string]string, chapters []Chapter, outputPath string) (err error) {
+	tempMetadataFile, err := createTempMetadataFile(metadata, chapters)
+	if err != nil {
+		return err
+	}
+	defer deleteFile(tempMetadataFile)
+
+	// ffmpeg -n -ss START -t DURATION -i INPUT.mp3 -i METADATA -map 0:a -map_metadata 1 -map_chapters 1 -c:a copy OUTPUT.mp3
+	// -n fails instead of asking whether to overwrite an existing file
+	args := []string{"-n", "-ss", formatSeconds(r.Start)}
+	if r.End != -1 {
+		args = append(args, "-t", formatSeconds(r.End-r.Start))
+	}
+	args = append(args,
+		"-i", mp3Filepath,
+		"-i", tempMetadataFile,
+		"-map", "0:a",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
+		"-c:a", "copy",
+		outputPath,
+	)
+
+	if _, statErr := os.Stat(outputPath); os.IsNotExist(statErr) {
+		defer func() {
+			if err != nil {
+				deleteFile(outputPath)
+			}
+		}()
+	}
+	if output, runErr := runCmd(ctx, o.executor, "ffmpeg", args...); runErr != nil {
+		return fmt.Errorf("ffmpeg split failed: %w - output: %s", runErr, output.Stderr)
+	}
+	if hasChapterDetails(chapters) {
+		return WriteID3Chapters(outputPath, chapters, nil)
+	}
+	return nil
+}
+
+// Replaces the placeholders of the template.
+func getSplitFileName(template string, mp3Filepath string, index int, title string) string {
+	return expandTemplate(template, map[string]any{
+		"index": index,
+		"title": sanitizeFileName(title),
+		"name":  sanitizeFileName(getBaseName(mp3Filepath)),
+	})
+}
+
+// Replaces each placeholder of the template by its value. Integer
+// values are padded with zeros to the width of the placeholder,
+// e.g. {index:02}. Unknown placeholders are kept.
+func expandTemplate(template string, values map[string]any) string {
+	return SPLIT_TEMPLATE_REGEX.ReplaceAllStringFunc(template, func(placeholder string) string {
+		matches := SPLIT_TEMPLATE_REGEX.FindStringSubmatch(placeholder)
+		switch value := values[matches[1]].(type) {
+		case int:
+			width, _ := strconv.Atoi(matches[2])
+			return fmt.Sprintf("%0*d", width, value)
+		case string:
+			return value
+		default:
+			return placeholder
+		}
+	})
+}
+
+// Returns the file name without directory and extension.
+func getBaseName(filePath string) string {
+	return strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+}
+
+func sanitizeFileName(input string) string {
+	return strings.TrimSpace(ILLEGAL_FILENAME_CHARACTERS.ReplaceAllString(input, "_"))
+}
+
+func maxChapterEnd(chapters []Chapter) (result float64) {
+	for _, chapter := range chapters {
+		result = max(result, chapter.GetEndTimeInSeconds())
+	}
+	return result
+}
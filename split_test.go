@@ -0,0 +1,169 @@
+package mp3joiner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitByChapters(t *testing.T) {
+	outputDir := t.TempDir()
+	executor := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":[` +
+			`{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Intro"}},` +
+			`{"time_base":"1/1000","start":10000,"end":25500,"tags":{"title":"Part: 1/2"}}]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"format":{"tags":{"title":"demo","artist":"me"}}}`}},
+		Execution{Name: "ffmpeg"},
+		Execution{Name: "ffmpeg"},
+	)
+
+	got, err := Split("input.mp3", SplitOptions{OutputDir: outputDir}, WithExecutor(executor))
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(outputDir, "01 - Intro.mp3"),
+		filepath.Join(outputDir, "02 - Part_ 1_2.mp3"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %v, want %v", got, want)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("Split() expected all executions to be used, %v remaining", executor.Remaining())
+	}
+
+	calls := executor.Calls()
+	expectedSequences := [][]string{
+		{"-ss", "0.000", "-t", "10.000", "-i", "input.mp3"},
+		{"-ss", "10.000", "-t", "15.500", "-i", "input.mp3"},
+	}
+	for i, sequence := range expectedSequences {
+		args := calls[i+2].Args
+		if !containsSequence(args, sequence) {
+			t.Errorf("Split() expected arguments %v in %v", sequence, args)
+		}
+		if !containsSequence(args, []string{"-c:a", "copy", want[i]}) {
+			t.Errorf("Split() expected stream copy to %s in %v", want[i], args)
+		}
+		if args[0] != "-n" {
+			t.Errorf("Split() expected existing files not to be overwritten in %v", args)
+		}
+	}
+}
+
+func TestSplitByRanges(t *testing.T) {
+	outputDir := t.TempDir()
+	executor := NewReplayExecutor(
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":[]}`}},
+		Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"format":{"tags":{"title":"demo"}}}`}},
+		Execution{Name: "ffmpeg"},
+		Execution{Name: "ffmpeg"},
+	)
+
+	got, err := Split("input.mp3", SplitOptions{
+		OutputDir:    outputDir,
+		NameTemplate: "{name}_{index:03}.mp3",
+		Ranges:       []SplitRange{{Start: 0, End: 5}, {Start: 5, End: -1}},
+	}, WithExecutor(executor))
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(outputDir, "input_001.mp3"),
+		filepath.Join(outputDir, "input_002.mp3"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %v, want %v", got, want)
+	}
+
+	args := executor.Calls()[3].Args
+	if !containsSequence(args, []string{"-ss", "5.000", "-i", "input.mp3"}) {
+		t.Errorf("Split() expected range until the end in %v", args)
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		chapters     string
+		splitOptions SplitOptions
+	}{
+		{
+			name:         "no chapters",
+			chapters:     `[]`,
+			splitOptions: SplitOptions{},
+		}, {
+			name:         "inverted range",
+			chapters:     `[]`,
+			splitOptions: SplitOptions{Ranges: []SplitRange{{Start: 5, End: 2}}},
+		}, {
+			name: "duplicate file names",
+			chapters: `[{"time_base":"1/1000","start":0,"end":10000,"tags":{"title":"Song"}},` +
+				`{"time_base":"1/1000","start":10000,"end":20000,"tags":{"title":"Song"}}]`,
+			splitOptions: SplitOptions{NameTemplate: "{title}.mp3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewReplayExecutor(Execution{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":` + tt.chapters + `}`}})
+			if _, err := Split("input.mp3", tt.splitOptions, WithExecutor(executor)); err == nil {
+				t.Error("Split() expected error")
+			}
+			if executor.Remaining() != 0 || len(executor.Calls()) != 1 {
+				t.Errorf("Split() expected to fail before writing files, found calls %v", executor.Calls())
+			}
+		})
+	}
+}
+
+func TestSplitNonMP3(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "book.m4b")
+	if err := os.WriteFile(filePath, concat(createTestAtom("ftyp", []byte("M4B ")), make([]byte, 8)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	executor := NewReplayExecutor()
+	if _, err := Split(filePath, SplitOptions{}, WithExecutor(executor)); err == nil {
+		t.Error("Split() expected error for M4B input")
+	}
+	if len(executor.Calls()) != 0 {
+		t.Errorf("Split() expected no calls, found %v", executor.Calls())
+	}
+}
+
+func Test_getSplitFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		index    int
+		title    string
+		want     string
+	}{
+		{
+			name:     "default",
+			template: DEFAULT_SPLIT_NAME_TEMPLATE,
+			index:    3,
+			title:    "Intro",
+			want:     "03 - Intro.mp3",
+		}, {
+			name:     "no padding",
+			template: "{index} {title}.mp3",
+			index:    12,
+			title:    "What?",
+			want:     "12 What_.mp3",
+		}, {
+			name:     "input name",
+			template: "{name} - {unknown}.mp3",
+			index:    1,
+			title:    "",
+			want:     "book - {unknown}.mp3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getSplitFileName(tt.template, "folder/book.mp3", tt.index, tt.title); got != tt.want {
+				t.Errorf("getSplitFileName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const streamCopyCodec = "mp3"
+
+// Section of a file listed in a concat demuxer script.
+type concatEntry struct {
+	File     string
+	InPoint  float64
+	OutPoint float64
+}
+
+// Returns the ffmpeg arguments, without the output path, to join all
+// segments via the concat demuxer without re-encoding. Segments which
+// do not match the format of the other segments are re-encoded into
+// temp files first. The returned temp files have to be deleted by the
+// caller, also in case of an error.
+func (b *MP3Builder) getStreamCopyArgs(ctx context.Context, metadataFile string, format OutputFormat) (args []string, tempFiles []string, err error) {
+	target := b.getStreamCopyTarget()
+	entries := make([]concatEntry, 0, len(b.streams))
+	for _, s := range b.streams {
+		if isStreamCopyCompatible(s.Stream, target) {
+			entries = append(entries, concatEntry{
+				File:     s.File,
+				InPoint:  s.Start,
+				OutPoint: s.Start + s.Duration,
+			})
+			continue
+		}
+
+		tempFile, err := b.reencodeSegment(ctx, s, target)
+		if tempFile != "" {
+			tempFiles = append(tempFiles, tempFile)
+		}
+		if err != nil {
+			return nil, tempFiles, err
+		}
+		entries = append(entries, concatEntry{
+			File:     tempFile,
+			InPoint:  0,
+			OutPoint: s.Duration,
+		})
+	}
+
+	concatFile, err := createTempConcatFile(entries)
+	if concatFile != "" {
+		tempFiles = append(tempFiles, concatFile)
+	}
+	if err != nil {
+		return nil, tempFiles, err
+	}
+
+	// ffmpeg -f concat -safe 0 -i LIST -i METADATA -map 0:a -map_metadata 1 -map_chapters 1 -c:a copy OUTPUT.mp3
+	args = []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", concatFile,
+		"-i", metadataFile,
+	}
+	coverArtInputArgs := b.getCoverArtInputArgs(format)
+	args = append(args, coverArtInputArgs...)
+	args = append(args,
+		"-map", "0:a",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
+		"-c:a", "copy",
+	)
+	if b.options.encodeOptions.DisableLAMETag {
+		args = append(args, "-write_xing", "0")
+	}
+	if len(coverArtInputArgs) > 0 {
+		args = append(args, getCoverArtArgs(2)...)
+	}
+	return args, tempFiles, nil
+}
+
+// Returns the format all segments have to share to be copied.
+// This is the format of the first MP3 segment.
+func (b *MP3Builder) getStreamCopyTarget() stream {
+	for _, s := range b.streams {
+		if s.Stream.CodecName == streamCopyCodec {
+			return s.Stream
+		}
+	}
+
+	// no segment can be copied, use the format of the first one
+	// limited to what MP3 supports
+	target := b.streams[0].Stream
+	target.CodecName = streamCopyCodec
+	target.Channels = min(target.Channels, 2)
+	if sampleRate, _ := strconv.Atoi(target.SampleRate); !isMPEGSampleRate(sampleRate) {
+		target.SampleRate = strconv.Itoa(mpegSampleRates[mpegVersion1][0])
+	}
+	return target
+}
+
+func isMPEGSampleRate(sampleRate int) bool {
+	for _, sampleRates := range mpegSampleRates {
+		if slices.Contains(sampleRates, sampleRate) {
+			return true
+		}
+	}
+	return false
+}
+
+func isStreamCopyCompatible(s stream, target stream) bool {
+	return s.CodecName == streamCopyCodec &&
+		s.SampleRate == target.SampleRate &&
+		s.Channels == target.Channels
+}
+
+// Encodes the segment into a temp MP3 file matching the target format.
+func (b *MP3Builder) reencodeSegment(ctx context.Context, s segment, target stream) (tempFilePath string, err error) {
+	tempFile, err := os.CreateTemp("", "mp3joinerSegment*.mp3")
+	if err != nil {
+		return "", err
+	}
+	tempFilePath = tempFile.Name()
+	if err = tempFile.Close(); err != nil {
+		return tempFilePath, err
+	}
+
+	// ffmpeg -y -ss START -t DURATION -i INPUT -map 0:a -c:a libmp3lame -b:a 128k -ar 44100 -ac 2 OUTPUT.mp3
+	args := append([]string{"-y"}, s.getInputArgs()...)
+	args = append(args, "-map", "0:"+s.Stream.getAudioSpecifier())
+	// sample rate and channels have to match the copied segments
+	settings := b.getEncodeOptions()
+	settings.SampleRate = 0
+	settings.ChannelMode = ChannelModeAuto
+	settings.DisableLAMETag = false
+	args = append(args, OutputFormatMP3.getCodecArgs(settings)...)
+	if target.SampleRate != "" {
+		args = append(args, "-ar", target.SampleRate)
+	}
+	if target.Channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(target.Channels))
+	}
+	args = append(args, tempFilePath)
+
+	if output, runErr := runCmd(ctx, b.options.executor, "ffmpeg", args...); runErr != nil {
+		return tempFilePath, fmt.Errorf("ffmpeg could not re-encode segment of '%s': %w - output: %s", s.getName(), runErr, output.Stderr)
+	}
+	return tempFilePath, nil
+}
+
+// Creates a script for the concat demuxer in the temp folder.
+// This file format is described here:
+// https://ffmpeg.org/ffmpeg-formats.html#concat-1
+func createTempConcatFile(entries []concatEntry) (concatFilepath string, err error) {
+	tempFile, err := os.CreateTemp("", "ffmpegConcat")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := tempFile.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = tempFile.WriteString(getConcatScript(entries))
+	return tempFile.Name(), err
+}
+
+func getConcatScript(entries []concatEntry) string {
+	var stringBuilder strings.Builder
+	stringBuilder.WriteString("ffconcat version 1.0")
+	for _, entry := range entries {
+		stringBuilder.WriteString(fmt.Sprintf("\nfile '%s'", escapeConcatPath(entry.File)))
+		stringBuilder.WriteString(fmt.Sprintf("\ninpoint %s", formatSeconds(entry.InPoint)))
+		stringBuilder.WriteString(fmt.Sprintf("\noutpoint %s", formatSeconds(entry.OutPoint)))
+	}
+	return stringBuilder.String()
+}
+
+// Single quotes can not be escaped within a quoted string,
+// therefore the string is closed, an escaped quote added
+// and the string reopened.
+func escapeConcatPath(path string) string {
+	return strings.ReplaceAll(path, "'", `'\''`)
+}
@@ -0,0 +1,124 @@
+package mp3joiner
+
+import (
+	"testing"
+)
+
+func TestMP3Builder_BuildStreamCopy(t *testing.T) {
+	mp3Stream := `{"bit_rate":"64000","codec_name":"mp3","sample_rate":"44100","channels":2}`
+	otherStream := `{"bit_rate":"128000","codec_name":"mp3","sample_rate":"48000","channels":2}`
+	executions := appendExecutions("00:00:10.00", `[]`, mp3Stream, true)
+	executions = append(executions, appendExecutions("00:00:20.00", `[]`, otherStream, false)...)
+	executions = append(executions, appendExecutions("00:00:30.00", `[]`, mp3Stream, false)...)
+	executions = append(executions, Execution{Name: "ffmpeg"}, Execution{Name: "ffmpeg"})
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithBuildMode(BuildModeStreamCopy))
+	for _, file := range []string{"first.mp3", "second.mp3", "third.mp3"} {
+		if err := builder.Append(file, 1, 5); err != nil {
+			t.Fatalf("MP3Builder.Append() error = %v", err)
+		}
+	}
+	if err := builder.Build("out.mp3"); err != nil {
+		t.Fatalf("MP3Builder.Build() error = %v", err)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("MP3Builder.Build() expected all executions to be used, %v remaining", executor.Remaining())
+	}
+
+	calls := executor.Calls()
+	reencodeArgs := calls[len(calls)-2].Args
+	for _, sequence := range [][]string{
+		{"-ss", "1.000", "-t", "4.000", "-i", "second.mp3"},
+		{"-ar", "44100", "-ac", "2"},
+	} {
+		if !containsSequence(reencodeArgs, sequence) {
+			t.Errorf("MP3Builder.Build() expected re-encode arguments %v in %v", sequence, reencodeArgs)
+		}
+	}
+	buildArgs := calls[len(calls)-1].Args
+	for _, sequence := range [][]string{
+		{"-f", "concat", "-safe", "0"},
+		{"-c:a", "copy", "out.mp3"},
+	} {
+		if !containsSequence(buildArgs, sequence) {
+			t.Errorf("MP3Builder.Build() expected arguments %v in %v", sequence, buildArgs)
+		}
+	}
+}
+
+func Test_getConcatScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []concatEntry
+		want    string
+	}{
+		{
+			name:    "empty test",
+			entries: []concatEntry{},
+			want:    "ffconcat version 1.0",
+		}, {
+			name: "positive test",
+			entries: []concatEntry{
+				{File: "/tmp/a.mp3", InPoint: 1, OutPoint: 2.5},
+				{File: "/tmp/it's.mp3", InPoint: 0, OutPoint: 10},
+			},
+			want: "ffconcat version 1.0\n" +
+				"file '/tmp/a.mp3'\n" +
+				"inpoint 1.000\n" +
+				"outpoint 2.500\n" +
+				"file '/tmp/it'\\''s.mp3'\n" +
+				"inpoint 0.000\n" +
+				"outpoint 10.000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getConcatScript(tt.entries); got != tt.want {
+				t.Errorf("getConcatScript() = '%v', want '%v'", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isStreamCopyCompatible(t *testing.T) {
+	target := stream{CodecName: "mp3", SampleRate: "44100", Channels: 2}
+	tests := []struct {
+		name   string
+		stream stream
+		want   bool
+	}{
+		{"same format", stream{CodecName: "mp3", SampleRate: "44100", Channels: 2, Bitrate: "32000"}, true},
+		{"different sample rate", stream{CodecName: "mp3", SampleRate: "48000", Channels: 2}, false},
+		{"different channels", stream{CodecName: "mp3", SampleRate: "44100", Channels: 1}, false},
+		{"different codec", stream{CodecName: "aac", SampleRate: "44100", Channels: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStreamCopyCompatible(tt.stream, target); got != tt.want {
+				t.Errorf("isStreamCopyCompatible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_getStreamCopyTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream stream
+		want   stream
+	}{
+		{"mp3 segment", stream{CodecName: "mp3", SampleRate: "22050", Channels: 1}, stream{CodecName: "mp3", SampleRate: "22050", Channels: 1}},
+		{"supported format", stream{CodecName: "aac", SampleRate: "48000", Channels: 2}, stream{CodecName: "mp3", SampleRate: "48000", Channels: 2}},
+		{"unsupported format", stream{CodecName: "flac", SampleRate: "96000", Channels: 6}, stream{CodecName: "mp3", SampleRate: "44100", Channels: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewMP3Builder()
+			builder.streams = []segment{{Stream: tt.stream}}
+			if got := builder.getStreamCopyTarget(); got != tt.want {
+				t.Errorf("MP3Builder.getStreamCopyTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
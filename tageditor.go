@@ -0,0 +1,238 @@
+package mp3joiner
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	commentFrameID       = "COMM"
+	userTextFrameID      = "TXXX"
+	commentKey           = "comment"
+	defaultLanguage      = "XXX"
+	commentLanguageBytes = 3
+)
+
+var (
+	// mapping of ffmpeg metadata keys to ID3v2 frames as described here:
+	// https://wiki.multimedia.cx/index.php/FFmpeg_Metadata#MP3
+	ffmpegKeyToFrameID = map[string]string{
+		"album":        "TALB",
+		"album_artist": "TPE2",
+		"artist":       "TPE1",
+		"composer":     "TCOM",
+		"copyright":    "TCOP",
+		"date":         "TDRC",
+		"disc":         "TPOS",
+		"encoded_by":   "TENC",
+		"encoder":      "TSSE",
+		"filename":     "TOFN",
+		"genre":        "TCON",
+		"grouping":     "TIT1",
+		"language":     "TLAN",
+		"performer":    "TPE3",
+		"publisher":    "TPUB",
+		"title":        "TIT2",
+		"track":        "TRCK",
+		"album-sort":   "TSOA",
+		"artist-sort":  "TSOP",
+		"title-sort":   "TSOT",
+	}
+	// ID3v2.3 stores the year in a separate frame
+	id3v23FFmpegKeyToFrameID = map[string]string{
+		"date": "TYER",
+	}
+)
+
+// Edits the ID3v2 tag of an MP3 file without re-encoding or
+// remuxing the audio. Changes are only written by Save.
+//
+// Metadata is addressed by the same keys as used by
+// GetFFmpegMetadataTag. Keys without a dedicated ID3v2 frame
+// are stored in TXXX frames.
+type TagEditor struct {
+	mp3Filepath string
+	tag         *id3Tag
+}
+
+// Reads the ID3v2 tag of the file. If the file has
+// no tag, a new ID3v2.4 tag will be created on Save.
+// Fails for other containers like MP4 or FLAC, as a
+// tag at the start of these files would corrupt them.
+func NewTagEditor(mp3Filepath string) (*TagEditor, error) {
+	if container := getContainer(mp3Filepath); container != ContainerMP3 && container != ContainerUnknown {
+		return nil, fmt.Errorf("'%s' is not an MP3 file but %s", mp3Filepath, container)
+	}
+	tag, err := readID3TagFromFile(mp3Filepath)
+	if err != nil {
+		return nil, err
+	}
+	return &TagEditor{
+		mp3Filepath: mp3Filepath,
+		tag:         tag,
+	}, nil
+}
+
+// Returns all text metadata of the tag.
+func (e *TagEditor) Metadata() map[string]string {
+	result := make(map[string]string)
+	frameIDToKey := e.getFrameIDToKey()
+	for _, frame := range e.tag.frames {
+		payload, ok := e.tag.getPayload(frame)
+		if !ok || len(payload) < 1 {
+			continue
+		}
+		switch {
+		case frame.id == userTextFrameID:
+			description, rest := readTerminatedText(payload[1:], payload[0])
+			result[description] = decodeTextFrame(append([]byte{payload[0]}, rest...))
+		case frame.id == commentFrameID:
+			if description, text := decodeCommentFrame(payload); description == "" {
+				result[commentKey] = text
+			}
+		case strings.HasPrefix(frame.id, "T"):
+			key, ok := frameIDToKey[frame.id]
+			if !ok {
+				key = frame.id
+			}
+			result[key] = decodeTextFrame(payload)
+		}
+	}
+	return result
+}
+
+// Returns the value of a single metadata key.
+func (e *TagEditor) Get(key string) (string, bool) {
+	value, ok := e.Metadata()[key]
+	return value, ok
+}
+
+// Sets the value of a single metadata key.
+func (e *TagEditor) Set(key string, value string) {
+	e.Delete(key)
+
+	var frame id3Frame
+	switch frameID := e.getFrameID(key); frameID {
+	case commentFrameID:
+		frame = id3Frame{id: commentFrameID, data: e.tag.encodeCommentFrame(value)}
+	case userTextFrameID:
+		encoding := e.tag.getTextEncoding(key + value)
+		payload := append([]byte{encoding}, encodeText(key, encoding, true)...)
+		frame = id3Frame{id: userTextFrameID, data: append(payload, encodeText(value, encoding, false)...)}
+	default:
+		frame = id3Frame{id: frameID, data: e.tag.encodeTextFrame(value)}
+	}
+	e.tag.frames = append(e.tag.frames, frame)
+}
+
+// Removes a single metadata key.
+func (e *TagEditor) Delete(key string) {
+	frameID := e.getFrameID(key)
+	result := make([]id3Frame, 0, len(e.tag.frames))
+	for _, frame := range e.tag.frames {
+		if frame.id == frameID && e.isFrameOfKey(frame, key) {
+			continue
+		}
+		result = append(result, frame)
+	}
+	e.tag.frames = result
+}
+
+// Replaces all text metadata of the tag. Chapters, images
+// and other binary frames are kept.
+func (e *TagEditor) SetMetadata(metadata map[string]string) {
+	for key := range e.Metadata() {
+		e.Delete(key)
+	}
+	for key, value := range metadata {
+		e.Set(key, value)
+	}
+}
+
+// Returns the chapters and tables of contents of the tag.
+func (e *TagEditor) Chapters() ([]Chapter, []TableOfContents, error) {
+	return e.tag.getChapters()
+}
+
+// Replaces the chapters and tables of contents of the tag.
+// See WriteID3Chapters for details.
+func (e *TagEditor) SetChapters(chapters []Chapter, tablesOfContents []TableOfContents) error {
+	return e.tag.setChapters(chapters, tablesOfContents)
+}
+
+// Writes the tag to the file. If the tag fits into the space of the
+// existing tag, only the tag is overwritten and the audio is not
+// touched. Otherwise the file is rewritten to a temp file with
+// additional padding for later edits, which then replaces the file.
+func (e *TagEditor) Save() error {
+	return writeID3Tag(e.mp3Filepath, e.tag)
+}
+
+// Returns the ID3v2 frame id for the ffmpeg metadata key.
+func (e *TagEditor) getFrameID(key string) string {
+	if key == commentKey {
+		return commentFrameID
+	}
+	if e.tag.version == 3 {
+		if frameID, ok := id3v23FFmpegKeyToFrameID[key]; ok {
+			return frameID
+		}
+	}
+	if frameID, ok := ffmpegKeyToFrameID[key]; ok {
+		return frameID
+	}
+	// keys like TLEN are frame ids themselves
+	if len(key) == id3v2FrameIDLength && strings.HasPrefix(key, "T") && strings.ToUpper(key) == key && key != userTextFrameID {
+		return key
+	}
+	return userTextFrameID
+}
+
+func (e *TagEditor) getFrameIDToKey() map[string]string {
+	result := make(map[string]string, len(ffmpegKeyToFrameID))
+	for key, frameID := range ffmpegKeyToFrameID {
+		result[frameID] = key
+	}
+	if e.tag.version == 3 {
+		for key, frameID := range id3v23FFmpegKeyToFrameID {
+			result[frameID] = key
+		}
+	}
+	return result
+}
+
+// Frames like TXXX and COMM hold multiple keys, which are
+// distinguished by their description.
+func (e *TagEditor) isFrameOfKey(frame id3Frame, key string) bool {
+	payload, ok := e.tag.getPayload(frame)
+	if !ok || len(payload) < 1 {
+		return frame.id != userTextFrameID && frame.id != commentFrameID
+	}
+	switch frame.id {
+	case userTextFrameID:
+		description, _ := readTerminatedText(payload[1:], payload[0])
+		return description == key
+	case commentFrameID:
+		description, _ := decodeCommentFrame(payload)
+		return description == ""
+	default:
+		return true
+	}
+}
+
+func decodeCommentFrame(payload []byte) (description string, text string) {
+	if len(payload) < 1+commentLanguageBytes {
+		return "", ""
+	}
+	encoding := payload[0]
+	description, rest := readTerminatedText(payload[1+commentLanguageBytes:], encoding)
+	text, _ = readTerminatedText(rest, encoding)
+	return description, text
+}
+
+func (t *id3Tag) encodeCommentFrame(text string) []byte {
+	encoding := t.getTextEncoding(text)
+	payload := append([]byte{encoding}, defaultLanguage...)
+	payload = append(payload, encodeText("", encoding, true)...)
+	return append(payload, encodeText(text, encoding, false)...)
+}
@@ -0,0 +1,148 @@
+package mp3joiner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTagEditor_Save(t *testing.T) {
+	filePath := createTestMP3File(t, 4)
+
+	// first save adds padding as the tag grows
+	editor, err := NewTagEditor(filePath)
+	if err != nil {
+		t.Fatalf("NewTagEditor() error = %v", err)
+	}
+	editor.Set("artist", "Someone")
+	if err := editor.Save(); err != nil {
+		t.Fatalf("TagEditor.Save() error = %v", err)
+	}
+	sizeWithPadding := getFileSizeInBytes(t, filePath)
+
+	// second save fits into the padding and keeps the file size
+	editor, err = NewTagEditor(filePath)
+	if err != nil {
+		t.Fatalf("NewTagEditor() error = %v", err)
+	}
+	editor.Set("title", "Another Song")
+	editor.Set("comment", "Nice")
+	if err := editor.Save(); err != nil {
+		t.Fatalf("TagEditor.Save() error = %v", err)
+	}
+	if size := getFileSizeInBytes(t, filePath); size != sizeWithPadding {
+		t.Errorf("TagEditor.Save() expected in place edit with size %v, found %v", sizeWithPadding, size)
+	}
+	assertTagEditorMetadata(t, filePath, map[string]string{"title": "Another Song", "artist": "Someone", "comment": "Nice"})
+
+	// a tag exceeding the padding requires a rewrite
+	editor, err = NewTagEditor(filePath)
+	if err != nil {
+		t.Fatalf("NewTagEditor() error = %v", err)
+	}
+	longValue := strings.Repeat("a", 2*defaultID3v2Padding)
+	editor.Set("description", longValue)
+	if err := editor.Save(); err != nil {
+		t.Fatalf("TagEditor.Save() error = %v", err)
+	}
+	if size := getFileSizeInBytes(t, filePath); size <= sizeWithPadding {
+		t.Errorf("TagEditor.Save() expected file to grow, found %v", size)
+	}
+	assertTagEditorMetadata(t, filePath, map[string]string{"title": "Another Song", "artist": "Someone", "comment": "Nice", "description": longValue})
+
+	info, err := GetMP3Info(filePath)
+	if err != nil || info.Frames != 10 {
+		t.Errorf("TagEditor.Save() did not keep audio, %v, %v", info, err)
+	}
+}
+
+func TestTagEditor_SetMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     byte
+		metadata    map[string]string
+		wantFrameID string
+	}{
+		{
+			name:        "ID3v2.4",
+			version:     4,
+			metadata:    map[string]string{"title": "Title", "date": "2024", "TLEN": "1000", "custom key": "value", "comment": "text"},
+			wantFrameID: "TDRC",
+		}, {
+			name:        "ID3v2.3",
+			version:     3,
+			metadata:    map[string]string{"album": "Album", "date": "2024", "track": "1/2"},
+			wantFrameID: "TYER",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := createTestMP3File(t, tt.version)
+			editor, err := NewTagEditor(filePath)
+			if err != nil {
+				t.Fatalf("NewTagEditor() error = %v", err)
+			}
+			editor.SetMetadata(tt.metadata)
+			if err := editor.Save(); err != nil {
+				t.Fatalf("TagEditor.Save() error = %v", err)
+			}
+
+			assertTagEditorMetadata(t, filePath, tt.metadata)
+			tag, err := readID3TagFromFile(filePath)
+			if err != nil {
+				t.Fatalf("could not read tag %v", err)
+			}
+			if len(tag.getPayloads(tt.wantFrameID)) != 1 {
+				t.Errorf("TagEditor.SetMetadata() expected date in frame %v", tt.wantFrameID)
+			}
+		})
+	}
+}
+
+func TestNewTagEditor(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(filePath, createTestFrames(testMPEG1FrameHeader, 3), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	editor, err := NewTagEditor(filePath)
+	if err != nil {
+		t.Fatalf("NewTagEditor() error = %v", err)
+	}
+	editor.Set("title", "New")
+	if err := editor.SetChapters([]Chapter{{TimeBase: "1/1", Start: 0, End: 1, Tags: Tags{Title: "One"}}}, nil); err != nil {
+		t.Fatalf("TagEditor.SetChapters() error = %v", err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("TagEditor.Save() error = %v", err)
+	}
+
+	assertTagEditorMetadata(t, filePath, map[string]string{"title": "New"})
+	chapters, _, err := ReadID3Chapters(filePath)
+	if err != nil || len(chapters) != 1 || chapters[0].Tags.Title != "One" {
+		t.Errorf("TagEditor.Save() expected chapter, found %v, %v", chapters, err)
+	}
+	if _, err := NewTagEditor("not existing"); err == nil {
+		t.Errorf("NewTagEditor() expected error for non existing file")
+	}
+
+	flacFilePath := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(flacFilePath, []byte("fLaC and some audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTagEditor(flacFilePath); err == nil {
+		t.Errorf("NewTagEditor() expected error for FLAC file")
+	}
+}
+
+func assertTagEditorMetadata(t *testing.T, filePath string, want map[string]string) {
+	editor, err := NewTagEditor(filePath)
+	if err != nil {
+		t.Fatalf("NewTagEditor() error = %v", err)
+	}
+	if got := editor.Metadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TagEditor.Metadata() = %v, want %v", got, want)
+	}
+}
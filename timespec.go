@@ -0,0 +1,228 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	TIMECODE_REGEX       = regexp.MustCompile(`^(?:(\d+):)?(\d+):(\d+(?:\.\d+)?)$`)
+	SECONDS_REGEX        = regexp.MustCompile(`^\d+(?:\.\d+)?$`)
+	CHAPTER_ANCHOR_REGEX = regexp.MustCompile(`^chapter:(?:"((?:[^"\\]|\\.)*)"|(\d+))(?:\.(start|end))?(?:([+-])(.+))?$`)
+)
+
+// Point of a file which a TimeSpec is relative to.
+type TimeAnchor int
+
+const (
+	TimeAnchorFileStart TimeAnchor = iota
+	TimeAnchorFileEnd
+	// start of the chapter selected by title or index
+	TimeAnchorChapterStart
+	// end of the chapter selected by title or index
+	TimeAnchorChapterEnd
+)
+
+// Point in time of a file, see ParseTimeSpec.
+type TimeSpec struct {
+	Anchor TimeAnchor
+	// seconds added to the anchor, negative offsets point before it
+	Offset float64
+	// title of the chapter of chapter anchors,
+	// if empty the chapter is selected by its index
+	ChapterTitle string
+	// position of the chapter ordered by start time, counting from 0
+	ChapterIndex int
+}
+
+// Parses a point in time of a file. Supported are
+//   - timecodes, e.g. 01:02:03.500 or 02:03
+//   - seconds, e.g. 90 or 90.5
+//   - Go durations, e.g. 90s, 1m30s or 1h2m
+//   - negative times relative to the end of the file, e.g. -30s
+//   - "start" and "end" of the file
+//   - chapter anchors with an optional offset, e.g. chapter:"Outro".start+5s,
+//     chapter:"Outro".end-1:30 or chapter:2 for the start of the third chapter
+func ParseTimeSpec(spec string) (TimeSpec, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "start":
+		return TimeSpec{Anchor: TimeAnchorFileStart}, nil
+	case "end":
+		return TimeSpec{Anchor: TimeAnchorFileEnd}, nil
+	}
+
+	if matches := CHAPTER_ANCHOR_REGEX.FindStringSubmatch(spec); matches != nil {
+		result := TimeSpec{Anchor: TimeAnchorChapterStart}
+		if matches[2] != "" {
+			index, err := strconv.Atoi(matches[2])
+			if err != nil {
+				return result, fmt.Errorf("invalid chapter index in '%s': %w", spec, err)
+			}
+			result.ChapterIndex = index
+		} else {
+			result.ChapterTitle = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(matches[1])
+			if result.ChapterTitle == "" {
+				return result, fmt.Errorf("empty chapter title in '%s'", spec)
+			}
+		}
+		if matches[3] == "end" {
+			result.Anchor = TimeAnchorChapterEnd
+		}
+		if matches[4] != "" {
+			offset, err := parseDuration(matches[5])
+			if err != nil || offset < 0 {
+				return result, fmt.Errorf("invalid offset in '%s'", spec)
+			}
+			if matches[4] == "-" {
+				offset = -offset
+			}
+			result.Offset = offset
+		}
+		return result, nil
+	}
+
+	if rest, isNegative := strings.CutPrefix(spec, "-"); isNegative {
+		offset, err := parseDuration(rest)
+		if err != nil || offset < 0 {
+			return TimeSpec{}, fmt.Errorf("invalid time '%s'", spec)
+		}
+		return TimeSpec{Anchor: TimeAnchorFileEnd, Offset: -offset}, nil
+	}
+	offset, err := parseDuration(spec)
+	if err != nil {
+		return TimeSpec{}, fmt.Errorf("invalid time '%s'", spec)
+	}
+	return TimeSpec{Anchor: TimeAnchorFileStart, Offset: offset}, nil
+}
+
+// Parses a timecode, seconds or a Go duration into seconds.
+func parseDuration(value string) (float64, error) {
+	if matches := TIMECODE_REGEX.FindStringSubmatch(value); matches != nil {
+		hours := 0
+		if matches[1] != "" {
+			hours, _ = strconv.Atoi(matches[1])
+		}
+		minutes, _ := strconv.Atoi(matches[2])
+		seconds, err := strconv.ParseFloat(matches[3], 64)
+		if err != nil {
+			return 0, err
+		}
+		if (matches[1] != "" && minutes >= 60) || seconds >= 60 {
+			return 0, fmt.Errorf("invalid timecode '%s'", value)
+		}
+		return float64(hours*3600+minutes*60) + seconds, nil
+	}
+	// plain decimal seconds only, no exponents, hex floats, NaN or Inf
+	if SECONDS_REGEX.MatchString(value) {
+		return strconv.ParseFloat(value, 64)
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return duration.Seconds(), nil
+}
+
+func (t TimeSpec) needsChapters() bool {
+	return t.Anchor == TimeAnchorChapterStart || t.Anchor == TimeAnchorChapterEnd
+}
+
+// Returns the point in time in seconds of a file with the length and chapters.
+func (t TimeSpec) resolve(length float64, chapters []Chapter) (float64, error) {
+	var anchor float64
+	switch t.Anchor {
+	case TimeAnchorFileStart:
+	case TimeAnchorFileEnd:
+		anchor = length
+	case TimeAnchorChapterStart, TimeAnchorChapterEnd:
+		chapter, err := t.findChapter(chapters)
+		if err != nil {
+			return 0, err
+		}
+		anchor = chapter.GetStartTimeInSeconds()
+		if t.Anchor == TimeAnchorChapterEnd {
+			anchor = chapter.GetEndTimeInSeconds()
+		}
+	default:
+		return 0, fmt.Errorf("unknown time anchor %d", t.Anchor)
+	}
+
+	result := anchor + t.Offset
+	if result < 0 {
+		return 0, fmt.Errorf("time %vs is before the start of the file", result)
+	}
+	return result, nil
+}
+
+func (t TimeSpec) findChapter(chapters []Chapter) (Chapter, error) {
+	if t.ChapterTitle != "" {
+		for _, chapter := range chapters {
+			if chapter.Tags.Title == t.ChapterTitle {
+				return chapter, nil
+			}
+		}
+		return Chapter{}, fmt.Errorf("no chapter with title '%s'", t.ChapterTitle)
+	}
+
+	if t.ChapterIndex < 0 || t.ChapterIndex >= len(chapters) {
+		return Chapter{}, fmt.Errorf("no chapter %d of %d chapters", t.ChapterIndex, len(chapters))
+	}
+	sorted := append([]Chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetStartTimeInSeconds() < sorted[j].GetStartTimeInSeconds()
+	})
+	return sorted[t.ChapterIndex], nil
+}
+
+// Same as Append but start and end are given as time specifications,
+// see ParseTimeSpec. An empty start reads from the start of the file,
+// an empty end until the end of the file.
+func (b *MP3Builder) AppendSpec(audioFilepath string, start string, end string) error {
+	return b.AppendSpecContext(context.Background(), audioFilepath, start, end)
+}
+
+func (b *MP3Builder) AppendSpecContext(ctx context.Context, audioFilepath string, start string, end string) (err error) {
+	startSpec, endSpec := TimeSpec{Anchor: TimeAnchorFileStart}, TimeSpec{Anchor: TimeAnchorFileEnd}
+	if start != "" {
+		if startSpec, err = ParseTimeSpec(start); err != nil {
+			return err
+		}
+	}
+	if end != "" {
+		if endSpec, err = ParseTimeSpec(end); err != nil {
+			return err
+		}
+	}
+
+	length := 0.0
+	if startSpec.Anchor == TimeAnchorFileEnd || (endSpec.Anchor == TimeAnchorFileEnd && endSpec.Offset != 0) {
+		if length, err = b.getLengthInSeconds(ctx, audioFilepath); err != nil {
+			return err
+		}
+	}
+	var chapters []Chapter
+	if startSpec.needsChapters() || endSpec.needsChapters() {
+		if chapters, err = readChapters(ctx, b.options, audioFilepath); err != nil {
+			return err
+		}
+	}
+
+	startInSeconds, err := startSpec.resolve(length, chapters)
+	if err != nil {
+		return err
+	}
+	// -1 reads until the end without determining the length first
+	endInSeconds := -1.0
+	if endSpec.Anchor != TimeAnchorFileEnd || endSpec.Offset != 0 {
+		if endInSeconds, err = endSpec.resolve(length, chapters); err != nil {
+			return err
+		}
+	}
+	return b.AppendContext(ctx, audioFilepath, startInSeconds, endInSeconds)
+}
@@ -0,0 +1,112 @@
+package mp3joiner
+
+import (
+	"testing"
+)
+
+func TestParseTimeSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    TimeSpec
+		wantErr bool
+	}{
+		{spec: "01:02:03.500", want: TimeSpec{Offset: 3723.5}},
+		{spec: "02:03", want: TimeSpec{Offset: 123}},
+		{spec: "90:00", want: TimeSpec{Offset: 5400}},
+		{spec: "90", want: TimeSpec{Offset: 90}},
+		{spec: "12.25", want: TimeSpec{Offset: 12.25}},
+		{spec: "90s", want: TimeSpec{Offset: 90}},
+		{spec: "1m30s", want: TimeSpec{Offset: 90}},
+		{spec: "1h2m", want: TimeSpec{Offset: 3720}},
+		{spec: "-30s", want: TimeSpec{Anchor: TimeAnchorFileEnd, Offset: -30}},
+		{spec: "-00:45", want: TimeSpec{Anchor: TimeAnchorFileEnd, Offset: -45}},
+		{spec: "start", want: TimeSpec{Anchor: TimeAnchorFileStart}},
+		{spec: "end", want: TimeSpec{Anchor: TimeAnchorFileEnd}},
+		{spec: `chapter:"Outro"`, want: TimeSpec{Anchor: TimeAnchorChapterStart, ChapterTitle: "Outro"}},
+		{spec: `chapter:"Outro".start+5s`, want: TimeSpec{Anchor: TimeAnchorChapterStart, ChapterTitle: "Outro", Offset: 5}},
+		{spec: `chapter:"Part \"2\"".end-1:30`, want: TimeSpec{Anchor: TimeAnchorChapterEnd, ChapterTitle: `Part "2"`, Offset: -90}},
+		{spec: `chapter:2.end`, want: TimeSpec{Anchor: TimeAnchorChapterEnd, ChapterIndex: 2}},
+		{spec: "", wantErr: true},
+		{spec: "01:75", wantErr: true},
+		{spec: "1:61:00", wantErr: true},
+		{spec: "ten seconds", wantErr: true},
+		{spec: "nan", wantErr: true},
+		{spec: "inf", wantErr: true},
+		{spec: "-inf", wantErr: true},
+		{spec: "1e9", wantErr: true},
+		{spec: "0x1p4", wantErr: true},
+		{spec: "--30s", wantErr: true},
+		{spec: `chapter:""`, wantErr: true},
+		{spec: `chapter:"Outro".start+later`, wantErr: true},
+		{spec: `chapter:"Outro".middle`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseTimeSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseTimeSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSpec_resolve(t *testing.T) {
+	chapters := []Chapter{
+		{TimeBase: "1/1000", Start: 540000, End: 600000, Tags: Tags{Title: "Outro"}},
+		{TimeBase: "1/1000", Start: 0, End: 540000, Tags: Tags{Title: "Main"}},
+	}
+	tests := []struct {
+		name    string
+		spec    TimeSpec
+		want    float64
+		wantErr bool
+	}{
+		{name: "file start", spec: TimeSpec{Offset: 12}, want: 12},
+		{name: "file end", spec: TimeSpec{Anchor: TimeAnchorFileEnd, Offset: -30}, want: 570},
+		{name: "chapter title", spec: TimeSpec{Anchor: TimeAnchorChapterStart, ChapterTitle: "Outro", Offset: 5}, want: 545},
+		{name: "chapter index", spec: TimeSpec{Anchor: TimeAnchorChapterEnd, ChapterIndex: 0, Offset: -10}, want: 530},
+		{name: "unknown title", spec: TimeSpec{Anchor: TimeAnchorChapterStart, ChapterTitle: "Intro"}, wantErr: true},
+		{name: "unknown index", spec: TimeSpec{Anchor: TimeAnchorChapterStart, ChapterIndex: 2}, wantErr: true},
+		{name: "before start", spec: TimeSpec{Anchor: TimeAnchorFileEnd, Offset: -700}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.spec.resolve(600, chapters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TimeSpec.resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("TimeSpec.resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_AppendSpec(t *testing.T) {
+	chapters := `[{"time_base":"1/1000","start":0,"end":540000,"tags":{"title":"Main"}},` +
+		`{"time_base":"1/1000","start":540000,"end":600000,"tags":{"title":"Outro"}}]`
+	executions := []Execution{
+		{Name: "ffmpeg", Result: ExecResult{Stderr: "size=N/A time=00:10:00.00 bitrate=N/A speed=1x"}},
+		{Name: "ffprobe", Result: ExecResult{Stdout: `{"chapters":` + chapters + `}`}},
+	}
+	executions = append(executions, appendExecutions("00:10:00.00", chapters, `{"bit_rate":"64000"}`, true)...)
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor))
+	if err := builder.AppendSpec("episode.mp3", `chapter:"Main".end-1m`, "-10s"); err != nil {
+		t.Fatalf("MP3Builder.AppendSpec() error = %v", err)
+	}
+	if s := builder.streams[0]; s.Start != 480 || s.Duration != 110 {
+		t.Errorf("MP3Builder.AppendSpec() segment = %v - %v, want 480 - 590", s.Start, s.Start+s.Duration)
+	}
+	if executor.Remaining() != 0 {
+		t.Errorf("MP3Builder.AppendSpec() expected %d more executions", executor.Remaining())
+	}
+
+	if err := builder.AppendSpec("episode.mp3", "1:00:00:00", ""); err == nil {
+		t.Error("MP3Builder.AppendSpec() expected error for invalid start")
+	}
+}
@@ -0,0 +1,111 @@
+package mp3joiner
+
+import (
+	"fmt"
+)
+
+// Shape of the volume change of a fade, see the curves of the afade
+// filter of ffmpeg.
+type FadeCurve string
+
+const (
+	// linear, the default
+	FadeCurveTriangular FadeCurve = "tri"
+	// keeps the perceived loudness constant during crossfades
+	FadeCurveQuarterSine FadeCurve = "qsin"
+	FadeCurveHalfSine    FadeCurve = "hsin"
+	FadeCurveExponential FadeCurve = "exp"
+	FadeCurveLogarithmic FadeCurve = "log"
+)
+
+var fadeCurves = []FadeCurve{
+	FadeCurveTriangular, FadeCurveQuarterSine, FadeCurveHalfSine,
+	FadeCurveExponential, FadeCurveLogarithmic,
+}
+
+// Fade in, fade out or crossfade between two segments.
+type Fade struct {
+	// length of the fade in seconds. A crossfade overlaps both
+	// segments by this length, which shortens the output.
+	Duration float64
+	// shape of the fade, FadeCurveTriangular if empty
+	Curve FadeCurve
+}
+
+func (f Fade) getCurve() FadeCurve {
+	if f.Curve == "" {
+		return FadeCurveTriangular
+	}
+	return f.Curve
+}
+
+func (f Fade) validate() error {
+	if f.Duration < 0 {
+		return fmt.Errorf("invalid negative fade duration %v", f.Duration)
+	}
+	for _, curve := range fadeCurves {
+		if f.getCurve() == curve {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown fade curve '%s'", f.Curve)
+}
+
+// Sets the crossfade from the previous segment into the segment at
+// the index, counting appended files from 0. Overrides the crossfade
+// set by WithCrossfade, a fade without duration joins the segments
+// without crossfade. Chapters of the previous segment end where the
+// crossfade starts.
+func (b *MP3Builder) SetCrossfade(index int, crossfade Fade) error {
+	if index < 1 || index >= len(b.streams) {
+		return fmt.Errorf("no join before segment %d of %d segments", index, len(b.streams))
+	}
+	if err := crossfade.validate(); err != nil {
+		return err
+	}
+	b.streams[index].Crossfade = &crossfade
+	return nil
+}
+
+// Returns the crossfade from the previous segment into the segment at the index.
+func (b *MP3Builder) getCrossfade(index int) Fade {
+	if index < 1 || index >= len(b.streams) {
+		return Fade{}
+	}
+	if b.streams[index].Crossfade != nil {
+		return *b.streams[index].Crossfade
+	}
+	return b.options.crossfade
+}
+
+func (b *MP3Builder) hasTransitions() bool {
+	if b.options.fadeIn.Duration > 0 || b.options.fadeOut.Duration > 0 {
+		return true
+	}
+	for i := range b.streams {
+		if b.getCrossfade(i).Duration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that each crossfade fits into both segments
+// and the fades fit into the output.
+func (b *MP3Builder) validateTransitions() error {
+	for _, fade := range []Fade{b.options.crossfade, b.options.fadeIn, b.options.fadeOut} {
+		if err := fade.validate(); err != nil {
+			return err
+		}
+	}
+	for i, s := range b.streams {
+		overlap := b.getCrossfade(i).Duration + b.getCrossfade(i+1).Duration
+		if overlap > s.getOutputDuration() {
+			return fmt.Errorf("crossfades of %vs are longer than segment %d with %vs", overlap, i, s.getOutputDuration())
+		}
+	}
+	if fades := b.options.fadeIn.Duration + b.options.fadeOut.Duration; fades > b.getTotalDuration() {
+		return fmt.Errorf("fades of %vs are longer than the output with %vs", fades, b.getTotalDuration())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package mp3joiner
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMP3Builder_getChaptersWithCrossfade(t *testing.T) {
+	builder := NewMP3Builder(WithCrossfade(Fade{Duration: 2}))
+	for _, title := range []string{"First", "Second", "Third"} {
+		builder.streams = append(builder.streams, segment{
+			Duration: 10,
+			Chapters: []Chapter{{TimeBase: "1/1000", Start: 0, End: 10000, Tags: Tags{Title: title}}},
+		})
+	}
+	if err := builder.SetCrossfade(2, Fade{}); err != nil {
+		t.Fatalf("MP3Builder.SetCrossfade() error = %v", err)
+	}
+
+	expected := []struct {
+		title string
+		start float64
+		end   float64
+	}{
+		{title: "First", start: 0, end: 8},
+		{title: "Second", start: 8, end: 18},
+		{title: "Third", start: 18, end: 28},
+	}
+	chapters := builder.getChapters()
+	if len(chapters) != len(expected) {
+		t.Fatalf("MP3Builder.getChapters() got %d chapters, want %d", len(chapters), len(expected))
+	}
+	for i, want := range expected {
+		chapter := chapters[i]
+		if chapter.Tags.Title != want.title || chapter.GetStartTimeInSeconds() != want.start || chapter.GetEndTimeInSeconds() != want.end {
+			t.Errorf("MP3Builder.getChapters() chapter %d = %+v, want %+v", i, chapter, want)
+		}
+	}
+	if got := builder.getTotalDuration(); got != 28 {
+		t.Errorf("MP3Builder.getTotalDuration() = %v, want 28", got)
+	}
+}
+
+func TestMP3Builder_SetCrossfade(t *testing.T) {
+	builder := NewMP3Builder()
+	builder.streams = []segment{{Duration: 10}, {Duration: 10}}
+
+	tests := []struct {
+		name    string
+		index   int
+		fade    Fade
+		wantErr bool
+	}{
+		{name: "valid", index: 1, fade: Fade{Duration: 2, Curve: FadeCurveHalfSine}},
+		{name: "first segment", index: 0, fade: Fade{Duration: 2}, wantErr: true},
+		{name: "after last segment", index: 2, fade: Fade{Duration: 2}, wantErr: true},
+		{name: "negative duration", index: 1, fade: Fade{Duration: -1}, wantErr: true},
+		{name: "unknown curve", index: 1, fade: Fade{Duration: 1, Curve: "wobble"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := builder.SetCrossfade(tt.index, tt.fade); (err != nil) != tt.wantErr {
+				t.Errorf("MP3Builder.SetCrossfade() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_BuildWithTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "crossfade", opts: []Option{WithCrossfade(Fade{Duration: 3})}},
+		{name: "crossfade longer than segment", opts: []Option{WithCrossfade(Fade{Duration: 11})}, wantErr: true},
+		{name: "fades longer than output", opts: []Option{WithFadeIn(Fade{Duration: 11}), WithFadeOut(Fade{Duration: 11})}, wantErr: true},
+		{name: "stream copy", opts: []Option{WithFadeIn(Fade{Duration: 1}), WithBuildMode(BuildModeStreamCopy)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executions := appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"64000"}`, true)
+			executions = append(executions, appendExecutions("00:00:10.00", `[]`, `{"bit_rate":"64000"}`, false)...)
+			executor := NewReplayExecutor(append(executions, Execution{Name: "ffmpeg"})...)
+			builder := NewMP3Builder(append(tt.opts, WithExecutor(executor))...)
+			for _, file := range []string{"first.mp3", "second.mp3"} {
+				if err := builder.Append(file, 0, -1); err != nil {
+					t.Fatalf("MP3Builder.Append() error = %v", err)
+				}
+			}
+
+			err := builder.Build(filepath.Join(t.TempDir(), "out.mp3"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MP3Builder.Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && executor.Remaining() != 0 {
+				t.Errorf("MP3Builder.Build() expected ffmpeg to run")
+			}
+		})
+	}
+}
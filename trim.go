@@ -0,0 +1,96 @@
+package mp3joiner
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// shortest silence which is trimmed
+	minTrimmedSilence = 0.1
+	// silence ending this close to an edge of a segment counts as edge silence
+	silenceEdgeTolerance = 0.05
+)
+
+// Defines how silence at the start and the end of a segment is trimmed.
+type SilenceTrim struct {
+	// level in dB below which audio counts as silence, -50 if 0
+	NoiseFloor float64
+	// maximum seconds trimmed from each edge, unlimited if 0
+	MaxTrim float64
+	// seconds of silence kept at each edge, so words are not cut
+	Padding float64
+}
+
+func (t SilenceTrim) getNoiseFloor() float64 {
+	if t.NoiseFloor == 0 {
+		return defaultSilenceNoiseFloor
+	}
+	return t.NoiseFloor
+}
+
+func (t SilenceTrim) validate() error {
+	if t.NoiseFloor > 0 {
+		return fmt.Errorf("noise floor has to be negative but is %v dB", t.NoiseFloor)
+	}
+	if t.MaxTrim < 0 || t.Padding < 0 {
+		return fmt.Errorf("invalid negative trim length")
+	}
+	return nil
+}
+
+// Returns the seconds to trim from an edge with the given silence.
+func (t SilenceTrim) getTrim(silence float64) float64 {
+	result := max(silence-t.Padding, 0)
+	if t.MaxTrim > 0 {
+		result = min(result, t.MaxTrim)
+	}
+	return result
+}
+
+// Removes silence from the start and the end of the segment at the
+// index, counting appended files from 0. Chapters of the segment are
+// moved accordingly, chapters within the trimmed silence are removed.
+// Segments which are silent as a whole are not changed.
+func (b *MP3Builder) TrimSilence(index int, trim SilenceTrim) error {
+	return b.TrimSilenceContext(context.Background(), index, trim)
+}
+
+func (b *MP3Builder) TrimSilenceContext(ctx context.Context, index int, trim SilenceTrim) error {
+	if err := b.checkSegmentIndex(index); err != nil {
+		return err
+	}
+	if b.streams[index].Source != "" {
+		return fmt.Errorf("segment %d is generated audio", index)
+	}
+	return b.trimSilence(ctx, &b.streams[index], trim)
+}
+
+func (b *MP3Builder) trimSilence(ctx context.Context, s *segment, trim SilenceTrim) error {
+	if err := trim.validate(); err != nil {
+		return err
+	}
+	silences, err := runSilenceDetect(ctx, b.options, s.getInputArgs(), s.Stream.getAudioSpecifier(), trim.getNoiseFloor(), minTrimmedSilence, s.Duration)
+	if err != nil {
+		return err
+	}
+
+	leading, trailing := 0.0, 0.0
+	for _, silence := range silences {
+		if silence.start <= silenceEdgeTolerance {
+			leading = trim.getTrim(silence.end)
+		}
+		if silence.end >= s.Duration-silenceEdgeTolerance {
+			trailing = trim.getTrim(s.Duration - silence.start)
+		}
+	}
+	duration := s.Duration - leading - trailing
+	if duration <= 0 {
+		return nil
+	}
+
+	s.Chapters = rebaseChapters(getChapterInTimeFrame(s.Chapters, leading, leading+duration), -leading)
+	s.Start += leading
+	s.Duration = duration
+	return nil
+}
@@ -0,0 +1,104 @@
+package mp3joiner
+
+import (
+	"math"
+	"testing"
+)
+
+const edgeSilenceStderr = `[silencedetect @ 0x5633c8c0] silence_start: 0
+[silencedetect @ 0x5633c8c0] silence_end: 3 | silence_duration: 3
+[silencedetect @ 0x5633c8c0] silence_start: 20
+[silencedetect @ 0x5633c8c0] silence_end: 21 | silence_duration: 1
+[silencedetect @ 0x5633c8c0] silence_start: 56
+[silencedetect @ 0x5633c8c0] silence_end: 60 | silence_duration: 4
+`
+
+func TestMP3Builder_TrimSilence(t *testing.T) {
+	tests := []struct {
+		name      string
+		trim      SilenceTrim
+		stderr    string
+		wantStart float64
+		wantEnd   float64
+	}{
+		{name: "edges", stderr: edgeSilenceStderr, wantStart: 13, wantEnd: 66},
+		{name: "padding", trim: SilenceTrim{Padding: 0.5}, stderr: edgeSilenceStderr, wantStart: 12.5, wantEnd: 66.5},
+		{name: "maximum trim", trim: SilenceTrim{MaxTrim: 2}, stderr: edgeSilenceStderr, wantStart: 12, wantEnd: 68},
+		{name: "no edge silence", stderr: "[silencedetect @ 0x5633c8c0] silence_start: 20\n[silencedetect @ 0x5633c8c0] silence_end: 21\n", wantStart: 10, wantEnd: 70},
+		{name: "silent segment", stderr: "[silencedetect @ 0x5633c8c0] silence_start: 0\n", wantStart: 10, wantEnd: 70},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewReplayExecutor(Execution{Name: "ffmpeg", Result: ExecResult{Stderr: tt.stderr}})
+			builder := NewMP3Builder(WithExecutor(executor))
+			builder.streams = []segment{{
+				File:     "interview.mp3",
+				Start:    10,
+				Duration: 60,
+				Stream:   stream{audioIndex: 1},
+				Chapters: []Chapter{
+					{TimeBase: DEFAULT_TIME_BASE, Start: 0, End: toDefaultTimeBase(30), Tags: Tags{Title: "First"}},
+					{TimeBase: DEFAULT_TIME_BASE, Start: toDefaultTimeBase(30), End: toDefaultTimeBase(60), Tags: Tags{Title: "Second"}},
+				},
+			}}
+			if err := builder.TrimSilence(0, tt.trim); err != nil {
+				t.Fatalf("MP3Builder.TrimSilence() error = %v", err)
+			}
+
+			s := builder.streams[0]
+			if s.Start != tt.wantStart || math.Abs(s.Start+s.Duration-tt.wantEnd) > 1e-9 {
+				t.Errorf("MP3Builder.TrimSilence() range = %v - %v, want %v - %v", s.Start, s.Start+s.Duration, tt.wantStart, tt.wantEnd)
+			}
+			if len(s.Chapters) != 2 || s.Chapters[0].Start != 0 || math.Abs(s.Chapters[1].GetEndTimeInSeconds()-s.Duration) > 1e-6 ||
+				math.Abs(s.Chapters[1].GetStartTimeInSeconds()-(40-s.Start)) > 1e-6 {
+				t.Errorf("MP3Builder.TrimSilence() chapters = %+v", s.Chapters)
+			}
+			if args := executor.Calls()[0].Args; !containsSequence(args, []string{"-ss", "10.000", "-t", "60.000", "-i", "interview.mp3", "-map", "0:a:1"}) {
+				t.Errorf("MP3Builder.TrimSilence() args = %v", args)
+			}
+		})
+	}
+}
+
+func TestMP3Builder_TrimSilenceErrors(t *testing.T) {
+	builder := NewMP3Builder()
+	builder.streams = []segment{{File: "interview.mp3", Duration: 10}}
+	if err := builder.AppendSilence(1); err != nil {
+		t.Fatalf("MP3Builder.AppendSilence() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		trim  SilenceTrim
+	}{
+		{name: "unknown segment", index: 2},
+		{name: "generated audio", index: 1},
+		{name: "positive noise floor", index: 0, trim: SilenceTrim{NoiseFloor: 10}},
+		{name: "negative padding", index: 0, trim: SilenceTrim{Padding: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := builder.TrimSilence(tt.index, tt.trim); err == nil {
+				t.Error("MP3Builder.TrimSilence() expected error")
+			}
+		})
+	}
+}
+
+func TestMP3Builder_AppendWithSilenceTrim(t *testing.T) {
+	executions := appendExecutions("00:01:00.00", `[]`, `{"bit_rate":"64000"}`, true)
+	executions = append(executions, Execution{Name: "ffmpeg", Result: ExecResult{Stderr: edgeSilenceStderr}})
+	executor := NewReplayExecutor(executions...)
+
+	builder := NewMP3Builder(WithExecutor(executor), WithSilenceTrim(SilenceTrim{NoiseFloor: -40}))
+	if err := builder.Append("interview.mp3", 0, -1); err != nil {
+		t.Fatalf("MP3Builder.Append() error = %v", err)
+	}
+	if s := builder.streams[0]; s.Start != 3 || s.Duration != 53 {
+		t.Errorf("MP3Builder.Append() segment = %v - %v, want 3 - 56", s.Start, s.Start+s.Duration)
+	}
+	if args := executor.Calls()[len(executor.Calls())-1].Args; !containsSequence(args, []string{"-af", "silencedetect=n=-40dB:d=0.1"}) {
+		t.Errorf("MP3Builder.Append() args = %v", args)
+	}
+}
@@ -0,0 +1,305 @@
+package mp3joiner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	flacStreamInfoBlockType    = 0
+	flacVorbisCommentBlockType = 4
+	flacStreamInfoLength       = 34
+	oggPageHeaderLength        = 27
+	// Opus always uses this sample rate for granule positions
+	opusGranuleSampleRate = 48000
+	vorbisChapterTimeBase = "1/1000"
+)
+
+var (
+	VORBIS_CHAPTER_REGEX = regexp.MustCompile(`(?i)^CHAPTER(\d+)(NAME|URL)?$`)
+
+	flacIdentifier            = []byte("fLaC")
+	oggPageIdentifier         = []byte("OggS")
+	vorbisIdentificationIdent = []byte("\x01vorbis")
+	vorbisCommentIdent        = []byte("\x03vorbis")
+	opusIdentificationIdent   = []byte("OpusHead")
+	opusCommentIdent          = []byte("OpusTags")
+
+	errNoVorbisCommentFound = errors.New("no vorbis comment found")
+)
+
+// Reads the chapters from the Vorbis comments of a FLAC, Ogg Vorbis or
+// Opus file without using ffprobe. Chapters follow the CHAPTERxx,
+// CHAPTERxxNAME and CHAPTERxxURL convention. Each chapter ends with
+// the start of the next one and the last one with the end of the audio.
+func ReadVorbisChapters(audioFilepath string) (chapters []Chapter, err error) {
+	file, err := os.Open(audioFilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(file)
+
+	header := make([]byte, len(oggPageIdentifier))
+	if _, err = io.ReadFull(file, header); err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var comments []string
+	var length float64
+	switch {
+	case bytes.Equal(header, flacIdentifier):
+		comments, length, err = readFLACComments(file)
+	case bytes.Equal(header, oggPageIdentifier):
+		comments, length, err = readOggComments(file)
+	default:
+		return nil, fmt.Errorf("'%s' is neither a FLAC nor an Ogg file", audioFilepath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseVorbisChapters(comments, length)
+}
+
+// Reads the comments and the length in seconds from the metadata blocks.
+func readFLACComments(reader io.Reader) (comments []string, length float64, err error) {
+	if _, err = io.ReadFull(reader, make([]byte, len(flacIdentifier))); err != nil {
+		return nil, 0, err
+	}
+
+	found := false
+	blockHeader := make([]byte, 4)
+	for isLast := false; !isLast; {
+		if _, err = io.ReadFull(reader, blockHeader); err != nil {
+			return nil, 0, err
+		}
+		isLast = blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7F
+		blockLength := int(blockHeader[1])<<16 | int(blockHeader[2])<<8 | int(blockHeader[3])
+		block := make([]byte, blockLength)
+		if _, err = io.ReadFull(reader, block); err != nil {
+			return nil, 0, err
+		}
+
+		switch blockType {
+		case flacStreamInfoBlockType:
+			if blockLength < flacStreamInfoLength {
+				return nil, 0, fmt.Errorf("STREAMINFO block is too short")
+			}
+			// 20 bits sample rate, 3 bits channels, 5 bits sample size, 36 bits samples
+			sampleRate := int(block[10])<<12 | int(block[11])<<4 | int(block[12])>>4
+			samples := uint64(block[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(block[14:]))
+			if sampleRate > 0 {
+				length = float64(samples) / float64(sampleRate)
+			}
+		case flacVorbisCommentBlockType:
+			if comments, err = decodeVorbisComment(block); err != nil {
+				return nil, 0, err
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil, 0, errNoVorbisCommentFound
+	}
+	return comments, length, nil
+}
+
+// Reads the comments from the comment header of the first logical
+// stream and the length in seconds from its last granule position.
+func readOggComments(reader io.ReadSeeker) (comments []string, length float64, err error) {
+	var serial uint32
+	var packets [][]byte
+	var packet []byte
+	granule := int64(-1)
+	isFirstPage := true
+	header := make([]byte, oggPageHeaderLength)
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, 0, err
+		}
+		if !bytes.Equal(header[:4], oggPageIdentifier) {
+			return nil, 0, fmt.Errorf("invalid Ogg page")
+		}
+		segments := make([]byte, header[26])
+		if _, err = io.ReadFull(reader, segments); err != nil {
+			return nil, 0, err
+		}
+		bodyLength := 0
+		for _, segment := range segments {
+			bodyLength += int(segment)
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(header[14:])
+		if isFirstPage {
+			serial = pageSerial
+			isFirstPage = false
+		}
+		if pageSerial != serial || len(packets) >= 2 {
+			if pageSerial == serial {
+				if position := int64(binary.LittleEndian.Uint64(header[6:])); position >= 0 {
+					granule = position
+				}
+			}
+			if _, err = reader.Seek(int64(bodyLength), io.SeekCurrent); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		body := make([]byte, bodyLength)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			return nil, 0, err
+		}
+		if position := int64(binary.LittleEndian.Uint64(header[6:])); position >= 0 {
+			granule = position
+		}
+		// packets end with a segment shorter than 255 bytes
+		for _, segment := range segments {
+			packet = append(packet, body[:segment]...)
+			body = body[segment:]
+			if segment < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+	if len(packets) < 2 {
+		return nil, 0, errNoVorbisCommentFound
+	}
+
+	identification, comment := packets[0], packets[1]
+	switch {
+	case bytes.HasPrefix(identification, vorbisIdentificationIdent) && bytes.HasPrefix(comment, vorbisCommentIdent):
+		if len(identification) < 16 {
+			return nil, 0, fmt.Errorf("identification header of the Vorbis stream is too short")
+		}
+		if sampleRate := binary.LittleEndian.Uint32(identification[12:]); sampleRate > 0 && granule > 0 {
+			length = float64(granule) / float64(sampleRate)
+		}
+		comments, err = decodeVorbisComment(comment[len(vorbisCommentIdent):])
+	case bytes.HasPrefix(identification, opusIdentificationIdent) && bytes.HasPrefix(comment, opusCommentIdent):
+		if len(identification) < 12 {
+			return nil, 0, fmt.Errorf("identification header of the Opus stream is too short")
+		}
+		preSkip := int64(binary.LittleEndian.Uint16(identification[10:]))
+		if granule > preSkip {
+			length = float64(granule-preSkip) / opusGranuleSampleRate
+		}
+		comments, err = decodeVorbisComment(comment[len(opusCommentIdent):])
+	default:
+		return nil, 0, fmt.Errorf("first stream of the Ogg file is neither Vorbis nor Opus")
+	}
+	return comments, length, err
+}
+
+// Decodes the vendor string and the list of comments, all with
+// little endian lengths, and returns the comments.
+func decodeVorbisComment(data []byte) ([]string, error) {
+	readString := func() (string, error) {
+		if len(data) < 4 {
+			return "", fmt.Errorf("vorbis comment is truncated")
+		}
+		length := binary.LittleEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-4) {
+			return "", fmt.Errorf("vorbis comment is truncated")
+		}
+		result := string(data[4 : 4+length])
+		data = data[4+length:]
+		return result, nil
+	}
+
+	if _, err := readString(); err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("vorbis comment is truncated")
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	result := make([]string, 0, min(int(count), len(data)/4))
+	for i := uint32(0); i < count; i++ {
+		comment, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, comment)
+	}
+	return result, nil
+}
+
+// Creates the chapters from CHAPTERxx comments. The chapters are
+// ordered by their number.
+func parseVorbisChapters(comments []string, lengthInSeconds float64) ([]Chapter, error) {
+	chaptersByNumber := make(map[int]*Chapter)
+	hasStart := make(map[int]bool)
+	for _, comment := range comments {
+		key, value, found := strings.Cut(comment, "=")
+		matches := VORBIS_CHAPTER_REGEX.FindStringSubmatch(key)
+		if !found || matches == nil {
+			continue
+		}
+		number, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter number in '%s': %w", key, err)
+		}
+		chapter, ok := chaptersByNumber[number]
+		if !ok {
+			chapter = &Chapter{TimeBase: vorbisChapterTimeBase}
+			chaptersByNumber[number] = chapter
+		}
+
+		switch strings.ToUpper(matches[2]) {
+		case "NAME":
+			chapter.Tags.Title = value
+		case "URL":
+			chapter.Links = append(chapter.Links, Link{URL: value})
+		default:
+			seconds, err := parseNormalPlayTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid start '%s' of %s: %w", value, key, err)
+			}
+			chapter.Start = int(math.Round(seconds * 1000))
+			hasStart[number] = true
+		}
+	}
+
+	numbers := make([]int, 0, len(chaptersByNumber))
+	for number := range chaptersByNumber {
+		if !hasStart[number] {
+			return nil, fmt.Errorf("chapter %d has no start", number)
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	result := make([]Chapter, 0, len(numbers))
+	for _, number := range numbers {
+		result = append(result, *chaptersByNumber[number])
+	}
+	for i := range result {
+		if i+1 < len(result) {
+			result[i].End = result[i+1].Start
+		} else {
+			result[i].End = max(result[i].Start, int(math.Round(lengthInSeconds*1000)))
+		}
+		if result[i].End < result[i].Start {
+			return nil, fmt.Errorf("chapter '%s' starts after the next chapter", result[i].Tags.Title)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package mp3joiner
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testVorbisChapterComments = []string{
+	"TITLE=Book",
+	"CHAPTER002=00:00:12.500",
+	"CHAPTER002NAME=Main",
+	"chapter001=00:00:00.000",
+	"chapter001name=Intro",
+	"CHAPTER001URL=https://example.com",
+}
+
+func createTestVorbisComment(comments []string) []byte {
+	putString := func(value string) []byte {
+		result := make([]byte, 4+len(value))
+		binary.LittleEndian.PutUint32(result, uint32(len(value)))
+		putBytes(result[4:], []byte(value))
+		return result
+	}
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, uint32(len(comments)))
+	result := concat(putString("test vendor"), count)
+	for _, comment := range comments {
+		result = concat(result, putString(comment))
+	}
+	return result
+}
+
+func createTestFLACBlock(blockType byte, isLast bool, payload []byte) []byte {
+	if isLast {
+		blockType |= 0x80
+	}
+	return concat([]byte{blockType, byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload))}, payload)
+}
+
+// Creates an Ogg page of a single stream. Packets must be shorter than 255 bytes.
+func createTestOggPage(granule int64, packets ...[]byte) []byte {
+	header := make([]byte, oggPageHeaderLength)
+	putBytes(header, oggPageIdentifier)
+	binary.LittleEndian.PutUint64(header[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(header[14:], 42)
+	header[26] = byte(len(packets))
+	segments := make([]byte, 0, len(packets))
+	for _, packet := range packets {
+		segments = append(segments, byte(len(packet)))
+	}
+	return concat(append(header, segments...), concat(packets...))
+}
+
+func TestReadVorbisChapters(t *testing.T) {
+	// 44100 Hz, 2 channels, 16 bits, 44100 * 30 samples
+	streamInfo := make([]byte, flacStreamInfoLength)
+	putBytes(streamInfo[10:], []byte{0x0A, 0xC4, 0x42, 0xF0, 0x00, 0x14, 0x2F, 0xF8})
+	flac := concat(flacIdentifier,
+		createTestFLACBlock(flacStreamInfoBlockType, false, streamInfo),
+		createTestFLACBlock(flacVorbisCommentBlockType, true, createTestVorbisComment(testVorbisChapterComments)),
+	)
+
+	opusHead := concat(opusIdentificationIdent, []byte{1, 2, 0x38, 0x01}, make([]byte, 7))
+	opus := concat(
+		createTestOggPage(0, opusHead),
+		createTestOggPage(0, concat(opusCommentIdent, createTestVorbisComment(testVorbisChapterComments))),
+		createTestOggPage(48000*30+312, make([]byte, 10)),
+	)
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "book.flac", content: flac},
+		{name: "book.opus", content: opus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audioFilepath := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(audioFilepath, tt.content, 0644); err != nil {
+				t.Fatalf("could not write test file: %v", err)
+			}
+
+			chapters, err := ReadVorbisChapters(audioFilepath)
+			if err != nil {
+				t.Fatalf("ReadVorbisChapters() error = %v", err)
+			}
+			if len(chapters) != 2 {
+				t.Fatalf("ReadVorbisChapters() got %d chapters, want 2", len(chapters))
+			}
+			if chapters[0].Tags.Title != "Intro" || chapters[0].GetEndTimeInSeconds() != 12.5 ||
+				len(chapters[0].Links) != 1 || chapters[0].Links[0].URL != "https://example.com" {
+				t.Errorf("ReadVorbisChapters() chapter 0 = %+v", chapters[0])
+			}
+			if chapters[1].Tags.Title != "Main" || chapters[1].GetStartTimeInSeconds() != 12.5 || chapters[1].GetEndTimeInSeconds() != 30 {
+				t.Errorf("ReadVorbisChapters() chapter 1 = %+v", chapters[1])
+			}
+		})
+	}
+}
+
+func Test_parseVorbisChaptersErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []string
+	}{
+		{name: "invalid start", comments: []string{"CHAPTER01=twelve"}},
+		{name: "missing start", comments: []string{"CHAPTER01=00:00:00.000", "CHAPTER02NAME=Outro"}},
+		{name: "unordered", comments: []string{"CHAPTER01=00:01:00.000", "CHAPTER02=00:00:10.000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseVorbisChapters(tt.comments, 120); err == nil {
+				t.Error("parseVorbisChapters() expected error")
+			}
+		})
+	}
+}